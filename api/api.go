@@ -0,0 +1,117 @@
+// Package api contém a especificação OpenAPI da API v1 e valida as requisições contra ela.
+package api
+
+import (
+	"context"
+	_ "embed"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/openapi3filter"
+	"github.com/getkin/kin-openapi/routers"
+	"github.com/getkin/kin-openapi/routers/legacy"
+)
+
+// Spec é o documento OpenAPI da API v1, servido em /api/v1/openapi.yaml
+//
+//go:embed openapi.yaml
+var Spec []byte
+
+// Erros de roteamento retornados por Validate quando a requisição não está na especificação
+var (
+	ErrRouteNotFound    = errors.New("rota não encontrada")
+	ErrMethodNotAllowed = errors.New("método não suportado")
+)
+
+// ValidationError indica que a requisição não respeita a especificação
+type ValidationError struct {
+	Reason string
+	Err    error
+}
+
+func (e *ValidationError) Error() string {
+	return e.Reason
+}
+
+func (e *ValidationError) Unwrap() error {
+	return e.Err
+}
+
+// Validator valida as requisições contra a especificação embutida
+type Validator struct {
+	router routers.Router
+}
+
+// NewValidator carrega e valida a especificação embutida
+func NewValidator() (*Validator, error) {
+	doc, err := openapi3.NewLoader().LoadFromData(Spec)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao carregar a especificação OpenAPI: %w", err)
+	}
+	if err := doc.Validate(context.Background()); err != nil {
+		return nil, fmt.Errorf("especificação OpenAPI inválida: %w", err)
+	}
+
+	router, err := legacy.NewRouter(doc)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao criar o roteador OpenAPI: %w", err)
+	}
+	return &Validator{router: router}, nil
+}
+
+// Validate verifica o método, a rota, os parâmetros e o corpo da requisição; o corpo é
+// restaurado para que o handler possa lê-lo novamente. O corpo é lido por inteiro, então quem
+// chama deve limitá-lo antes (ex.: http.MaxBytesReader).
+func (v *Validator) Validate(r *http.Request) error {
+	route, pathParams, err := v.router.FindRoute(r)
+	if err != nil {
+		var routeErr *routers.RouteError
+		if errors.As(err, &routeErr) && routeErr.Reason == routers.ErrMethodNotAllowed.Error() {
+			return ErrMethodNotAllowed
+		}
+		return ErrRouteNotFound
+	}
+
+	err = openapi3filter.ValidateRequest(r.Context(), &openapi3filter.RequestValidationInput{
+		Request:    r,
+		PathParams: pathParams,
+		Route:      route,
+		Options: &openapi3filter.Options{
+			AuthenticationFunc: openapi3filter.NoopAuthenticationFunc,
+		},
+	})
+	if err != nil {
+		return &ValidationError{Reason: describe(err), Err: err}
+	}
+	return nil
+}
+
+// describe resume o erro de validação indicando o campo, sem repetir o schema inteiro
+func describe(err error) string {
+	var requestErr *openapi3filter.RequestError
+	if errors.As(err, &requestErr) && requestErr.Parameter != nil {
+		reason := requestErr.Reason
+		var schemaErr *openapi3.SchemaError
+		if errors.As(err, &schemaErr) {
+			reason = schemaErr.Reason
+		}
+		return fmt.Sprintf("parâmetro %q inválido: %s", requestErr.Parameter.Name, reason)
+	}
+
+	var schemaErr *openapi3.SchemaError
+	if errors.As(err, &schemaErr) {
+		field := strings.Join(schemaErr.JSONPointer(), ".")
+		if field == "" {
+			return "corpo da requisição inválido: " + schemaErr.Reason
+		}
+		return fmt.Sprintf("campo %q inválido: %s", field, schemaErr.Reason)
+	}
+
+	if requestErr != nil && requestErr.Reason != "" {
+		return "corpo da requisição inválido: " + requestErr.Reason
+	}
+	return "requisição inválida: " + err.Error()
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/chatcomStackspotAI/llm"
+	"github.com/chatcomStackspotAI/models"
+)
+
+// backend envia os prompts ao servidor ou diretamente aos provedores
+type backend interface {
+	// Send envia o prompt na conversa atual e retorna a resposta
+	Send(ctx context.Context, prompt string) (string, error)
+	// Resume passa a usar a conversa informada; vazio inicia uma nova conversa
+	Resume(ctx context.Context, conversationID string) error
+	// ConversationID retorna a conversa atual, se houver
+	ConversationID() string
+	SetProvider(provider, model string)
+}
+
+// remoteBackend usa a API v1 do servidor, que mantém o histórico das conversas
+type remoteBackend struct {
+	baseURL        string
+	httpClient     *http.Client
+	provider       string
+	model          string
+	conversationID string
+}
+
+func newRemoteBackend(baseURL, provider, model string) *remoteBackend {
+	return &remoteBackend{
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		httpClient: &http.Client{Timeout: 6 * time.Minute},
+		provider:   provider,
+		model:      model,
+	}
+}
+
+func (b *remoteBackend) SetProvider(provider, model string) {
+	b.provider = provider
+	b.model = model
+}
+
+func (b *remoteBackend) ConversationID() string {
+	return b.conversationID
+}
+
+func (b *remoteBackend) Resume(ctx context.Context, conversationID string) error {
+	if conversationID == "" {
+		b.conversationID = ""
+		return nil
+	}
+	var conversation models.Conversation
+	if err := b.do(ctx, http.MethodGet, "/api/v1/conversations/"+conversationID, nil, &conversation); err != nil {
+		return err
+	}
+	b.conversationID = conversation.ID
+	return nil
+}
+
+func (b *remoteBackend) Send(ctx context.Context, prompt string) (string, error) {
+	// A conversa é criada no primeiro prompt, para que o histórico fique no servidor
+	if b.conversationID == "" {
+		var conversation models.Conversation
+		if err := b.do(ctx, http.MethodPost, "/api/v1/conversations", map[string]string{"title": title(prompt)}, &conversation); err != nil {
+			return "", err
+		}
+		b.conversationID = conversation.ID
+	}
+
+	request := map[string]string{
+		"provider":        b.provider,
+		"prompt":          prompt,
+		"conversation_id": b.conversationID,
+	}
+	if b.model != "" {
+		request["model"] = b.model
+	}
+
+	var completion struct {
+		Content string `json:"content"`
+	}
+	if err := b.do(ctx, http.MethodPost, "/api/v1/chat/completions", request, &completion); err != nil {
+		return "", err
+	}
+	return completion.Content, nil
+}
+
+func (b *remoteBackend) do(ctx context.Context, method, path string, body, result interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		payload, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(payload)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, b.baseURL+path, reader)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := b.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("erro ao conectar ao servidor %s: %w", b.baseURL, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		var envelope struct {
+			Error *models.APIError `json:"error"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&envelope); err != nil || envelope.Error == nil {
+			return fmt.Errorf("o servidor respondeu %s", resp.Status)
+		}
+		return fmt.Errorf("%s (código: %s, request ID: %s)", envelope.Error.Message, envelope.Error.Code, envelope.Error.RequestID)
+	}
+	return json.NewDecoder(resp.Body).Decode(result)
+}
+
+// localBackend chama os provedores pelo LLMManager, com o histórico mantido em memória
+type localBackend struct {
+	manager  *llm.LLMManager
+	provider string
+	model    string
+	history  []models.Message
+}
+
+func newLocalBackend(manager *llm.LLMManager, provider, model string) *localBackend {
+	return &localBackend{
+		manager:  manager,
+		provider: provider,
+		model:    model,
+	}
+}
+
+func (b *localBackend) SetProvider(provider, model string) {
+	b.provider = provider
+	b.model = model
+}
+
+func (b *localBackend) ConversationID() string {
+	return ""
+}
+
+func (b *localBackend) Resume(ctx context.Context, conversationID string) error {
+	if conversationID != "" {
+		return fmt.Errorf("o modo local não mantém conversas; use o modo servidor para retomar %s", conversationID)
+	}
+	b.history = nil
+	return nil
+}
+
+func (b *localBackend) Send(ctx context.Context, prompt string) (string, error) {
+	client, err := b.manager.GetClientWithModel(ctx, b.provider, b.model)
+	if err != nil {
+		return "", err
+	}
+
+	response, err := client.SendPrompt(ctx, prompt, b.history)
+	if err != nil {
+		var llmErr *llm.Error
+		if errors.As(err, &llmErr) {
+			return "", fmt.Errorf("%s (código: %s): %w", llmErr.UserMessage(), llmErr.Code, err)
+		}
+		return "", err
+	}
+
+	b.history = append(b.history,
+		models.Message{Role: "user", Content: prompt},
+		models.Message{Role: "assistant", Content: response, Provider: b.provider, Model: client.GetModelName()})
+	return response, nil
+}
+
+// title resume o primeiro prompt para nomear a conversa
+func title(prompt string) string {
+	line := strings.TrimSpace(strings.SplitN(prompt, "\n", 2)[0])
+	if runes := []rune(line); len(runes) > 60 {
+		return string(runes[:60]) + "…"
+	}
+	return line
+}
@@ -0,0 +1,233 @@
+// Command chatcli conversa com os provedores de LLM pelo terminal, usando a API do servidor
+// ou, no modo local, o LLMManager diretamente.
+//
+//	chatcli                                 # REPL interativo com o servidor local
+//	chatcli -provider CLAUDEAI "Explique X" # prompt único
+//	git diff | chatcli "Revise este diff"   # prompt a partir do stdin
+//	chatcli -local -provider MOCK           # sem servidor, usando as variáveis de ambiente
+package main
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+
+	"github.com/chatcomStackspotAI/llm"
+	"github.com/joho/godotenv"
+	"go.uber.org/zap"
+)
+
+const replHelp = `Comandos:
+  /new                      inicia uma nova conversa
+  /resume <id>              retoma uma conversa do servidor
+  /provider <nome> [modelo] troca o provedor (OPENAI, CLAUDEAI, SPOT, MOCK)
+  /id                       mostra o ID da conversa atual
+  /help                     mostra esta ajuda
+  /exit                     encerra
+Linhas terminadas em \ continuam na próxima.`
+
+func main() {
+	serverURL := flag.String("server", envOrDefault("CHAT_SERVER_URL", "http://localhost:8080"), "URL do servidor de chat")
+	provider := flag.String("provider", envOrDefault("CHAT_PROVIDER", llm.ProviderOpenAI), "provedor de LLM (OPENAI, CLAUDEAI, SPOT, MOCK)")
+	model := flag.String("model", "", "modelo do provedor; para a StackSpot, o slug do quick command")
+	conversationID := flag.String("conversation", "", "retoma a conversa com este ID (modo servidor)")
+	local := flag.Bool("local", false, "chama os provedores diretamente, sem o servidor")
+	raw := flag.Bool("raw", false, "imprime as respostas sem renderizar o markdown")
+	verbose := flag.Bool("verbose", false, "exibe os logs do LLMManager no modo local")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Uso: chatcli [flags] [prompt]\n\n")
+		flag.PrintDefaults()
+		fmt.Fprintf(flag.CommandLine.Output(), "\n%s\n", replHelp)
+	}
+	flag.Parse()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	chat, err := newBackend(*local, *verbose, *serverURL, *provider, *model)
+	if err != nil {
+		fatal(err)
+	}
+	if *conversationID != "" {
+		if err := chat.Resume(ctx, *conversationID); err != nil {
+			fatal(err)
+		}
+	}
+
+	printer := responsePrinter{markdown: !*raw && isTerminal(os.Stdout) && os.Getenv("NO_COLOR") == ""}
+
+	// Com argumentos ou stdin redirecionado, envia um único prompt e encerra
+	prompt := strings.Join(flag.Args(), " ")
+	if !isTerminal(os.Stdin) {
+		input, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			fatal(fmt.Errorf("erro ao ler o stdin: %w", err))
+		}
+		if text := strings.TrimSpace(string(input)); text != "" {
+			prompt = strings.TrimSpace(prompt + "\n\n" + text)
+		}
+	}
+	if prompt != "" || !isTerminal(os.Stdin) {
+		if prompt == "" {
+			fatal(fmt.Errorf("nenhum prompt informado"))
+		}
+		response, err := chat.Send(ctx, prompt)
+		if err != nil {
+			fatal(err)
+		}
+		printer.print(response)
+		return
+	}
+
+	repl(ctx, chat, printer, *provider)
+}
+
+func repl(ctx context.Context, chat backend, printer responsePrinter, provider string) {
+	fmt.Fprintf(os.Stderr, "chatcli — provedor %s. Digite /help para ver os comandos.\n", provider)
+	if id := chat.ConversationID(); id != "" {
+		fmt.Fprintf(os.Stderr, "Conversa %s retomada.\n", id)
+	}
+
+	scanner := bufio.NewScanner(os.Stdin)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	var pending []string
+
+	for {
+		if len(pending) == 0 {
+			fmt.Fprint(os.Stderr, "\n> ")
+		} else {
+			fmt.Fprint(os.Stderr, "… ")
+		}
+		if !scanner.Scan() {
+			fmt.Fprintln(os.Stderr)
+			return
+		}
+
+		line := scanner.Text()
+		if strings.HasSuffix(line, `\`) {
+			pending = append(pending, strings.TrimSuffix(line, `\`))
+			continue
+		}
+		input := strings.TrimSpace(strings.Join(append(pending, line), "\n"))
+		pending = nil
+		if input == "" {
+			continue
+		}
+
+		if strings.HasPrefix(input, "/") {
+			if quit := runCommand(ctx, chat, input, &provider); quit {
+				return
+			}
+			continue
+		}
+
+		fmt.Fprint(os.Stderr, "pensando…\r")
+		response, err := chat.Send(ctx, input)
+		fmt.Fprint(os.Stderr, "          \r")
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			fmt.Fprintln(os.Stderr, "Erro:", err)
+			continue
+		}
+		fmt.Println()
+		printer.print(response)
+	}
+}
+
+// runCommand executa um comando do REPL e indica se a sessão deve terminar
+func runCommand(ctx context.Context, chat backend, input string, provider *string) bool {
+	fields := strings.Fields(input)
+	switch fields[0] {
+	case "/exit", "/quit":
+		return true
+	case "/help":
+		fmt.Fprintln(os.Stderr, replHelp)
+	case "/id":
+		if id := chat.ConversationID(); id != "" {
+			fmt.Fprintln(os.Stderr, id)
+		} else {
+			fmt.Fprintln(os.Stderr, "Nenhuma conversa no servidor ainda.")
+		}
+	case "/new":
+		chat.Resume(ctx, "")
+		fmt.Fprintln(os.Stderr, "Nova conversa iniciada.")
+	case "/resume":
+		if len(fields) < 2 {
+			fmt.Fprintln(os.Stderr, "Uso: /resume <id>")
+			break
+		}
+		if err := chat.Resume(ctx, fields[1]); err != nil {
+			fmt.Fprintln(os.Stderr, "Erro:", err)
+			break
+		}
+		fmt.Fprintf(os.Stderr, "Conversa %s retomada.\n", fields[1])
+	case "/provider":
+		if len(fields) < 2 {
+			fmt.Fprintln(os.Stderr, "Uso: /provider <nome> [modelo]")
+			break
+		}
+		*provider = strings.ToUpper(fields[1])
+		model := ""
+		if len(fields) > 2 {
+			model = fields[2]
+		}
+		chat.SetProvider(*provider, model)
+		fmt.Fprintf(os.Stderr, "Usando o provedor %s.\n", *provider)
+	default:
+		fmt.Fprintf(os.Stderr, "Comando desconhecido: %s. Digite /help.\n", fields[0])
+	}
+	return false
+}
+
+func newBackend(local, verbose bool, serverURL, provider, model string) (backend, error) {
+	if !local {
+		return newRemoteBackend(serverURL, provider, model), nil
+	}
+
+	// O modo local lê as mesmas variáveis (e o .env) que o servidor
+	godotenv.Load()
+	logger := zap.NewNop()
+	if verbose {
+		logger, _ = zap.NewDevelopment()
+	}
+	manager, err := llm.NewLLMManager(logger)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao inicializar o LLMManager: %w", err)
+	}
+	return newLocalBackend(manager, provider, model), nil
+}
+
+type responsePrinter struct {
+	markdown bool
+}
+
+func (p responsePrinter) print(response string) {
+	if p.markdown {
+		response = renderMarkdown(response)
+	}
+	fmt.Println(response)
+}
+
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+func envOrDefault(key, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
+
+func fatal(err error) {
+	fmt.Fprintln(os.Stderr, "Erro:", err)
+	os.Exit(1)
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Sequências ANSI usadas na renderização
+const (
+	ansiReset     = "\033[0m"
+	ansiBold      = "\033[1m"
+	ansiDim       = "\033[2m"
+	ansiItalic    = "\033[3m"
+	ansiUnderline = "\033[4m"
+	ansiCyan      = "\033[36m"
+	ansiYellow    = "\033[33m"
+	ansiMagenta   = "\033[35m"
+)
+
+var (
+	headingPattern    = regexp.MustCompile(`^(#{1,6})\s+(.*)$`)
+	listPattern       = regexp.MustCompile(`^(\s*)([-*+]|\d+\.)\s+(.*)$`)
+	inlineCodePattern = regexp.MustCompile("`([^`]+)`")
+	boldPattern       = regexp.MustCompile(`\*\*([^*]+)\*\*|__([^_]+)__`)
+	italicPattern     = regexp.MustCompile(`(^|[^*])\*([^*\s][^*]*)\*`)
+	linkPattern       = regexp.MustCompile(`\[([^\]]+)\]\(([^)]+)\)`)
+)
+
+// renderMarkdown converte o markdown mais comum nas respostas (títulos, listas, ênfase,
+// código e links) em texto com cores ANSI para o terminal
+func renderMarkdown(text string) string {
+	var out strings.Builder
+	inCode := false
+
+	for _, line := range strings.Split(text, "\n") {
+		trimmed := strings.TrimSpace(line)
+
+		if strings.HasPrefix(trimmed, "```") {
+			inCode = !inCode
+			if inCode {
+				if lang := strings.TrimPrefix(trimmed, "```"); lang != "" {
+					out.WriteString(ansiDim + "┌ " + lang + ansiReset + "\n")
+				} else {
+					out.WriteString(ansiDim + "┌" + ansiReset + "\n")
+				}
+			} else {
+				out.WriteString(ansiDim + "└" + ansiReset + "\n")
+			}
+			continue
+		}
+
+		switch {
+		case inCode:
+			out.WriteString(ansiDim + "│ " + ansiReset + ansiYellow + line + ansiReset)
+		case headingPattern.MatchString(line):
+			heading := headingPattern.FindStringSubmatch(line)[2]
+			out.WriteString(ansiBold + ansiUnderline + ansiMagenta + renderInline(heading) + ansiReset)
+		case listPattern.MatchString(line):
+			parts := listPattern.FindStringSubmatch(line)
+			bullet := "•"
+			if strings.HasSuffix(parts[2], ".") {
+				bullet = parts[2]
+			}
+			out.WriteString(parts[1] + ansiCyan + bullet + ansiReset + " " + renderInline(parts[3]))
+		case strings.HasPrefix(trimmed, ">"):
+			out.WriteString(ansiDim + "┃ " + ansiItalic + renderInline(strings.TrimSpace(strings.TrimPrefix(trimmed, ">"))) + ansiReset)
+		case trimmed == "---" || trimmed == "***":
+			out.WriteString(ansiDim + strings.Repeat("─", 40) + ansiReset)
+		default:
+			out.WriteString(renderInline(line))
+		}
+		out.WriteString("\n")
+	}
+	return strings.TrimRight(out.String(), "\n")
+}
+
+// renderInline aplica código, negrito, itálico e links dentro de uma linha
+func renderInline(line string) string {
+	// O código é separado primeiro para que a ênfase não seja aplicada dentro dele
+	segments := inlineCodePattern.Split(line, -1)
+	codes := inlineCodePattern.FindAllStringSubmatch(line, -1)
+
+	var out strings.Builder
+	for i, segment := range segments {
+		segment = boldPattern.ReplaceAllStringFunc(segment, func(match string) string {
+			parts := boldPattern.FindStringSubmatch(match)
+			return ansiBold + parts[1] + parts[2] + ansiReset
+		})
+		segment = italicPattern.ReplaceAllString(segment, "$1"+ansiItalic+"$2"+ansiReset)
+		segment = linkPattern.ReplaceAllString(segment, ansiUnderline+"$1"+ansiReset+ansiDim+" ($2)"+ansiReset)
+		out.WriteString(segment)
+
+		if i < len(codes) {
+			out.WriteString(ansiYellow + codes[i][1] + ansiReset)
+		}
+	}
+	return out.String()
+}
@@ -0,0 +1,281 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/chatcomStackspotAI/api"
+	"github.com/chatcomStackspotAI/llm"
+	"github.com/chatcomStackspotAI/middlewares"
+	"github.com/chatcomStackspotAI/models"
+	"github.com/chatcomStackspotAI/tracing"
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+	"go.uber.org/zap"
+)
+
+// maxAPIBodySize limita o corpo das requisições de /api/v1 sem limite próprio em apiBodyLimits
+const maxAPIBodySize = 2 << 20
+
+// apiBodyLimits define os limites de corpo das rotas que aceitam arquivos maiores
+var apiBodyLimits = map[string]int64{
+	"POST /api/v1/conversations/import": maxImportSize,
+}
+
+// ChatCompletion é a resposta de POST /api/v1/chat/completions
+type ChatCompletion struct {
+	ID             string    `json:"id"`
+	ConversationID string    `json:"conversation_id,omitempty"`
+	Provider       string    `json:"provider"`
+	Model          string    `json:"model"`
+	Content        string    `json:"content"`
+	Usage          llm.Usage `json:"usage"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+// listResponse envolve as listagens da API v1
+type listResponse struct {
+	Data interface{} `json:"data"`
+}
+
+// RegisterAPIV1 registra as rotas de /api/v1 no mux; toda requisição é validada contra a
+// especificação OpenAPI antes de chegar ao handler
+func RegisterAPIV1(mux *http.ServeMux, manager *llm.LLMManager, conversations *ConversationStore, webhooks *WebhookDispatcher, logger *zap.Logger) error {
+	validator, err := api.NewValidator()
+	if err != nil {
+		return err
+	}
+	comparisons := newComparisonStore()
+
+	routes := map[string]http.HandlerFunc{
+		"POST /api/v1/chat/completions":         chatCompletionsV1Handler(manager, conversations, logger),
+		"GET /api/v1/conversations":             listConversationsV1Handler(conversations),
+		"POST /api/v1/conversations":            createConversationV1Handler(conversations, logger),
+		"GET /api/v1/conversations/{id}":        getConversationV1Handler(conversations),
+		"PATCH /api/v1/conversations/{id}":      updateConversationV1Handler(conversations, logger),
+		"DELETE /api/v1/conversations/{id}":     deleteConversationV1Handler(conversations),
+		"GET /api/v1/conversations/{id}/export": exportConversationV1Handler(conversations),
+		"POST /api/v1/conversations/import":     importConversationsV1Handler(conversations, logger),
+		"POST /api/v1/compare":                  compareV1Handler(manager, conversations, comparisons, logger),
+		"GET /api/v1/compare/{id}":              getComparisonV1Handler(comparisons),
+		"POST /api/v1/compare/{id}/winner":      chooseWinnerV1Handler(conversations, comparisons, logger),
+		"POST /api/v1/ensemble":                 ensembleV1Handler(manager, conversations, logger),
+		"GET /api/v1/models":                    modelsV1Handler(manager),
+		"GET /api/v1/providers":                 providersV1Handler(manager),
+		"GET /api/v1/usage":                     usageV1Handler(manager),
+		"GET /api/v1/webhooks/deliveries":       listWebhookDeliveriesV1Handler(webhooks),
+		"GET /api/v1/webhooks/deliveries/{id}":  getWebhookDeliveryV1Handler(webhooks),
+		"GET /api/v1/openapi.yaml":              openAPIHandler(),
+	}
+	for pattern, handler := range routes {
+		limit, ok := apiBodyLimits[pattern]
+		if !ok {
+			limit = maxAPIBodySize
+		}
+		mux.Handle(pattern, validated(validator, limit, handler))
+	}
+
+	// Rotas ou métodos fora da especificação também recebem o envelope de erro
+	mux.Handle("/api/v1/", validated(validator, maxAPIBodySize, func(w http.ResponseWriter, r *http.Request) {
+		WriteError(w, r, http.StatusNotFound, ErrCodeNotFound, "Rota não encontrada")
+	}))
+	return nil
+}
+
+// validated responde 400, 404 ou 405 com o envelope de erro quando a requisição não respeita a
+// especificação, e 413 quando o corpo passa de maxBody bytes; o limite vale antes da validação,
+// que lê o corpo inteiro
+func validated(validator *api.Validator, maxBody int64, next http.HandlerFunc) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.Body = http.MaxBytesReader(w, r.Body, maxBody)
+		err := validator.Validate(r)
+		var tooLarge *http.MaxBytesError
+		switch {
+		case err == nil:
+			next(w, r)
+		case errors.As(err, &tooLarge):
+			WriteError(w, r, http.StatusRequestEntityTooLarge, ErrCodeInvalidRequest,
+				fmt.Sprintf("Corpo da requisição maior que o limite de %d bytes", tooLarge.Limit))
+		case errors.Is(err, api.ErrMethodNotAllowed):
+			WriteError(w, r, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "Método não suportado")
+		case errors.Is(err, api.ErrRouteNotFound):
+			WriteError(w, r, http.StatusNotFound, ErrCodeNotFound, "Rota não encontrada")
+		default:
+			WriteError(w, r, http.StatusBadRequest, ErrCodeInvalidRequest, err.Error())
+		}
+	})
+}
+
+func chatCompletionsV1Handler(manager *llm.LLMManager, conversations *ConversationStore, logger *zap.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, span := tracing.Start(r.Context(), "ChatCompletionsV1Handler")
+		defer span.End()
+
+		var data struct {
+			Provider       string           `json:"provider"`
+			Model          string           `json:"model"`
+			Prompt         string           `json:"prompt"`
+			History        []models.Message `json:"history"`
+			ConversationID string           `json:"conversation_id"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+			WriteError(w, r, http.StatusBadRequest, ErrCodeInvalidRequest, "Dados inválidos")
+			return
+		}
+
+		span.SetAttributes(
+			attribute.String("llm.provider", data.Provider),
+			attribute.String("conversation_id", data.ConversationID))
+
+		history := data.History
+		if data.ConversationID != "" {
+			conversation, exists := conversations.Get(data.ConversationID)
+			if !exists {
+				WriteError(w, r, http.StatusNotFound, ErrCodeNotFound, "Conversa não encontrada")
+				return
+			}
+			history = conversation.Messages
+		}
+
+		client, err := manager.GetClientWithModel(ctx, data.Provider, data.Model)
+		if err != nil {
+			logger.Error("Erro ao obter o cliente LLM", zap.Error(err))
+			WriteLLMError(w, r, err)
+			return
+		}
+
+		extendWriteDeadline(w, completionTimeout+10*time.Second, logger)
+
+		content, usage, err := runCompletion(ctx, client, data.Prompt, history)
+		if err != nil {
+			logger.Error("Erro ao obter a resposta da LLM",
+				zap.String("request_id", middlewares.RequestIDFromContext(ctx)),
+				zap.Error(err))
+			span.RecordError(err)
+			WriteLLMError(w, r, err)
+			return
+		}
+
+		if data.ConversationID != "" {
+			conversations.AppendMessages(data.ConversationID,
+				models.Message{Role: "user", Content: data.Prompt},
+				models.Message{Role: "assistant", Content: content, Provider: data.Provider, Model: client.GetModelName()})
+		}
+
+		writeJSON(w, http.StatusOK, ChatCompletion{
+			ID:             uuid.New().String(),
+			ConversationID: data.ConversationID,
+			Provider:       data.Provider,
+			Model:          client.GetModelName(),
+			Content:        content,
+			Usage:          usage,
+			CreatedAt:      time.Now().UTC(),
+		})
+	}
+}
+
+func listConversationsV1Handler(conversations *ConversationStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, listResponse{Data: conversations.List()})
+	}
+}
+
+func createConversationV1Handler(conversations *ConversationStore, logger *zap.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var data struct {
+			Title string `json:"title"`
+		}
+		// O corpo é opcional
+		if r.ContentLength != 0 {
+			if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+				WriteError(w, r, http.StatusBadRequest, ErrCodeInvalidRequest, "Dados inválidos")
+				return
+			}
+		}
+
+		conversation := conversations.Create(data.Title)
+		logger.Info("Conversa criada", zap.String("conversation_id", conversation.ID))
+
+		w.Header().Set("Location", "/api/v1/conversations/"+conversation.ID)
+		writeJSON(w, http.StatusCreated, conversation)
+	}
+}
+
+func getConversationV1Handler(conversations *ConversationStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		conversation, exists := conversations.Get(r.PathValue("id"))
+		if !exists {
+			WriteError(w, r, http.StatusNotFound, ErrCodeNotFound, "Conversa não encontrada")
+			return
+		}
+		writeJSON(w, http.StatusOK, conversation)
+	}
+}
+
+func updateConversationV1Handler(conversations *ConversationStore, logger *zap.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var data struct {
+			Title string `json:"title"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+			WriteError(w, r, http.StatusBadRequest, ErrCodeInvalidRequest, "Dados inválidos")
+			return
+		}
+
+		conversation, exists := conversations.Rename(r.PathValue("id"), data.Title)
+		if !exists {
+			WriteError(w, r, http.StatusNotFound, ErrCodeNotFound, "Conversa não encontrada")
+			return
+		}
+		logger.Info("Conversa renomeada", zap.String("conversation_id", conversation.ID))
+		writeJSON(w, http.StatusOK, conversation)
+	}
+}
+
+func deleteConversationV1Handler(conversations *ConversationStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !conversations.Delete(r.PathValue("id")) {
+			WriteError(w, r, http.StatusNotFound, ErrCodeNotFound, "Conversa não encontrada")
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func modelsV1Handler(manager *llm.LLMManager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, listResponse{Data: manager.Models()})
+	}
+}
+
+func providersV1Handler(manager *llm.LLMManager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, listResponse{Data: manager.ProviderStatuses()})
+	}
+}
+
+func usageV1Handler(manager *llm.LLMManager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		since, summaries := manager.Usage()
+		writeJSON(w, http.StatusOK, struct {
+			Since time.Time          `json:"since"`
+			Data  []llm.UsageSummary `json:"data"`
+		}{since, summaries})
+	}
+}
+
+func openAPIHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/yaml")
+		w.Write(api.Spec)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(value)
+}
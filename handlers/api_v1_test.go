@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/chatcomStackspotAI/llm/llmtest"
+	"go.uber.org/zap"
+)
+
+func TestAPIV1BodyLimit(t *testing.T) {
+	fake := llmtest.NewServer()
+	defer fake.Close()
+
+	logger := zap.NewNop()
+	mux := http.NewServeMux()
+	if err := RegisterAPIV1(mux, newTestManager(t, fake), NewConversationStore(), NewWebhookDispatcher(WebhookConfig{}, logger), logger); err != nil {
+		t.Fatalf("RegisterAPIV1: %v", err)
+	}
+
+	large := strings.Repeat("a", maxAPIBodySize+1)
+	cases := []struct {
+		name   string
+		path   string
+		body   string
+		status int
+	}{
+		{"chat acima do limite padrão", "/api/v1/chat/completions",
+			`{"provider":"OPENAI","prompt":"` + large + `"}`, http.StatusRequestEntityTooLarge},
+		{"importação abaixo do próprio limite", "/api/v1/conversations/import",
+			`{"title":"` + large + `","messages":[{"role":"user","content":"oi"}]}`, http.StatusCreated},
+		{"importação acima do próprio limite", "/api/v1/conversations/import",
+			`{"title":"` + strings.Repeat("a", maxImportSize) + `"}`, http.StatusRequestEntityTooLarge},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, tc.path, strings.NewReader(tc.body))
+			req.Header.Set("Content-Type", "application/json")
+			rec := httptest.NewRecorder()
+			mux.ServeHTTP(rec, req)
+
+			if rec.Code != tc.status {
+				t.Fatalf("status %d, esperado %d: %.200s", rec.Code, tc.status, rec.Body)
+			}
+			if tc.status == http.StatusRequestEntityTooLarge && !strings.Contains(rec.Body.String(), `"invalid_request"`) {
+				t.Fatalf("corpo sem o envelope de erro: %s", rec.Body)
+			}
+		})
+	}
+}
@@ -0,0 +1,346 @@
+package handlers
+
+import (
+	"context"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/chatcomStackspotAI/llm"
+	"github.com/chatcomStackspotAI/middlewares"
+	"github.com/chatcomStackspotAI/models"
+	"github.com/chatcomStackspotAI/tracing"
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+	"go.uber.org/zap"
+)
+
+// maxBatches é o número de lotes mantidos em memória; os mais antigos já concluídos são descartados
+const maxBatches = 100
+
+// BatchConfig configura o BatchProcessor
+type BatchConfig struct {
+	Workers  int // Itens processados ao mesmo tempo, somando todos os lotes; padrão: 4
+	MaxItems int // Itens aceitos por lote; padrão: 1000
+}
+
+// BatchConfigFromEnv lê BATCH_WORKERS e BATCH_MAX_ITEMS
+func BatchConfigFromEnv() BatchConfig {
+	var config BatchConfig
+	if v, err := strconv.Atoi(os.Getenv("BATCH_WORKERS")); err == nil && v > 0 {
+		config.Workers = v
+	}
+	if v, err := strconv.Atoi(os.Getenv("BATCH_MAX_ITEMS")); err == nil && v > 0 {
+		config.MaxItems = v
+	}
+	return config
+}
+
+// Batch é um lote de prompts enviado a /api/batches
+type Batch struct {
+	ID          string      `json:"id"`
+	Status      string      `json:"status"` // "running", "completed" ou "cancelled"
+	Concurrency int         `json:"concurrency"`
+	Counts      BatchCounts `json:"counts"`
+	CreatedAt   time.Time   `json:"created_at"`
+	CompletedAt *time.Time  `json:"completed_at,omitempty"`
+
+	items   []*BatchItem
+	running int // Itens em andamento, inclusive os aguardando um worker
+	cancel  context.CancelFunc
+}
+
+// BatchCounts resume o estado dos itens de um lote
+type BatchCounts struct {
+	Total     int `json:"total"`
+	Pending   int `json:"pending"`
+	Running   int `json:"running"`
+	Completed int `json:"completed"`
+	Failed    int `json:"failed"`
+	Cancelled int `json:"cancelled"`
+}
+
+// BatchItem é um prompt do lote e o seu resultado, uma linha do arquivo de resultados
+type BatchItem struct {
+	Index     int              `json:"index"`
+	CustomID  string           `json:"custom_id,omitempty"`
+	Provider  string           `json:"provider"`
+	Model     string           `json:"model,omitempty"`
+	Status    string           `json:"status"` // "pending", "running", "completed", "failed" ou "cancelled"
+	Attempts  int              `json:"attempts"`
+	Response  string           `json:"response,omitempty"`
+	Usage     *llm.Usage       `json:"usage,omitempty"`
+	Error     *models.APIError `json:"error,omitempty"`
+	LatencyMs int64            `json:"latency_ms,omitempty"`
+	// Parâmetros da linha que o provedor não aplica (ex.: temperature na StackSpot)
+	IgnoredParameters []string `json:"ignored_parameters,omitempty"`
+
+	prompt  string
+	history []models.Message
+	options llm.GenerationOptions
+}
+
+// BatchProcessor executa os lotes com um limite global de itens simultâneos, como um pool de
+// workers compartilhado, e um limite opcional por lote. Os lotes ficam apenas em memória.
+type BatchProcessor struct {
+	manager  *llm.LLMManager
+	workers  chan struct{} // Um slot por worker
+	maxItems int
+	logger   *zap.Logger
+
+	mu      sync.Mutex
+	batches map[string]*Batch
+	order   []string // IDs dos lotes, do mais antigo para o mais recente
+}
+
+func NewBatchProcessor(manager *llm.LLMManager, config BatchConfig, logger *zap.Logger) *BatchProcessor {
+	if config.Workers <= 0 {
+		config.Workers = 4
+	}
+	if config.MaxItems <= 0 {
+		config.MaxItems = 1000
+	}
+	return &BatchProcessor{
+		manager:  manager,
+		workers:  make(chan struct{}, config.Workers),
+		maxItems: config.MaxItems,
+		logger:   logger,
+		batches:  make(map[string]*Batch),
+	}
+}
+
+// Submit registra o lote e começa a processá-lo em background. concurrency limita os itens
+// simultâneos do lote (0 usa todos os workers).
+func (p *BatchProcessor) Submit(ctx context.Context, items []*BatchItem, concurrency int) Batch {
+	if concurrency <= 0 || concurrency > cap(p.workers) {
+		concurrency = cap(p.workers)
+	}
+	for i, item := range items {
+		item.Index = i
+		item.Status = "pending"
+	}
+
+	// O lote continua depois da requisição, mantendo o span e o request ID dela
+	batchCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	batch := &Batch{
+		ID:          uuid.New().String(),
+		Status:      "running",
+		Concurrency: concurrency,
+		CreatedAt:   time.Now().UTC(),
+		items:       items,
+		cancel:      cancel,
+	}
+
+	p.mu.Lock()
+	p.batches[batch.ID] = batch
+	p.order = append(p.order, batch.ID)
+	p.evictLocked()
+	batch.running = len(items)
+	snapshot := p.snapshotLocked(batch)
+	p.mu.Unlock()
+
+	p.logger.Info("Lote recebido", zap.String("batch_id", batch.ID), zap.Int("items", len(items)))
+	go p.run(batchCtx, batch, items)
+	return snapshot
+}
+
+// run entrega os itens aos workers respeitando o limite do lote
+func (p *BatchProcessor) run(ctx context.Context, batch *Batch, items []*BatchItem) {
+	slots := make(chan struct{}, batch.Concurrency)
+	var wg sync.WaitGroup
+
+	for _, item := range items {
+		select {
+		case slots <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if ctx.Err() == nil {
+			select {
+			case p.workers <- struct{}{}:
+			case <-ctx.Done():
+				<-slots
+			}
+		}
+		if ctx.Err() != nil {
+			p.finishItem(batch, item, func() { item.Status = "cancelled" })
+			continue
+		}
+
+		wg.Add(1)
+		go func(item *BatchItem) {
+			defer wg.Done()
+			defer func() { <-p.workers; <-slots }()
+			p.process(ctx, batch, item)
+		}(item)
+	}
+	wg.Wait()
+}
+
+// process envia o prompt de um item ao provedor
+func (p *BatchProcessor) process(ctx context.Context, batch *Batch, item *BatchItem) {
+	p.mu.Lock()
+	item.Status = "running"
+	item.Attempts++
+	p.mu.Unlock()
+
+	ctx, span := tracing.Start(ctx, "BatchProcessor.item",
+		attribute.String("batch_id", batch.ID),
+		attribute.Int("batch.index", item.Index),
+		attribute.String("llm.provider", item.Provider))
+
+	start := time.Now()
+	var response string
+	var usage llm.Usage
+	client, err := p.manager.GetClientWithModel(ctx, item.Provider, item.Model)
+	if err == nil {
+		response, usage, err = runCompletion(llm.WithGenerationOptions(ctx, item.options), client, item.prompt, item.history)
+	}
+	tracing.End(span, err)
+
+	p.finishItem(batch, item, func() {
+		item.LatencyMs = time.Since(start).Milliseconds()
+		switch {
+		case err == nil:
+			item.Status, item.Response, item.Usage, item.Error = "completed", response, &usage, nil
+		case ctx.Err() != nil:
+			item.Status = "cancelled"
+		default:
+			item.Status = "failed"
+			item.Error, _ = NewLLMAPIError(err, middlewares.RequestIDFromContext(ctx))
+			p.logger.Warn("Falha em um item do lote",
+				zap.String("batch_id", batch.ID), zap.Int("index", item.Index), zap.Error(err))
+		}
+	})
+}
+
+// finishItem aplica o resultado do item e conclui o lote quando ele é o último em andamento
+func (p *BatchProcessor) finishItem(batch *Batch, item *BatchItem, apply func()) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	apply()
+	batch.running--
+	if batch.running > 0 {
+		return
+	}
+
+	now := time.Now().UTC()
+	batch.CompletedAt = &now
+	if batch.Status == "running" {
+		batch.Status = "completed"
+	}
+	batch.cancel()
+	p.logger.Info("Lote concluído", zap.String("batch_id", batch.ID), zap.String("status", batch.Status))
+}
+
+// Cancel interrompe os itens em andamento e descarta os pendentes
+func (p *BatchProcessor) Cancel(id string) (Batch, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	batch, exists := p.batches[id]
+	if !exists {
+		return Batch{}, false
+	}
+	if batch.Status == "running" {
+		batch.Status = "cancelled"
+		batch.cancel()
+	}
+	return p.snapshotLocked(batch), true
+}
+
+// Retry reenvia os itens que falharam ou foram cancelados de um lote já concluído. Retorna
+// false em ok se o lote ainda está em andamento.
+func (p *BatchProcessor) Retry(ctx context.Context, id string) (batch Batch, exists, ok bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	b, exists := p.batches[id]
+	if !exists {
+		return Batch{}, false, false
+	}
+	// Um lote cancelado só pode ser reenviado depois que os itens em andamento terminarem
+	if b.Status == "running" || b.running > 0 {
+		return p.snapshotLocked(b), true, false
+	}
+
+	var retry []*BatchItem
+	for _, item := range b.items {
+		if item.Status == "failed" || item.Status == "cancelled" {
+			item.Status, item.Error = "pending", nil
+			retry = append(retry, item)
+		}
+	}
+	if len(retry) == 0 {
+		return p.snapshotLocked(b), true, true
+	}
+
+	batchCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	b.Status, b.CompletedAt, b.cancel, b.running = "running", nil, cancel, len(retry)
+	go p.run(batchCtx, b, retry)
+	return p.snapshotLocked(b), true, true
+}
+
+// Get retorna o lote e uma cópia dos itens
+func (p *BatchProcessor) Get(id string) (Batch, []BatchItem, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	batch, exists := p.batches[id]
+	if !exists {
+		return Batch{}, nil, false
+	}
+	items := make([]BatchItem, len(batch.items))
+	for i, item := range batch.items {
+		items[i] = *item
+	}
+	return p.snapshotLocked(batch), items, true
+}
+
+// List retorna os lotes, do mais recente para o mais antigo
+func (p *BatchProcessor) List() []Batch {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	batches := make([]Batch, 0, len(p.order))
+	for i := len(p.order) - 1; i >= 0; i-- {
+		batches = append(batches, p.snapshotLocked(p.batches[p.order[i]]))
+	}
+	return batches
+}
+
+// snapshotLocked copia o lote com as contagens atualizadas. Deve ser chamada com o mutex travado.
+func (p *BatchProcessor) snapshotLocked(batch *Batch) Batch {
+	snapshot := *batch
+	snapshot.items, snapshot.cancel = nil, nil
+	snapshot.Counts = BatchCounts{Total: len(batch.items)}
+	for _, item := range batch.items {
+		switch item.Status {
+		case "pending":
+			snapshot.Counts.Pending++
+		case "running":
+			snapshot.Counts.Running++
+		case "completed":
+			snapshot.Counts.Completed++
+		case "failed":
+			snapshot.Counts.Failed++
+		case "cancelled":
+			snapshot.Counts.Cancelled++
+		}
+	}
+	return snapshot
+}
+
+// evictLocked descarta os lotes concluídos mais antigos acima de maxBatches. Deve ser chamada
+// com o mutex travado.
+func (p *BatchProcessor) evictLocked() {
+	for i := 0; len(p.order) > maxBatches && i < len(p.order); {
+		if p.batches[p.order[i]].Status == "running" {
+			i++
+			continue
+		}
+		delete(p.batches, p.order[i])
+		p.order = append(p.order[:i], p.order[i+1:]...)
+	}
+}
@@ -0,0 +1,269 @@
+package handlers
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"mime"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/chatcomStackspotAI/llm"
+	"github.com/chatcomStackspotAI/models"
+	"go.uber.org/zap"
+)
+
+// maxBatchBodySize limita o arquivo JSONL enviado a /api/batches
+const maxBatchBodySize = 10 << 20
+
+// batchInput é uma linha do arquivo JSONL de entrada
+type batchInput struct {
+	CustomID   string                 `json:"custom_id"`
+	Provider   string                 `json:"provider"`
+	Model      string                 `json:"model"`
+	Prompt     string                 `json:"prompt"`
+	History    []models.Message       `json:"history"`
+	Parameters map[string]interface{} `json:"parameters"` // temperature e max_tokens
+}
+
+// batchDetail é a resposta de GET /api/batches/{id}: o lote e o estado de cada item, sem as respostas
+type batchDetail struct {
+	Batch
+	Items []batchItemStatus `json:"items"`
+}
+
+type batchItemStatus struct {
+	Index     int              `json:"index"`
+	CustomID  string           `json:"custom_id,omitempty"`
+	Status    string           `json:"status"`
+	Attempts  int              `json:"attempts"`
+	Error     *models.APIError `json:"error,omitempty"`
+	LatencyMs int64            `json:"latency_ms,omitempty"`
+
+	IgnoredParameters []string `json:"ignored_parameters,omitempty"`
+}
+
+// RegisterBatchRoutes registra as rotas de /api/batches no mux
+func RegisterBatchRoutes(mux *http.ServeMux, batches *BatchProcessor, manager *llm.LLMManager, logger *zap.Logger) {
+	mux.HandleFunc("POST /api/batches", createBatchHandler(batches, manager, logger))
+	mux.HandleFunc("GET /api/batches", listBatchesHandler(batches))
+	mux.HandleFunc("GET /api/batches/{id}", getBatchHandler(batches))
+	mux.HandleFunc("GET /api/batches/{id}/results", batchResultsHandler(batches))
+	mux.HandleFunc("POST /api/batches/{id}/cancel", cancelBatchHandler(batches))
+	mux.HandleFunc("POST /api/batches/{id}/retry", retryBatchHandler(batches))
+}
+
+// createBatchHandler recebe um JSONL com um prompt por linha. Os parâmetros provider, model e
+// concurrency da URL valem para as linhas que não os informarem.
+func createBatchHandler(batches *BatchProcessor, manager *llm.LLMManager, logger *zap.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		concurrency := 0
+		if value := query.Get("concurrency"); value != "" {
+			var err error
+			if concurrency, err = strconv.Atoi(value); err != nil || concurrency < 1 {
+				WriteError(w, r, http.StatusBadRequest, ErrCodeInvalidRequest, "concurrency deve ser um inteiro positivo")
+				return
+			}
+		}
+
+		items, lines, err := parseBatchItems(http.MaxBytesReader(w, r.Body, maxBatchBodySize), query.Get("provider"), query.Get("model"), batches.maxItems)
+		if err != nil {
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				WriteError(w, r, http.StatusRequestEntityTooLarge, ErrCodeInvalidRequest, "Arquivo maior que o limite de 10MB")
+				return
+			}
+			WriteError(w, r, http.StatusBadRequest, ErrCodeInvalidRequest, err.Error())
+			return
+		}
+
+		// Provedores e modelos inválidos são recusados antes de o lote começar
+		for i, item := range items {
+			if _, err := manager.GetClientWithModel(r.Context(), item.Provider, item.Model); err != nil {
+				logger.Warn("Lote com provedor inválido", zap.Int("line", lines[i]), zap.Error(err))
+				apiErr, status := NewLLMAPIError(err, "")
+				WriteError(w, r, status, apiErr.Code, fmt.Sprintf("Linha %d: %s", lines[i], apiErr.Message))
+				return
+			}
+		}
+
+		batch := batches.Submit(r.Context(), items, concurrency)
+		w.Header().Set("Location", "/api/batches/"+batch.ID)
+		writeJSON(w, http.StatusAccepted, batch)
+	}
+}
+
+// parseBatchItems lê o JSONL e retorna também a linha de cada item; linhas em branco são
+// ignoradas e a primeira linha inválida recusa o lote inteiro
+func parseBatchItems(body io.Reader, provider, model string, maxItems int) ([]*BatchItem, []int, error) {
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 64*1024), maxBatchBodySize)
+
+	var items []*BatchItem
+	var lines []int
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+
+		var input batchInput
+		if err := json.Unmarshal([]byte(text), &input); err != nil {
+			return nil, nil, fmt.Errorf("Linha %d: JSON inválido: %v", line, err)
+		}
+		if input.Prompt == "" {
+			return nil, nil, fmt.Errorf("Linha %d: prompt não fornecido", line)
+		}
+		item := &BatchItem{
+			Index:    len(items),
+			CustomID: input.CustomID,
+			Provider: input.Provider,
+			Model:    input.Model,
+			prompt:   input.Prompt,
+			history:  input.History,
+		}
+		if item.Provider == "" {
+			item.Provider, item.Model = provider, model
+		}
+		if item.Provider == "" {
+			return nil, nil, fmt.Errorf("Linha %d: provider não fornecido", line)
+		}
+		options, err := parseBatchParameters(input.Parameters)
+		if err == nil {
+			// Os intervalos dependem do provedor da linha (ex.: a ClaudeAI aceita temperature até 1)
+			err = llm.ValidateGenerationOptions(item.Provider, options)
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("Linha %d: %v", line, err)
+		}
+		item.options = options
+		if len(input.Parameters) > 0 && !llm.SupportsGenerationOptions(item.Provider) {
+			// O item é processado, mas o resultado indica que os parâmetros não foram aplicados
+			for name := range input.Parameters {
+				item.IgnoredParameters = append(item.IgnoredParameters, name)
+			}
+			sort.Strings(item.IgnoredParameters)
+		}
+
+		items, lines = append(items, item), append(lines, line)
+		if len(items) > maxItems {
+			return nil, nil, fmt.Errorf("O lote excede o limite de %d itens", maxItems)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, nil, err
+	}
+	if len(items) == 0 {
+		return nil, nil, errors.New("Nenhum prompt encontrado; envie um JSON por linha")
+	}
+	return items, lines, nil
+}
+
+// parseBatchParameters converte os parâmetros de uma linha nas opções de geração; nomes
+// desconhecidos e valores de tipo inválido recusam a linha
+func parseBatchParameters(parameters map[string]interface{}) (llm.GenerationOptions, error) {
+	var options llm.GenerationOptions
+	for name, value := range parameters {
+		number, isNumber := value.(float64)
+		switch name {
+		case "temperature":
+			if !isNumber {
+				return options, errors.New("temperature deve ser um número")
+			}
+			options.Temperature = &number
+		case "max_tokens":
+			if !isNumber || number < 1 || number != math.Trunc(number) {
+				return options, errors.New("max_tokens deve ser um inteiro positivo")
+			}
+			maxTokens := int(number)
+			options.MaxTokens = &maxTokens
+		default:
+			return options, fmt.Errorf("parâmetro desconhecido '%s'; use temperature ou max_tokens", name)
+		}
+	}
+	return options, nil
+}
+
+func listBatchesHandler(batches *BatchProcessor) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, listResponse{Data: batches.List()})
+	}
+}
+
+func getBatchHandler(batches *BatchProcessor) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		batch, items, exists := batches.Get(r.PathValue("id"))
+		if !exists {
+			WriteError(w, r, http.StatusNotFound, ErrCodeNotFound, "Lote não encontrado")
+			return
+		}
+
+		detail := batchDetail{Batch: batch, Items: make([]batchItemStatus, len(items))}
+		for i, item := range items {
+			detail.Items[i] = batchItemStatus{
+				Index:     item.Index,
+				CustomID:  item.CustomID,
+				Status:    item.Status,
+				Attempts:  item.Attempts,
+				Error:     item.Error,
+				LatencyMs: item.LatencyMs,
+
+				IgnoredParameters: item.IgnoredParameters,
+			}
+		}
+		writeJSON(w, http.StatusOK, detail)
+	}
+}
+
+// batchResultsHandler devolve um JSONL com uma linha por item, na ordem da entrada. Com o lote
+// em andamento, os itens ainda não concluídos aparecem com o status atual.
+func batchResultsHandler(batches *BatchProcessor) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		batch, items, exists := batches.Get(r.PathValue("id"))
+		if !exists {
+			WriteError(w, r, http.StatusNotFound, ErrCodeNotFound, "Lote não encontrado")
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{
+			"filename": "batch-" + batch.ID + ".jsonl",
+		}))
+		encoder := json.NewEncoder(w)
+		for _, item := range items {
+			encoder.Encode(item)
+		}
+	}
+}
+
+func cancelBatchHandler(batches *BatchProcessor) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		batch, exists := batches.Cancel(r.PathValue("id"))
+		if !exists {
+			WriteError(w, r, http.StatusNotFound, ErrCodeNotFound, "Lote não encontrado")
+			return
+		}
+		writeJSON(w, http.StatusOK, batch)
+	}
+}
+
+// retryBatchHandler reenvia os itens que falharam ou foram cancelados
+func retryBatchHandler(batches *BatchProcessor) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		batch, exists, ok := batches.Retry(r.Context(), r.PathValue("id"))
+		switch {
+		case !exists:
+			WriteError(w, r, http.StatusNotFound, ErrCodeNotFound, "Lote não encontrado")
+		case !ok:
+			WriteError(w, r, http.StatusConflict, ErrCodeInvalidRequest, "O lote ainda está em andamento")
+		default:
+			writeJSON(w, http.StatusAccepted, batch)
+		}
+	}
+}
@@ -0,0 +1,131 @@
+package handlers
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/chatcomStackspotAI/llm/llmtest"
+	"go.uber.org/zap"
+)
+
+func TestParseBatchParameters(t *testing.T) {
+	options, err := parseBatchParameters(map[string]interface{}{"temperature": 0.2, "max_tokens": float64(128)})
+	if err != nil {
+		t.Fatalf("parseBatchParameters: %v", err)
+	}
+	if options.Temperature == nil || *options.Temperature != 0.2 || options.MaxTokens == nil || *options.MaxTokens != 128 {
+		t.Fatalf("opções = %+v", options)
+	}
+
+	invalid := []map[string]interface{}{
+		{"top_p": 0.9},
+		{"temperature": "alta"},
+		{"max_tokens": 0.0},
+		{"max_tokens": 10.5},
+	}
+	for _, parameters := range invalid {
+		if _, err := parseBatchParameters(parameters); err == nil {
+			t.Errorf("parâmetros %v deveriam ser recusados", parameters)
+		}
+	}
+}
+
+func TestParseBatchItemsProviderRanges(t *testing.T) {
+	cases := []struct {
+		line  string
+		valid bool
+	}{
+		{`{"provider":"OPENAI","prompt":"oi","parameters":{"temperature":1.5}}`, true},
+		{`{"provider":"OPENAI","prompt":"oi","parameters":{"temperature":2.5}}`, false},
+		{`{"provider":"CLAUDEAI","prompt":"oi","parameters":{"temperature":0.7}}`, true},
+		{`{"provider":"CLAUDEAI","prompt":"oi","parameters":{"temperature":1.5}}`, false},
+		{`{"provider":"CLAUDEAI","prompt":"oi","parameters":{"temperature":-0.1}}`, false},
+		{`{"prompt":"oi","parameters":{"temperature":1.5}}`, false}, // Provedor da URL
+	}
+	for _, tc := range cases {
+		// A linha inválida vem depois de uma válida, e o erro indica a linha
+		input := `{"provider":"OPENAI","prompt":"oi"}` + "\n" + tc.line
+		_, _, err := parseBatchItems(strings.NewReader(input), "CLAUDEAI", "", 10)
+		if tc.valid && err != nil {
+			t.Errorf("%s: %v", tc.line, err)
+		}
+		if !tc.valid && (err == nil || !strings.Contains(err.Error(), "Linha 2")) {
+			t.Errorf("%s: erro = %v, esperado recusar a linha 2", tc.line, err)
+		}
+	}
+}
+
+func TestBatchParameters(t *testing.T) {
+	fake := llmtest.NewServer()
+	defer fake.Close()
+	manager := newTestManager(t, fake)
+	logger := zap.NewNop()
+
+	mux := http.NewServeMux()
+	RegisterBatchRoutes(mux, NewBatchProcessor(manager, BatchConfig{}, logger), manager, logger)
+
+	// Um parâmetro desconhecido recusa o lote, indicando a linha
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/batches",
+		strings.NewReader(`{"provider":"OPENAI","prompt":"oi"}`+"\n"+`{"provider":"OPENAI","prompt":"oi","parameters":{"top_p":0.5}}`)))
+	if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), "Linha 2") {
+		t.Fatalf("status = %d: %s", rec.Code, rec.Body)
+	}
+
+	input := strings.Join([]string{
+		`{"custom_id":"openai","provider":"OPENAI","prompt":"oi","parameters":{"temperature":0.3,"max_tokens":64}}`,
+		`{"custom_id":"claude","provider":"CLAUDEAI","prompt":"oi","parameters":{"max_tokens":32}}`,
+		`{"custom_id":"spot","provider":"SPOT","prompt":"oi","parameters":{"temperature":0.3}}`,
+	}, "\n")
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/batches", strings.NewReader(input)))
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("status = %d: %s", rec.Code, rec.Body)
+	}
+	var batch Batch
+	json.NewDecoder(rec.Body).Decode(&batch)
+
+	deadline := time.Now().Add(10 * time.Second)
+	for batch.Status == "running" && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+		rec = httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/batches/"+batch.ID, nil))
+		json.NewDecoder(rec.Body).Decode(&batch)
+	}
+	if batch.Status != "completed" || batch.Counts.Completed != 3 {
+		t.Fatalf("lote = %+v", batch)
+	}
+
+	// Os parâmetros chegam aos provedores que os suportam
+	openAIBody := fake.Requests(llmtest.RouteOpenAIChat)[0].Body
+	if !strings.Contains(openAIBody, `"temperature":0.3`) || !strings.Contains(openAIBody, `"max_tokens":64`) {
+		t.Errorf("corpo enviado à OpenAI sem os parâmetros: %s", openAIBody)
+	}
+	if body := fake.Requests(llmtest.RouteAnthropicMessages)[0].Body; !strings.Contains(body, `"max_tokens":32`) {
+		t.Errorf("corpo enviado à Anthropic sem max_tokens: %s", body)
+	}
+
+	// E o resultado indica os que o provedor não aplicou
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/batches/"+batch.ID+"/results", nil))
+	ignored := make(map[string][]string)
+	scanner := bufio.NewScanner(rec.Body)
+	for scanner.Scan() {
+		var item BatchItem
+		if err := json.Unmarshal(scanner.Bytes(), &item); err != nil {
+			t.Fatalf("linha de resultado inválida: %v", err)
+		}
+		ignored[item.CustomID] = item.IgnoredParameters
+	}
+	if len(ignored["openai"]) != 0 || len(ignored["claude"]) != 0 {
+		t.Errorf("parâmetros ignorados em provedores que os suportam: %v", ignored)
+	}
+	if len(ignored["spot"]) != 1 || ignored["spot"][0] != "temperature" {
+		t.Errorf("ignored_parameters da StackSpot = %v", ignored["spot"])
+	}
+}
@@ -0,0 +1,353 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/chatcomStackspotAI/llm"
+	"github.com/chatcomStackspotAI/middlewares"
+	"github.com/chatcomStackspotAI/models"
+	"github.com/chatcomStackspotAI/tracing"
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+	"go.uber.org/zap"
+)
+
+// maxComparisons é o número de comparações mantidas em memória para a escolha da vencedora
+const maxComparisons = 100
+
+// CompareTarget é um provedor, e opcionalmente o modelo, incluído na comparação
+type CompareTarget struct {
+	Provider string `json:"provider"`
+	Model    string `json:"model,omitempty"`
+}
+
+// CompareResult é a resposta de um dos provedores comparados
+type CompareResult struct {
+	Index     int              `json:"index"`
+	Provider  string           `json:"provider"`
+	Model     string           `json:"model"`
+	Status    string           `json:"status"` // "pending", "completed" ou "failed"
+	Content   string           `json:"content,omitempty"`
+	Usage     *llm.Usage       `json:"usage,omitempty"`
+	Error     *models.APIError `json:"error,omitempty"`
+	LatencyMs int64            `json:"latency_ms,omitempty"`
+}
+
+// Comparison é o mesmo prompt respondido por vários provedores, criado em POST /api/v1/compare
+type Comparison struct {
+	ID             string          `json:"id"`
+	ConversationID string          `json:"conversation_id,omitempty"`
+	Prompt         string          `json:"prompt"`
+	Status         string          `json:"status"` // "running" ou "completed"
+	Results        []CompareResult `json:"results"`
+	Winner         *int            `json:"winner,omitempty"` // Índice do resultado escolhido
+	CreatedAt      time.Time       `json:"created_at"`
+	CompletedAt    *time.Time      `json:"completed_at,omitempty"`
+}
+
+// Erros da escolha da resposta vencedora
+var (
+	errComparisonNotFound = errors.New("comparação não encontrada")
+	errInvalidWinner      = errors.New("índice fora da lista de resultados")
+	errWinnerNotCompleted = errors.New("o resultado escolhido não foi concluído")
+	errWinnerChosen       = errors.New("a resposta vencedora já foi escolhida")
+)
+
+// comparisonStore guarda as comparações recentes em memória
+type comparisonStore struct {
+	mu          sync.Mutex
+	comparisons map[string]*Comparison
+	order       []string // IDs das comparações, da mais antiga para a mais recente
+}
+
+func newComparisonStore() *comparisonStore {
+	return &comparisonStore{comparisons: make(map[string]*Comparison)}
+}
+
+// add registra a comparação e descarta as concluídas mais antigas acima de maxComparisons
+func (s *comparisonStore) add(comparison *Comparison) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.comparisons[comparison.ID] = comparison
+	s.order = append(s.order, comparison.ID)
+	for i := 0; len(s.order) > maxComparisons && i < len(s.order); {
+		if s.comparisons[s.order[i]].Status == "running" {
+			i++
+			continue
+		}
+		delete(s.comparisons, s.order[i])
+		s.order = append(s.order[:i], s.order[i+1:]...)
+	}
+}
+
+// setResult grava o resultado de um provedor e conclui a comparação quando ele é o último
+func (s *comparisonStore) setResult(id string, result CompareResult) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	comparison, exists := s.comparisons[id]
+	if !exists {
+		return
+	}
+	comparison.Results[result.Index] = result
+	for _, r := range comparison.Results {
+		if r.Status == "pending" {
+			return
+		}
+	}
+	now := time.Now().UTC()
+	comparison.Status, comparison.CompletedAt = "completed", &now
+}
+
+func (s *comparisonStore) get(id string) (Comparison, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	comparison, exists := s.comparisons[id]
+	if !exists {
+		return Comparison{}, false
+	}
+	return copyComparison(comparison), true
+}
+
+// chooseWinner marca o resultado escolhido; a escolha só pode ser feita uma vez
+func (s *comparisonStore) chooseWinner(id string, index int) (Comparison, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	comparison, exists := s.comparisons[id]
+	switch {
+	case !exists:
+		return Comparison{}, errComparisonNotFound
+	case index < 0 || index >= len(comparison.Results):
+		return Comparison{}, errInvalidWinner
+	case comparison.Winner != nil:
+		return Comparison{}, errWinnerChosen
+	case comparison.Results[index].Status != "completed":
+		return Comparison{}, errWinnerNotCompleted
+	}
+	comparison.Winner = &index
+	return copyComparison(comparison), nil
+}
+
+func copyComparison(comparison *Comparison) Comparison {
+	c := *comparison
+	c.Results = append([]CompareResult(nil), comparison.Results...)
+	return c
+}
+
+// resolveTargets obtém o cliente de cada provedor e os resultados pendentes correspondentes.
+// Em caso de erro, responde indicando o item inválido (ex.: "targets[1]") e retorna false.
+func resolveTargets(w http.ResponseWriter, r *http.Request, manager *llm.LLMManager, targets []CompareTarget,
+	field string, logger *zap.Logger) ([]llm.LLMClient, []CompareResult, bool) {
+	clients := make([]llm.LLMClient, len(targets))
+	pending := make([]CompareResult, len(targets))
+	for i, target := range targets {
+		client, err := manager.GetClientWithModel(r.Context(), target.Provider, target.Model)
+		if err != nil {
+			logger.Warn("Provedor inválido na requisição", zap.String("field", field), zap.Int("index", i), zap.Error(err))
+			apiErr, status := NewLLMAPIError(err, "")
+			WriteError(w, r, status, apiErr.Code, fmt.Sprintf("%s[%d]: %s", field, i, apiErr.Message))
+			return nil, nil, false
+		}
+		clients[i] = client
+		pending[i] = CompareResult{Index: i, Provider: target.Provider, Model: client.GetModelName(), Status: "pending"}
+	}
+	return clients, pending, true
+}
+
+// runTargets envia o prompt a todos os clientes em paralelo. Cada resultado é passado a
+// onResult e depois ao canal retornado, na ordem em que os provedores terminam.
+func runTargets(ctx context.Context, clients []llm.LLMClient, pending []CompareResult, prompt string, history []models.Message,
+	onResult func(CompareResult), logger *zap.Logger) <-chan CompareResult {
+	results := make(chan CompareResult, len(clients))
+	for i, client := range clients {
+		go func(result CompareResult, client llm.LLMClient) {
+			ctx, span := tracing.Start(ctx, "compare.target",
+				attribute.Int("compare.index", result.Index),
+				attribute.String("llm.provider", result.Provider))
+			start := time.Now()
+			content, usage, err := runCompletion(ctx, client, prompt, history)
+			tracing.End(span, err)
+
+			result.LatencyMs = time.Since(start).Milliseconds()
+			if err != nil {
+				logger.Warn("Falha em um dos provedores consultados em paralelo",
+					zap.String("provider", result.Provider), zap.Error(err))
+				result.Status = "failed"
+				result.Error, _ = NewLLMAPIError(err, middlewares.RequestIDFromContext(ctx))
+			} else {
+				result.Status, result.Content, result.Usage = "completed", content, &usage
+			}
+			if onResult != nil {
+				onResult(result)
+			}
+			results <- result
+		}(pending[i], client)
+	}
+	return results
+}
+
+// compareV1Handler envia o prompt a todos os provedores ao mesmo tempo. Com "stream": true, cada
+// resultado é enviado como server-sent event assim que fica pronto; sem ele, a resposta traz
+// todos os resultados quando o último terminar.
+func compareV1Handler(manager *llm.LLMManager, conversations *ConversationStore, comparisons *comparisonStore, logger *zap.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, span := tracing.Start(r.Context(), "CompareV1Handler")
+		defer span.End()
+
+		var data struct {
+			Prompt         string           `json:"prompt"`
+			History        []models.Message `json:"history"`
+			ConversationID string           `json:"conversation_id"`
+			Targets        []CompareTarget  `json:"targets"`
+			Stream         bool             `json:"stream"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+			WriteError(w, r, http.StatusBadRequest, ErrCodeInvalidRequest, "Dados inválidos")
+			return
+		}
+		span.SetAttributes(
+			attribute.Int("compare.targets", len(data.Targets)),
+			attribute.String("conversation_id", data.ConversationID))
+
+		history := data.History
+		if data.ConversationID != "" {
+			conversation, exists := conversations.Get(data.ConversationID)
+			if !exists {
+				WriteError(w, r, http.StatusNotFound, ErrCodeNotFound, "Conversa não encontrada")
+				return
+			}
+			history = conversation.Messages
+		}
+
+		clients, pending, ok := resolveTargets(w, r, manager, data.Targets, "targets", logger)
+		if !ok {
+			return
+		}
+		comparison := &Comparison{
+			ID:             uuid.New().String(),
+			ConversationID: data.ConversationID,
+			Prompt:         data.Prompt,
+			Status:         "running",
+			Results:        pending,
+			CreatedAt:      time.Now().UTC(),
+		}
+		comparisons.add(comparison)
+		snapshot, _ := comparisons.get(comparison.ID)
+
+		// As respostas continuam sendo geradas se o cliente desconectar e podem ser consultadas
+		// depois em GET /api/v1/compare/{id}
+		results := runTargets(context.WithoutCancel(ctx), clients, snapshot.Results, data.Prompt, history, func(result CompareResult) {
+			comparisons.setResult(comparison.ID, result)
+		}, logger)
+
+		extendWriteDeadline(w, completionTimeout+10*time.Second, logger)
+
+		if !data.Stream {
+			for range clients {
+				<-results
+			}
+			final, _ := comparisons.get(comparison.ID)
+			writeJSON(w, http.StatusOK, final)
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("Connection", "keep-alive")
+		w.WriteHeader(http.StatusOK)
+		controller := http.NewResponseController(w)
+		send := func(event string, value interface{}) error {
+			payload, err := json.Marshal(value)
+			if err != nil {
+				return err
+			}
+			if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, payload); err != nil {
+				return err
+			}
+			return controller.Flush()
+		}
+
+		if err := send("comparison", snapshot); err != nil {
+			return
+		}
+		for range clients {
+			select {
+			case result := <-results:
+				if err := send("result", result); err != nil {
+					return
+				}
+			case <-r.Context().Done():
+				return
+			}
+		}
+		final, _ := comparisons.get(comparison.ID)
+		send("done", final)
+	}
+}
+
+func getComparisonV1Handler(comparisons *comparisonStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		comparison, exists := comparisons.get(r.PathValue("id"))
+		if !exists {
+			WriteError(w, r, http.StatusNotFound, ErrCodeNotFound, "Comparação não encontrada")
+			return
+		}
+		writeJSON(w, http.StatusOK, comparison)
+	}
+}
+
+// chooseWinnerV1Handler marca a resposta escolhida e, se a comparação foi feita em uma conversa,
+// acrescenta o prompt e essa resposta a ela
+func chooseWinnerV1Handler(conversations *ConversationStore, comparisons *comparisonStore, logger *zap.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var data struct {
+			Index int `json:"index"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+			WriteError(w, r, http.StatusBadRequest, ErrCodeInvalidRequest, "Dados inválidos")
+			return
+		}
+
+		id := r.PathValue("id")
+		if current, exists := comparisons.get(id); exists && current.ConversationID != "" {
+			if _, exists := conversations.Get(current.ConversationID); !exists {
+				WriteError(w, r, http.StatusNotFound, ErrCodeNotFound, "Conversa não encontrada")
+				return
+			}
+		}
+
+		comparison, err := comparisons.chooseWinner(id, data.Index)
+		switch {
+		case errors.Is(err, errComparisonNotFound):
+			WriteError(w, r, http.StatusNotFound, ErrCodeNotFound, "Comparação não encontrada")
+			return
+		case errors.Is(err, errWinnerChosen):
+			WriteError(w, r, http.StatusConflict, ErrCodeInvalidRequest, "A resposta vencedora já foi escolhida")
+			return
+		case err != nil:
+			WriteError(w, r, http.StatusBadRequest, ErrCodeInvalidRequest, "Resultado inválido: "+err.Error())
+			return
+		}
+
+		winner := comparison.Results[data.Index]
+		if comparison.ConversationID != "" {
+			conversations.AppendMessages(comparison.ConversationID,
+				models.Message{Role: "user", Content: comparison.Prompt},
+				models.Message{Role: "assistant", Content: winner.Content, Provider: winner.Provider, Model: winner.Model})
+		}
+		logger.Info("Resposta vencedora escolhida",
+			zap.String("comparison_id", comparison.ID),
+			zap.String("provider", winner.Provider),
+			zap.String("model", winner.Model))
+		writeJSON(w, http.StatusOK, comparison)
+	}
+}
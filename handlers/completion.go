@@ -0,0 +1,31 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/chatcomStackspotAI/llm"
+	"github.com/chatcomStackspotAI/models"
+	"go.uber.org/zap"
+)
+
+// completionTimeout é o prazo máximo de uma chamada ao provedor, incluindo novas tentativas
+const completionTimeout = 5 * time.Minute
+
+// runCompletion envia o prompt ao provedor e retorna a resposta com o consumo de tokens
+func runCompletion(ctx context.Context, client llm.LLMClient, prompt string, history []models.Message) (string, llm.Usage, error) {
+	ctx, cancel := context.WithTimeout(ctx, completionTimeout)
+	defer cancel()
+
+	ctx, usage := llm.WithUsage(ctx)
+	response, err := client.SendPrompt(ctx, prompt, history)
+	return response, usage(), err
+}
+
+// extendWriteDeadline permite que respostas síncronas ultrapassem o WriteTimeout do servidor
+func extendWriteDeadline(w http.ResponseWriter, d time.Duration, logger *zap.Logger) {
+	if err := http.NewResponseController(w).SetWriteDeadline(time.Now().Add(d)); err != nil {
+		logger.Warn("Não foi possível estender o prazo de escrita da resposta", zap.Error(err))
+	}
+}
@@ -0,0 +1,146 @@
+// handlers/conversation_store.go
+
+package handlers
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/chatcomStackspotAI/models"
+	"github.com/google/uuid"
+)
+
+const defaultConversationTitle = "Nova conversa"
+
+type ConversationStore struct {
+	mu            sync.RWMutex
+	conversations map[string]*models.Conversation
+}
+
+func NewConversationStore() *ConversationStore {
+	return &ConversationStore{
+		conversations: make(map[string]*models.Conversation),
+	}
+}
+
+// Create cria uma conversa vazia com o título informado
+func (store *ConversationStore) Create(title string) models.Conversation {
+	if title == "" {
+		title = defaultConversationTitle
+	}
+	now := time.Now().UTC()
+	conversation := &models.Conversation{
+		ID:        uuid.New().String(),
+		Title:     title,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	store.conversations[conversation.ID] = conversation
+	return *conversation
+}
+
+// Import cria uma conversa com as mensagens informadas, preservando o título e as datas
+// originais; a conversa sempre recebe um novo ID
+func (store *ConversationStore) Import(source models.Conversation) models.Conversation {
+	now := time.Now().UTC()
+	conversation := &models.Conversation{
+		ID:        uuid.New().String(),
+		Title:     source.Title,
+		CreatedAt: source.CreatedAt.UTC(),
+		UpdatedAt: source.UpdatedAt.UTC(),
+		Messages:  append([]models.Message(nil), source.Messages...),
+	}
+	if conversation.Title == "" {
+		conversation.Title = defaultConversationTitle
+	}
+	if source.CreatedAt.IsZero() {
+		conversation.CreatedAt = now
+	}
+	if source.UpdatedAt.IsZero() {
+		conversation.UpdatedAt = conversation.CreatedAt
+	}
+
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	store.conversations[conversation.ID] = conversation
+	return copyConversation(conversation)
+}
+
+// List retorna as conversas sem as mensagens, da atualizada mais recentemente para a mais antiga
+func (store *ConversationStore) List() []models.Conversation {
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+
+	conversations := make([]models.Conversation, 0, len(store.conversations))
+	for _, conversation := range store.conversations {
+		summary := *conversation
+		summary.Messages = nil
+		conversations = append(conversations, summary)
+	}
+	sort.Slice(conversations, func(i, j int) bool {
+		return conversations[i].UpdatedAt.After(conversations[j].UpdatedAt)
+	})
+	return conversations
+}
+
+// Get retorna uma cópia da conversa com as mensagens
+func (store *ConversationStore) Get(id string) (models.Conversation, bool) {
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+
+	conversation, exists := store.conversations[id]
+	if !exists {
+		return models.Conversation{}, false
+	}
+	return copyConversation(conversation), true
+}
+
+// Rename altera o título da conversa
+func (store *ConversationStore) Rename(id, title string) (models.Conversation, bool) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	conversation, exists := store.conversations[id]
+	if !exists {
+		return models.Conversation{}, false
+	}
+	if title != "" {
+		conversation.Title = title
+		conversation.UpdatedAt = time.Now().UTC()
+	}
+	return copyConversation(conversation), true
+}
+
+// Delete remove a conversa e indica se ela existia
+func (store *ConversationStore) Delete(id string) bool {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	_, exists := store.conversations[id]
+	delete(store.conversations, id)
+	return exists
+}
+
+// AppendMessages acrescenta mensagens ao final da conversa
+func (store *ConversationStore) AppendMessages(id string, messages ...models.Message) bool {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	conversation, exists := store.conversations[id]
+	if !exists {
+		return false
+	}
+	conversation.Messages = append(conversation.Messages, messages...)
+	conversation.UpdatedAt = time.Now().UTC()
+	return true
+}
+
+func copyConversation(conversation *models.Conversation) models.Conversation {
+	clone := *conversation
+	clone.Messages = append([]models.Message(nil), conversation.Messages...)
+	return clone
+}
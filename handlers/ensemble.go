@@ -0,0 +1,170 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/chatcomStackspotAI/llm"
+	"github.com/chatcomStackspotAI/middlewares"
+	"github.com/chatcomStackspotAI/models"
+	"github.com/chatcomStackspotAI/tracing"
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+	"go.uber.org/zap"
+)
+
+// synthesisHeading separa a análise do juiz da resposta final
+const synthesisHeading = "## Resposta final"
+
+// Ensemble é a resposta de POST /api/v1/ensemble: os rascunhos de cada provedor e a síntese do juiz
+type Ensemble struct {
+	ID             string          `json:"id"`
+	ConversationID string          `json:"conversation_id,omitempty"`
+	Synthesis      string          `json:"synthesis"`          // Resposta final do juiz
+	Critique       string          `json:"critique,omitempty"` // Análise dos rascunhos feita pelo juiz
+	Judge          CompareResult   `json:"judge"`
+	Drafts         []CompareResult `json:"drafts"`
+	Usage          llm.Usage       `json:"usage"` // Soma dos rascunhos e do juiz
+	CreatedAt      time.Time       `json:"created_at"`
+}
+
+// ensembleV1Handler envia o prompt aos provedores em paralelo e pede ao juiz que critique os
+// rascunhos e os combine em uma única resposta. Os rascunhos que falharem ficam de fora da síntese.
+func ensembleV1Handler(manager *llm.LLMManager, conversations *ConversationStore, logger *zap.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, span := tracing.Start(r.Context(), "EnsembleV1Handler")
+		defer span.End()
+
+		var data struct {
+			Prompt         string           `json:"prompt"`
+			History        []models.Message `json:"history"`
+			ConversationID string           `json:"conversation_id"`
+			Drafters       []CompareTarget  `json:"drafters"`
+			Judge          CompareTarget    `json:"judge"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+			WriteError(w, r, http.StatusBadRequest, ErrCodeInvalidRequest, "Dados inválidos")
+			return
+		}
+		span.SetAttributes(
+			attribute.Int("ensemble.drafters", len(data.Drafters)),
+			attribute.String("ensemble.judge", data.Judge.Provider),
+			attribute.String("conversation_id", data.ConversationID))
+
+		history := data.History
+		if data.ConversationID != "" {
+			conversation, exists := conversations.Get(data.ConversationID)
+			if !exists {
+				WriteError(w, r, http.StatusNotFound, ErrCodeNotFound, "Conversa não encontrada")
+				return
+			}
+			history = conversation.Messages
+		}
+
+		clients, pending, ok := resolveTargets(w, r, manager, data.Drafters, "drafters", logger)
+		if !ok {
+			return
+		}
+		judgeClient, err := manager.GetClientWithModel(ctx, data.Judge.Provider, data.Judge.Model)
+		if err != nil {
+			apiErr, status := NewLLMAPIError(err, "")
+			WriteError(w, r, status, apiErr.Code, "judge: "+apiErr.Message)
+			return
+		}
+
+		// Os rascunhos e o juiz rodam em sequência, cada um com o seu próprio limite de tempo
+		extendWriteDeadline(w, 2*completionTimeout+10*time.Second, logger)
+
+		ensemble := Ensemble{
+			ID:             uuid.New().String(),
+			ConversationID: data.ConversationID,
+			Judge:          CompareResult{Provider: data.Judge.Provider, Model: judgeClient.GetModelName()},
+			Drafts:         pending,
+			CreatedAt:      time.Now().UTC(),
+		}
+		results := runTargets(ctx, clients, pending, data.Prompt, history, nil, logger)
+		for range clients {
+			result := <-results
+			ensemble.Drafts[result.Index] = result
+			if result.Usage != nil {
+				addUsage(&ensemble.Usage, *result.Usage)
+			}
+		}
+
+		judgePrompt, drafts := buildJudgePrompt(data.Prompt, ensemble.Drafts)
+		if drafts == 0 {
+			// Sem nenhum rascunho não há o que sintetizar; o erro é o do primeiro provedor
+			logger.Error("Todos os rascunhos do ensemble falharam", zap.String("ensemble_id", ensemble.ID))
+			first := ensemble.Drafts[0].Error
+			WriteError(w, r, llmErrorStatus[llm.ErrorCode(first.Code)], first.Code, "Nenhum provedor respondeu: "+first.Message)
+			return
+		}
+
+		judgeCtx, judgeSpan := tracing.Start(ctx, "ensemble.judge", attribute.String("llm.provider", data.Judge.Provider))
+		start := time.Now()
+		content, usage, err := runCompletion(judgeCtx, judgeClient, judgePrompt, history)
+		tracing.End(judgeSpan, err)
+		ensemble.Judge.LatencyMs = time.Since(start).Milliseconds()
+		if err != nil {
+			// Os rascunhos continuam na resposta para que possam ser aproveitados
+			logger.Error("Erro ao obter a síntese do juiz",
+				zap.String("request_id", middlewares.RequestIDFromContext(ctx)),
+				zap.Error(err))
+			ensemble.Judge.Status = "failed"
+			ensemble.Judge.Error, _ = NewLLMAPIError(err, middlewares.RequestIDFromContext(ctx))
+			writeJSON(w, http.StatusOK, ensemble)
+			return
+		}
+		ensemble.Judge.Status, ensemble.Judge.Usage = "completed", &usage
+		addUsage(&ensemble.Usage, usage)
+		ensemble.Critique, ensemble.Synthesis = splitJudgeResponse(content)
+
+		if data.ConversationID != "" {
+			conversations.AppendMessages(data.ConversationID,
+				models.Message{Role: "user", Content: data.Prompt},
+				models.Message{Role: "assistant", Content: ensemble.Synthesis, Provider: ensemble.Judge.Provider, Model: ensemble.Judge.Model})
+		}
+		writeJSON(w, http.StatusOK, ensemble)
+	}
+}
+
+// buildJudgePrompt monta o pedido ao juiz com os rascunhos concluídos e retorna quantos foram incluídos
+func buildJudgePrompt(prompt string, drafts []CompareResult) (string, int) {
+	var b strings.Builder
+	b.WriteString("Você é o juiz de um grupo de assistentes que responderam à mesma pergunta de forma independente. ")
+	b.WriteString("Analise as respostas: aponte erros, omissões e divergências entre elas. ")
+	b.WriteString("Depois, escreva uma resposta final única que combine o que cada uma tem de melhor e corrija o que estiver errado, ")
+	b.WriteString("sem mencionar os assistentes nem este processo.\n\n")
+	b.WriteString("Use exatamente este formato:\n## Análise\n<sua análise>\n" + synthesisHeading + "\n<a resposta final para o usuário>\n\n")
+	fmt.Fprintf(&b, "# Pergunta\n%s\n", prompt)
+
+	included := 0
+	for _, draft := range drafts {
+		if draft.Status != "completed" {
+			continue
+		}
+		included++
+		fmt.Fprintf(&b, "\n# Resposta do assistente %d\n%s\n", included, draft.Content)
+	}
+	return b.String(), included
+}
+
+// splitJudgeResponse separa a análise da resposta final, a partir do último título "## Resposta
+// final"; se o juiz não seguir o formato, a resposta inteira é usada como síntese
+func splitJudgeResponse(content string) (critique, synthesis string) {
+	i := strings.LastIndex(content, synthesisHeading)
+	if i < 0 {
+		return "", strings.TrimSpace(content)
+	}
+	critique = strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(content[:i]), "## Análise"))
+	return critique, strings.TrimSpace(content[i+len(synthesisHeading):])
+}
+
+func addUsage(total *llm.Usage, usage llm.Usage) {
+	total.PromptTokens += usage.PromptTokens
+	total.CompletionTokens += usage.CompletionTokens
+	total.TotalTokens += usage.TotalTokens
+}
@@ -0,0 +1,106 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/chatcomStackspotAI/llm"
+	"github.com/chatcomStackspotAI/middlewares"
+	"github.com/chatcomStackspotAI/models"
+)
+
+// Códigos de erro da própria API; as falhas dos provedores usam os códigos de llm.ErrorCode
+const (
+	ErrCodeInvalidRequest      = "invalid_request"
+	ErrCodeMethodNotAllowed    = "method_not_allowed"
+	ErrCodeNotFound            = "not_found"
+	ErrCodeUnsupportedProvider = "unsupported_provider"
+	ErrCodeInternal            = "internal_error"
+	ErrCodeInterrupted         = "interrupted"
+)
+
+// statusClientClosedRequest segue a convenção do nginx para requisições canceladas pelo cliente
+const statusClientClosedRequest = 499
+
+// Status HTTP correspondente a cada código de erro dos provedores
+var llmErrorStatus = map[llm.ErrorCode]int{
+	llm.ErrCodeAuthFailed:          http.StatusBadGateway,
+	llm.ErrCodeRateLimited:         http.StatusTooManyRequests,
+	llm.ErrCodeContextTooLong:      http.StatusBadRequest,
+	llm.ErrCodeProviderUnavailable: http.StatusServiceUnavailable,
+	llm.ErrCodeContentFiltered:     http.StatusUnprocessableEntity,
+	llm.ErrCodeTimeout:             http.StatusGatewayTimeout,
+	llm.ErrCodeCancelled:           statusClientClosedRequest,
+	llm.ErrCodeProviderError:       http.StatusBadGateway,
+}
+
+// errorEnvelope é o corpo JSON de todas as respostas de erro da API
+type errorEnvelope struct {
+	Error *models.APIError `json:"error"`
+}
+
+// WriteError responde com o envelope de erro padrão e o request ID da requisição
+func WriteError(w http.ResponseWriter, r *http.Request, status int, code, message string) {
+	writeAPIError(w, status, &models.APIError{
+		Code:      code,
+		Message:   message,
+		Retryable: status == http.StatusTooManyRequests || status >= http.StatusInternalServerError,
+		RequestID: middlewares.RequestIDFromContext(r.Context()),
+	})
+}
+
+// WriteLLMError responde com o erro de um provedor, sem expor a causa original
+func WriteLLMError(w http.ResponseWriter, r *http.Request, err error) {
+	apiErr, status := NewLLMAPIError(err, middlewares.RequestIDFromContext(r.Context()))
+
+	setRetryAfter(w, err)
+	writeAPIError(w, status, apiErr)
+}
+
+// setRetryAfter informa quando o circuito do provedor volta a aceitar chamadas
+func setRetryAfter(w http.ResponseWriter, err error) {
+	var openErr *llm.CircuitOpenError
+	if errors.As(err, &openErr) {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(openErr.RetryIn.Seconds()))))
+	}
+}
+
+// NewLLMAPIError converte o erro de um provedor no corpo de erro da API e no status HTTP correspondente
+func NewLLMAPIError(err error, requestID string) (*models.APIError, int) {
+	if errors.Is(err, llm.ErrUnsupportedProvider) {
+		return &models.APIError{
+			Code:      ErrCodeUnsupportedProvider,
+			Message:   "O provedor de LLM selecionado não está configurado.",
+			RequestID: requestID,
+		}, http.StatusBadRequest
+	}
+
+	if errors.Is(err, llm.ErrInvalidModel) {
+		return &models.APIError{
+			Code:      ErrCodeInvalidRequest,
+			Message:   "O modelo informado é inválido.",
+			RequestID: requestID,
+		}, http.StatusBadRequest
+	}
+
+	var llmErr *llm.Error
+	if !errors.As(err, &llmErr) {
+		llmErr = llm.NewError(llm.ErrCodeProviderError, "", err)
+	}
+	return &models.APIError{
+		Code:      string(llmErr.Code),
+		Message:   llmErr.UserMessage(),
+		Retryable: llmErr.Retryable(),
+		RequestID: requestID,
+	}, llmErrorStatus[llmErr.Code]
+}
+
+func writeAPIError(w http.ResponseWriter, status int, apiErr *models.APIError) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(errorEnvelope{Error: apiErr})
+}
@@ -0,0 +1,150 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"mime"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/chatcomStackspotAI/models"
+	"github.com/chatcomStackspotAI/web"
+	"github.com/yuin/goldmark"
+	highlighting "github.com/yuin/goldmark-highlighting/v2"
+	"github.com/yuin/goldmark/extension"
+)
+
+// Formatos aceitos em GET /api/v1/conversations/{id}/export?format=
+const (
+	ExportFormatMarkdown = "markdown"
+	ExportFormatJSON     = "json"
+	ExportFormatHTML     = "html"
+)
+
+// exportMarkdown renderiza o markdown das mensagens no servidor, para que a página exportada
+// não dependa de scripts nem de CDNs. O HTML bruto das mensagens é descartado e os blocos de
+// código recebem o destaque de sintaxe em estilos inline.
+var exportMarkdown = goldmark.New(
+	goldmark.WithExtensions(
+		extension.GFM,
+		highlighting.NewHighlighting(highlighting.WithStyle("github")),
+	),
+)
+
+// exportTemplate gera a página HTML autossuficiente
+var exportTemplate = template.Must(template.New("export.html").Funcs(template.FuncMap{
+	"roleLabel": roleLabel,
+	"markdown":  renderMarkdown,
+}).ParseFS(web.FS, "templates/export.html"))
+
+func exportConversationV1Handler(conversations *ConversationStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		conversation, exists := conversations.Get(r.PathValue("id"))
+		if !exists {
+			WriteError(w, r, http.StatusNotFound, ErrCodeNotFound, "Conversa não encontrada")
+			return
+		}
+
+		format := r.URL.Query().Get("format")
+		if format == "" {
+			format = ExportFormatMarkdown
+		}
+
+		var body bytes.Buffer
+		var contentType, extension string
+		switch format {
+		case ExportFormatMarkdown:
+			contentType, extension = "text/markdown; charset=utf-8", ".md"
+			writeMarkdownExport(&body, conversation)
+		case ExportFormatJSON:
+			// O JSON é o mesmo formato aceito pela importação
+			contentType, extension = "application/json", ".json"
+			encoded, err := json.MarshalIndent(conversation, "", "  ")
+			if err != nil {
+				WriteError(w, r, http.StatusInternalServerError, ErrCodeInternal, "Erro ao gerar a exportação")
+				return
+			}
+			body.Write(encoded)
+		case ExportFormatHTML:
+			contentType, extension = "text/html; charset=utf-8", ".html"
+			err := exportTemplate.Execute(&body, map[string]interface{}{
+				"Conversation": conversation,
+				"ExportedAt":   time.Now().UTC(),
+			})
+			if err != nil {
+				WriteError(w, r, http.StatusInternalServerError, ErrCodeInternal, "Erro ao gerar a exportação")
+				return
+			}
+		default:
+			WriteError(w, r, http.StatusBadRequest, ErrCodeInvalidRequest, fmt.Sprintf("formato de exportação desconhecido: %q", format))
+			return
+		}
+
+		w.Header().Set("Content-Type", contentType)
+		w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{
+			"filename": exportFilename(conversation.Title) + extension,
+		}))
+		w.Write(body.Bytes())
+	}
+}
+
+// writeMarkdownExport escreve a conversa em Markdown, pronta para colar em um PR ou wiki
+func writeMarkdownExport(out *bytes.Buffer, conversation models.Conversation) {
+	fmt.Fprintf(out, "# %s\n\n", conversation.Title)
+	fmt.Fprintf(out, "_Criada em %s · %d mensagens_\n",
+		conversation.CreatedAt.Format("02/01/2006 15:04 MST"), len(conversation.Messages))
+
+	for _, message := range conversation.Messages {
+		fmt.Fprintf(out, "\n---\n\n### %s\n\n%s\n", roleLabel(message), strings.TrimSpace(message.Content))
+	}
+}
+
+// renderMarkdown converte o conteúdo da mensagem em HTML seguro; se a conversão falhar, o
+// texto é exibido sem formatação
+func renderMarkdown(content string) template.HTML {
+	var out bytes.Buffer
+	if err := exportMarkdown.Convert([]byte(content), &out); err != nil {
+		return template.HTML("<pre>" + template.HTMLEscapeString(content) + "</pre>")
+	}
+	return template.HTML(out.String())
+}
+
+// roleLabel descreve o autor da mensagem, incluindo o provedor e o modelo das respostas
+func roleLabel(message models.Message) string {
+	switch message.Role {
+	case "user":
+		return "Usuário"
+	case "system":
+		return "Sistema"
+	}
+
+	label := "Assistente"
+	switch {
+	case message.Provider != "" && message.Model != "":
+		label += " (" + message.Provider + " · " + message.Model + ")"
+	case message.Provider != "" || message.Model != "":
+		label += " (" + message.Provider + message.Model + ")"
+	}
+	return label
+}
+
+// exportFilename deriva o nome do arquivo a partir do título da conversa
+func exportFilename(title string) string {
+	name := strings.Map(func(r rune) rune {
+		switch r {
+		case '/', '\\', ':', '*', '?', '"', '<', '>', '|':
+			return '-'
+		}
+		if r < ' ' {
+			return -1
+		}
+		return r
+	}, strings.TrimSpace(title))
+	if name == "" {
+		return "conversa"
+	}
+	return name
+}
@@ -0,0 +1,44 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/chatcomStackspotAI/models"
+)
+
+func TestExportHTMLIsSelfContained(t *testing.T) {
+	conversations := NewConversationStore()
+	conversation := conversations.Create("Revisão")
+	conversations.AppendMessages(conversation.ID,
+		models.Message{Role: "user", Content: "Como somo em **Go**? <script>alert(1)</script> [clique](javascript:alert(1))"},
+		models.Message{Role: "assistant", Provider: "MOCK", Model: "mock", Content: "Assim:\n\n```go\nfunc soma(a, b int) int { return a + b }\n```\n\n| a | b |\n|---|---|\n| 1 | 2 |"},
+	)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/v1/conversations/{id}/export", exportConversationV1Handler(conversations))
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/conversations/"+conversation.ID+"/export?format=html", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", rec.Code, rec.Body)
+	}
+	page := rec.Body.String()
+
+	for _, forbidden := range []string{"<script", "javascript:", "<link", "src=\"http", "cdn"} {
+		if strings.Contains(page, forbidden) {
+			t.Errorf("a página exportada contém %q", forbidden)
+		}
+	}
+	for _, expected := range []string{
+		"<strong>Go</strong>", // Markdown renderizado no servidor
+		"<table>",             // Tabelas do GFM
+		`<span style="color:#000;font-weight:bold">func</span>`, // Destaque de sintaxe com estilos inline
+		"Assistente (MOCK · mock)",
+	} {
+		if !strings.Contains(page, expected) {
+			t.Errorf("a página exportada não contém %q", expected)
+		}
+	}
+}
@@ -1,15 +1,22 @@
 package handlers
 
 import (
+	"context"
 	"encoding/json"
+	"github.com/chatcomStackspotAI/models"
 	"go.uber.org/zap"
 	"net/http"
+	"strconv"
+	"time"
 )
 
-func GetResponseHandler(store *ResponseStore, logger *zap.Logger) http.HandlerFunc {
+// maxResponseWait limita o parâmetro wait de /get-response
+const maxResponseWait = 60 * time.Second
+
+func GetResponseHandler(store ResponseStore, logger *zap.Logger) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != "GET" {
-			http.Error(w, "Método não suportado", http.StatusMethodNotAllowed)
+			WriteError(w, r, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "Método não suportado")
 			return
 		}
 
@@ -19,14 +26,36 @@ func GetResponseHandler(store *ResponseStore, logger *zap.Logger) http.HandlerFu
 
 		// Verificar se ambos foram fornecidos
 		if messageID == "" || sessionID == "" {
-			http.Error(w, "message_id ou session_id não fornecido", http.StatusBadRequest)
+			WriteError(w, r, http.StatusBadRequest, ErrCodeInvalidRequest, "message_id ou session_id não fornecido")
+			return
+		}
+
+		// Com wait (ex.: wait=30s), a requisição aguarda até que a mensagem seja concluída
+		wait, ok := parseWait(r.URL.Query().Get("wait"))
+		if !ok {
+			WriteError(w, r, http.StatusBadRequest, ErrCodeInvalidRequest, "wait inválido: use uma duração como 30s")
 			return
 		}
 
 		// Obter a resposta da store
-		data, exists := store.GetResponse(sessionID, messageID)
+		var data *models.ResponseData
+		var exists bool
+		var err error
+		if wait > 0 {
+			extendWriteDeadline(w, wait+10*time.Second, logger)
+			ctx, cancel := context.WithTimeout(r.Context(), wait)
+			data, exists, err = store.Wait(ctx, sessionID, messageID)
+			cancel()
+		} else {
+			data, exists, err = store.GetResponse(r.Context(), sessionID, messageID)
+		}
+		if err != nil {
+			logger.Error("Erro ao obter a resposta", zap.Error(err))
+			WriteError(w, r, http.StatusInternalServerError, ErrCodeInternal, "Não foi possível obter a resposta")
+			return
+		}
 		if !exists {
-			http.Error(w, "message_id não encontrado", http.StatusNotFound)
+			WriteError(w, r, http.StatusNotFound, ErrCodeNotFound, "message_id não encontrado")
 			return
 		}
 
@@ -35,3 +64,23 @@ func GetResponseHandler(store *ResponseStore, logger *zap.Logger) http.HandlerFu
 		json.NewEncoder(w).Encode(data)
 	}
 }
+
+// parseWait interpreta o parâmetro wait como uma duração ("30s", "1m") ou em segundos ("30").
+// Vazio desativa a espera; valores acima de maxResponseWait são limitados a ele.
+func parseWait(value string) (time.Duration, bool) {
+	if value == "" {
+		return 0, true
+	}
+	wait, err := time.ParseDuration(value)
+	if err != nil {
+		seconds, convErr := strconv.Atoi(value)
+		if convErr != nil {
+			return 0, false
+		}
+		wait = time.Duration(seconds) * time.Second
+	}
+	if wait < 0 {
+		return 0, false
+	}
+	return min(wait, maxResponseWait), true
+}
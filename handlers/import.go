@@ -0,0 +1,241 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/chatcomStackspotAI/llm"
+	"github.com/chatcomStackspotAI/models"
+	"go.uber.org/zap"
+)
+
+// maxImportSize limita o corpo da importação; o conversations.json do ChatGPT cresce rápido
+const maxImportSize = 20 << 20
+
+// chatGPTConversation é uma conversa do conversations.json exportado pelo ChatGPT. As mensagens
+// formam uma árvore (cada edição cria um ramo); current_node aponta para o ramo exibido.
+type chatGPTConversation struct {
+	Title       string                 `json:"title"`
+	CreateTime  float64                `json:"create_time"`
+	UpdateTime  float64                `json:"update_time"`
+	CurrentNode string                 `json:"current_node"`
+	Mapping     map[string]chatGPTNode `json:"mapping"`
+}
+
+type chatGPTNode struct {
+	Parent   string   `json:"parent"`
+	Children []string `json:"children"`
+	Message  *struct {
+		Author struct {
+			Role string `json:"role"`
+		} `json:"author"`
+		Content struct {
+			ContentType string            `json:"content_type"`
+			Parts       []json.RawMessage `json:"parts"`
+		} `json:"content"`
+		Metadata struct {
+			ModelSlug string `json:"model_slug"`
+		} `json:"metadata"`
+	} `json:"message"`
+}
+
+func importConversationsV1Handler(conversations *ConversationStore, logger *zap.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// O corpo já chega limitado a maxImportSize por validated (apiBodyLimits)
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				WriteError(w, r, http.StatusRequestEntityTooLarge, ErrCodeInvalidRequest, "Arquivo de importação muito grande")
+				return
+			}
+			WriteError(w, r, http.StatusBadRequest, ErrCodeInvalidRequest, "Erro ao ler o arquivo de importação")
+			return
+		}
+
+		sources, err := parseImport(body)
+		if err != nil {
+			WriteError(w, r, http.StatusBadRequest, ErrCodeInvalidRequest, err.Error())
+			return
+		}
+
+		imported := make([]models.Conversation, 0, len(sources))
+		for _, source := range sources {
+			imported = append(imported, conversations.Import(source))
+		}
+		logger.Info("Conversas importadas", zap.Int("count", len(imported)))
+
+		writeJSON(w, http.StatusCreated, listResponse{Data: imported})
+	}
+}
+
+// parseImport aceita uma conversa exportada em JSON por este servidor, uma conversa do ChatGPT
+// ou o conversations.json completo do ChatGPT (uma lista de conversas)
+func parseImport(body []byte) ([]models.Conversation, error) {
+	body = bytes.TrimSpace(body)
+	if len(body) == 0 {
+		return nil, errors.New("corpo da importação vazio")
+	}
+
+	var raw []json.RawMessage
+	if body[0] == '[' {
+		if err := json.Unmarshal(body, &raw); err != nil {
+			return nil, fmt.Errorf("JSON inválido: %w", err)
+		}
+	} else {
+		raw = []json.RawMessage{body}
+	}
+
+	conversations := make([]models.Conversation, 0, len(raw))
+	for i, item := range raw {
+		conversation, err := parseImportedConversation(item)
+		if err != nil {
+			if len(raw) > 1 {
+				return nil, fmt.Errorf("conversa %d: %w", i+1, err)
+			}
+			return nil, err
+		}
+		conversations = append(conversations, conversation)
+	}
+	if len(conversations) == 0 {
+		return nil, errors.New("nenhuma conversa para importar")
+	}
+	return conversations, nil
+}
+
+func parseImportedConversation(data json.RawMessage) (models.Conversation, error) {
+	var probe struct {
+		Mapping json.RawMessage `json:"mapping"`
+	}
+	if err := json.Unmarshal(data, &probe); err != nil {
+		return models.Conversation{}, fmt.Errorf("JSON inválido: %w", err)
+	}
+	if probe.Mapping != nil {
+		var conversation chatGPTConversation
+		if err := json.Unmarshal(data, &conversation); err != nil {
+			return models.Conversation{}, fmt.Errorf("conversa do ChatGPT inválida: %w", err)
+		}
+		return conversation.toConversation()
+	}
+
+	var conversation models.Conversation
+	if err := json.Unmarshal(data, &conversation); err != nil {
+		return models.Conversation{}, fmt.Errorf("conversa inválida: %w", err)
+	}
+	for i, message := range conversation.Messages {
+		switch message.Role {
+		case "user", "assistant", "system":
+		default:
+			return models.Conversation{}, fmt.Errorf("mensagem %d: papel %q inválido", i+1, message.Role)
+		}
+	}
+	return conversation, nil
+}
+
+// toConversation segue o ramo atual, de current_node até a raiz, e mantém apenas as
+// mensagens de texto do usuário e do assistente
+func (c chatGPTConversation) toConversation() (models.Conversation, error) {
+	node := c.CurrentNode
+	if _, exists := c.Mapping[node]; !exists {
+		node = c.lastNodeOfFirstBranch()
+	}
+
+	var messages []models.Message
+	for visited := 0; node != "" && visited <= len(c.Mapping); visited++ {
+		current, exists := c.Mapping[node]
+		if !exists {
+			break
+		}
+		if message, ok := current.toMessage(); ok {
+			messages = append(messages, message)
+		}
+		node = current.Parent
+	}
+	if len(messages) == 0 {
+		return models.Conversation{}, errors.New("a conversa do ChatGPT não tem mensagens de texto")
+	}
+
+	// O caminho foi percorrido da folha para a raiz
+	for i, j := 0, len(messages)-1; i < j; i, j = i+1, j-1 {
+		messages[i], messages[j] = messages[j], messages[i]
+	}
+
+	return models.Conversation{
+		Title:     c.Title,
+		CreatedAt: unixSeconds(c.CreateTime),
+		UpdatedAt: unixSeconds(c.UpdateTime),
+		Messages:  messages,
+	}, nil
+}
+
+// lastNodeOfFirstBranch é usado quando current_node está ausente: parte da raiz e segue
+// sempre o primeiro filho
+func (c chatGPTConversation) lastNodeOfFirstBranch() string {
+	ids := make([]string, 0, len(c.Mapping))
+	for id := range c.Mapping {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	node := ""
+	for _, id := range ids {
+		if _, hasParent := c.Mapping[c.Mapping[id].Parent]; !hasParent {
+			node = id
+			break
+		}
+	}
+	for visited := 0; visited <= len(c.Mapping); visited++ {
+		children := c.Mapping[node].Children
+		if len(children) == 0 {
+			break
+		}
+		node = children[0]
+	}
+	return node
+}
+
+func (n chatGPTNode) toMessage() (models.Message, bool) {
+	if n.Message == nil || n.Message.Content.ContentType != "text" {
+		return models.Message{}, false
+	}
+	role := n.Message.Author.Role
+	if role != "user" && role != "assistant" {
+		return models.Message{}, false
+	}
+
+	// Partes que não são texto (imagens, anexos) são ignoradas
+	var parts []string
+	for _, raw := range n.Message.Content.Parts {
+		var part string
+		if json.Unmarshal(raw, &part) == nil && strings.TrimSpace(part) != "" {
+			parts = append(parts, part)
+		}
+	}
+	if len(parts) == 0 {
+		return models.Message{}, false
+	}
+
+	message := models.Message{Role: role, Content: strings.Join(parts, "\n\n")}
+	if role == "assistant" {
+		message.Provider = llm.ProviderOpenAI
+		message.Model = n.Message.Metadata.ModelSlug
+	}
+	return message, true
+}
+
+// unixSeconds converte os timestamps do ChatGPT (segundos com fração)
+func unixSeconds(seconds float64) time.Time {
+	if seconds <= 0 {
+		return time.Time{}
+	}
+	whole, fraction := math.Modf(seconds)
+	return time.Unix(int64(whole), int64(fraction*1e9)).UTC()
+}
@@ -0,0 +1,39 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/chatcomStackspotAI/llm"
+	"go.uber.org/zap"
+)
+
+// ModelsHandler mantém o formato legado de /api/models; novos clientes devem usar /api/v1/models
+func ModelsHandler(manager *llm.LLMManager, logger *zap.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "GET" {
+			WriteError(w, r, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "Método não suportado")
+			return
+		}
+
+		models := map[string]string{
+			"openai":  "",
+			"claude":  "",
+			"default": "spot-default",
+		}
+		for _, info := range manager.Models() {
+			switch info.Provider {
+			case llm.ProviderOpenAI:
+				models["openai"] = info.Model
+			case llm.ProviderClaudeAI:
+				models["claude"] = info.Model
+			}
+		}
+
+		w.Header().Set("Link", `</api/v1/models>; rel="successor-version"`)
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(models); err != nil {
+			logger.Error("Erro ao serializar os modelos", zap.Error(err))
+		}
+	}
+}
@@ -0,0 +1,368 @@
+package handlers
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/chatcomStackspotAI/llm"
+	"github.com/chatcomStackspotAI/middlewares"
+	"github.com/chatcomStackspotAI/models"
+	"github.com/chatcomStackspotAI/tracing"
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+	"go.uber.org/zap"
+)
+
+// Prefixo usado em /v1/models para cada provedor, aceito de volta por llm.ResolveModel
+var openAIModelPrefixes = map[string]string{
+	llm.ProviderOpenAI:    "openai",
+	llm.ProviderClaudeAI:  "claude",
+	llm.ProviderStackSpot: "spot",
+	llm.ProviderMock:      "mock",
+}
+
+// openAIChatRequest contém os campos da API de chat completions da OpenAI usados pelo proxy;
+// temperature e max_tokens são repassados aos provedores que os aplicam, e os demais parâmetros
+// de amostragem (top_p etc.) são aceitos e ignorados
+type openAIChatRequest struct {
+	Model       string              `json:"model"`
+	Messages    []openAIChatMessage `json:"messages"`
+	Stream      bool                `json:"stream"`
+	Temperature *float64            `json:"temperature"`
+	MaxTokens   *int                `json:"max_tokens"`
+	// Com include_usage, o último chunk do streaming traz o consumo de tokens
+	StreamOptions *struct {
+		IncludeUsage bool `json:"include_usage"`
+	} `json:"stream_options"`
+}
+
+type openAIChatMessage struct {
+	Role    string          `json:"role"`
+	Content json.RawMessage `json:"content"`
+}
+
+// text aceita o conteúdo como string ou como lista de partes {"type": "text", "text": ...}
+func (m openAIChatMessage) text() (string, error) {
+	var content string
+	if err := json.Unmarshal(m.Content, &content); err == nil {
+		return content, nil
+	}
+
+	var parts []struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	}
+	if err := json.Unmarshal(m.Content, &parts); err != nil {
+		return "", fmt.Errorf("conteúdo da mensagem em formato não suportado")
+	}
+	var builder strings.Builder
+	for _, part := range parts {
+		if part.Type != "text" {
+			return "", fmt.Errorf("partes do tipo %q não são suportadas", part.Type)
+		}
+		builder.WriteString(part.Text)
+	}
+	return builder.String(), nil
+}
+
+type openAIChoice struct {
+	Index        int                `json:"index"`
+	Message      *openAIChoiceDelta `json:"message,omitempty"`
+	Delta        *openAIChoiceDelta `json:"delta,omitempty"`
+	FinishReason *string            `json:"finish_reason"`
+}
+
+type openAIChoiceDelta struct {
+	Role    string `json:"role,omitempty"`
+	Content string `json:"content,omitempty"`
+}
+
+type openAIChatResponse struct {
+	ID      string         `json:"id"`
+	Object  string         `json:"object"`
+	Created int64          `json:"created"`
+	Model   string         `json:"model"`
+	Choices []openAIChoice `json:"choices"`
+	Usage   *llm.Usage     `json:"usage,omitempty"`
+}
+
+// OpenAIChatCompletionsHandler expõe POST /v1/chat/completions no formato da OpenAI, com e sem
+// streaming, encaminhando para o provedor indicado pelo nome do modelo (ex.: "spot/<slug>")
+func OpenAIChatCompletionsHandler(manager *llm.LLMManager, logger *zap.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !authorizeOpenAICompat(w, r) {
+			return
+		}
+
+		ctx, span := tracing.Start(r.Context(), "OpenAIChatCompletionsHandler")
+		defer span.End()
+
+		var data openAIChatRequest
+		if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+			writeOpenAIError(w, r, http.StatusBadRequest, "invalid_request_error", ErrCodeInvalidRequest, "Dados inválidos")
+			return
+		}
+		if data.Model == "" || len(data.Messages) == 0 {
+			writeOpenAIError(w, r, http.StatusBadRequest, "invalid_request_error", ErrCodeInvalidRequest, "model e messages são obrigatórios")
+			return
+		}
+
+		prompt, history, err := splitOpenAIMessages(data.Messages)
+		if err != nil {
+			writeOpenAIError(w, r, http.StatusBadRequest, "invalid_request_error", ErrCodeInvalidRequest, err.Error())
+			return
+		}
+
+		provider, model, err := llm.ResolveModel(data.Model)
+		if err != nil {
+			writeOpenAIError(w, r, http.StatusNotFound, "invalid_request_error", "model_not_found",
+				fmt.Sprintf("O modelo '%s' não existe ou não está configurado", data.Model))
+			return
+		}
+		span.SetAttributes(
+			attribute.String("llm.provider", provider),
+			attribute.String("llm.requested_model", data.Model),
+			attribute.Bool("stream", data.Stream))
+
+		client, err := manager.GetClientWithModel(ctx, provider, model)
+		if err != nil {
+			logger.Error("Erro ao obter o cliente LLM", zap.Error(err))
+			writeOpenAILLMError(w, r, err)
+			return
+		}
+
+		options := llm.GenerationOptions{Temperature: data.Temperature, MaxTokens: data.MaxTokens}
+		if err := llm.ValidateGenerationOptions(provider, options); err != nil {
+			writeOpenAIError(w, r, http.StatusBadRequest, "invalid_request_error", ErrCodeInvalidRequest, err.Error())
+			return
+		}
+		// A StackSpot e o MOCK não aplicam as opções de geração; nelas os parâmetros são ignorados
+		if llm.SupportsGenerationOptions(provider) {
+			ctx = llm.WithGenerationOptions(ctx, options)
+		}
+
+		extendWriteDeadline(w, completionTimeout+10*time.Second, logger)
+
+		response := openAIChatResponse{
+			ID:      "chatcmpl-" + uuid.New().String(),
+			Created: time.Now().Unix(),
+			Model:   data.Model,
+		}
+
+		if data.Stream {
+			includeUsage := data.StreamOptions != nil && data.StreamOptions.IncludeUsage
+			streamOpenAIChat(ctx, w, r, client, prompt, history, response, includeUsage, logger)
+			return
+		}
+
+		content, usage, err := runCompletion(ctx, client, prompt, history)
+		if err != nil {
+			logger.Error("Erro ao obter a resposta da LLM",
+				zap.String("request_id", middlewares.RequestIDFromContext(ctx)),
+				zap.Error(err))
+			span.RecordError(err)
+			writeOpenAILLMError(w, r, err)
+			return
+		}
+
+		stop := "stop"
+		response.Object = "chat.completion"
+		response.Choices = []openAIChoice{{
+			Message:      &openAIChoiceDelta{Role: "assistant", Content: content},
+			FinishReason: &stop,
+		}}
+		response.Usage = &usage
+		writeJSON(w, http.StatusOK, response)
+	}
+}
+
+// streamOpenAIChat envia a resposta como server-sent events no formato chat.completion.chunk
+func streamOpenAIChat(ctx context.Context, w http.ResponseWriter, r *http.Request, client llm.LLMClient, prompt string, history []models.Message,
+	response openAIChatResponse, includeUsage bool, logger *zap.Logger) {
+	ctx, cancel := context.WithTimeout(ctx, completionTimeout)
+	defer cancel()
+	ctx, usage := llm.WithUsage(ctx)
+	controller := http.NewResponseController(w)
+	started := false
+
+	send := func(choice *openAIChoice, usage *llm.Usage) error {
+		if !started {
+			w.Header().Set("Content-Type", "text/event-stream")
+			w.Header().Set("Cache-Control", "no-cache")
+			w.Header().Set("Connection", "keep-alive")
+			w.WriteHeader(http.StatusOK)
+			started = true
+		}
+
+		chunk := response
+		chunk.Object = "chat.completion.chunk"
+		chunk.Choices = []openAIChoice{}
+		if choice != nil {
+			chunk.Choices = append(chunk.Choices, *choice)
+		}
+		chunk.Usage = usage
+
+		payload, err := json.Marshal(chunk)
+		if err != nil {
+			return err
+		}
+		if _, err := fmt.Fprintf(w, "data: %s\n\n", payload); err != nil {
+			return err
+		}
+		return controller.Flush()
+	}
+
+	// O papel é enviado no primeiro chunk, como faz a OpenAI
+	first := true
+	_, err := llm.Stream(ctx, client, prompt, history, func(text string) error {
+		delta := &openAIChoiceDelta{Content: text}
+		if first {
+			delta.Role = "assistant"
+			first = false
+		}
+		return send(&openAIChoice{Delta: delta}, nil)
+	})
+	if err != nil {
+		logger.Error("Erro durante o streaming da resposta",
+			zap.String("request_id", middlewares.RequestIDFromContext(r.Context())),
+			zap.Error(err))
+		if !started {
+			writeOpenAILLMError(w, r, err)
+			return
+		}
+		// Depois do início do streaming, o erro segue como um evento no mesmo formato da OpenAI
+		apiErr, _ := NewLLMAPIError(err, middlewares.RequestIDFromContext(r.Context()))
+		payload, _ := json.Marshal(map[string]interface{}{"error": openAIErrorBody(apiErr, "server_error")})
+		fmt.Fprintf(w, "data: %s\n\n", payload)
+		controller.Flush()
+		return
+	}
+
+	stop := "stop"
+	if err := send(&openAIChoice{Delta: &openAIChoiceDelta{}, FinishReason: &stop}, nil); err != nil {
+		return
+	}
+	if includeUsage {
+		total := usage()
+		if err := send(nil, &total); err != nil {
+			return
+		}
+	}
+	fmt.Fprint(w, "data: [DONE]\n\n")
+	controller.Flush()
+}
+
+// splitOpenAIMessages separa a última mensagem do usuário (o prompt) do histórico anterior
+func splitOpenAIMessages(messages []openAIChatMessage) (string, []models.Message, error) {
+	last := messages[len(messages)-1]
+	if last.Role != "user" {
+		return "", nil, fmt.Errorf("a última mensagem deve ter role \"user\"")
+	}
+	prompt, err := last.text()
+	if err != nil {
+		return "", nil, err
+	}
+
+	history := make([]models.Message, 0, len(messages)-1)
+	for _, message := range messages[:len(messages)-1] {
+		content, err := message.text()
+		if err != nil {
+			return "", nil, err
+		}
+		history = append(history, models.Message{Role: message.Role, Content: content})
+	}
+	return prompt, history, nil
+}
+
+// OpenAIModelsHandler expõe GET /v1/models no formato da OpenAI, com os nomes aceitos pelo proxy
+func OpenAIModelsHandler(manager *llm.LLMManager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !authorizeOpenAICompat(w, r) {
+			return
+		}
+
+		type openAIModel struct {
+			ID      string `json:"id"`
+			Object  string `json:"object"`
+			Created int64  `json:"created"`
+			OwnedBy string `json:"owned_by"`
+		}
+		data := []openAIModel{}
+		for _, info := range manager.Models() {
+			prefix, ok := openAIModelPrefixes[info.Provider]
+			if !ok {
+				continue
+			}
+			data = append(data, openAIModel{
+				ID:      prefix + "/" + info.Model,
+				Object:  "model",
+				OwnedBy: strings.ToLower(info.Provider),
+			})
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"object": "list",
+			"data":   data,
+		})
+	}
+}
+
+// authorizeOpenAICompat exige "Authorization: Bearer <OPENAI_COMPAT_API_KEY>" quando a variável está definida
+func authorizeOpenAICompat(w http.ResponseWriter, r *http.Request) bool {
+	apiKey := os.Getenv("OPENAI_COMPAT_API_KEY")
+	if apiKey == "" {
+		return true
+	}
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if subtle.ConstantTimeCompare([]byte(token), []byte(apiKey)) == 1 {
+		return true
+	}
+	writeOpenAIError(w, r, http.StatusUnauthorized, "authentication_error", "invalid_api_key", "Chave de API inválida")
+	return false
+}
+
+// Tipo de erro da OpenAI correspondente a cada código de erro dos provedores
+var openAIErrorTypes = map[string]string{
+	string(llm.ErrCodeAuthFailed):      "server_error",
+	string(llm.ErrCodeRateLimited):     "rate_limit_error",
+	string(llm.ErrCodeContextTooLong):  "invalid_request_error",
+	string(llm.ErrCodeContentFiltered): "invalid_request_error",
+	ErrCodeUnsupportedProvider:         "invalid_request_error",
+}
+
+// openAIErrorBody segue o formato de erro da OpenAI, mantendo o request ID e o flag retryable da API
+func openAIErrorBody(apiErr *models.APIError, errType string) map[string]interface{} {
+	return map[string]interface{}{
+		"message":    apiErr.Message,
+		"type":       errType,
+		"code":       apiErr.Code,
+		"param":      nil,
+		"retryable":  apiErr.Retryable,
+		"request_id": apiErr.RequestID,
+	}
+}
+
+func writeOpenAIError(w http.ResponseWriter, r *http.Request, status int, errType, code, message string) {
+	writeJSON(w, status, map[string]interface{}{
+		"error": openAIErrorBody(&models.APIError{
+			Code:      code,
+			Message:   message,
+			RequestID: middlewares.RequestIDFromContext(r.Context()),
+		}, errType),
+	})
+}
+
+func writeOpenAILLMError(w http.ResponseWriter, r *http.Request, err error) {
+	apiErr, status := NewLLMAPIError(err, middlewares.RequestIDFromContext(r.Context()))
+	errType, ok := openAIErrorTypes[apiErr.Code]
+	if !ok {
+		errType = "server_error"
+	}
+	setRetryAfter(w, err)
+	writeJSON(w, status, map[string]interface{}{"error": openAIErrorBody(apiErr, errType)})
+}
@@ -0,0 +1,62 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/chatcomStackspotAI/llm/llmtest"
+	"go.uber.org/zap"
+)
+
+func TestOpenAICompatGenerationOptions(t *testing.T) {
+	fake := llmtest.NewServer()
+	defer fake.Close()
+	handler := OpenAIChatCompletionsHandler(newTestManager(t, fake), zap.NewNop())
+
+	post := func(body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		handler(rec, httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(body)))
+		return rec
+	}
+
+	rec := post(`{"model":"gpt-4o","temperature":0.4,"max_tokens":50,"messages":[{"role":"user","content":"oi"}]}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("OpenAI: status %d: %s", rec.Code, rec.Body)
+	}
+	body := fake.Requests(llmtest.RouteOpenAIChat)[0].Body
+	if !strings.Contains(body, `"temperature":0.4`) || !strings.Contains(body, `"max_tokens":50`) {
+		t.Errorf("corpo enviado à OpenAI sem os parâmetros: %s", body)
+	}
+
+	// No streaming, as opções também chegam ao provedor
+	rec = post(`{"model":"claude-3-5-sonnet-latest","stream":true,"max_tokens":20,"messages":[{"role":"user","content":"oi"}]}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("ClaudeAI: status %d: %s", rec.Code, rec.Body)
+	}
+	if body := fake.Requests(llmtest.RouteAnthropicMessages)[0].Body; !strings.Contains(body, `"max_tokens":20`) {
+		t.Errorf("corpo enviado à Anthropic sem max_tokens: %s", body)
+	}
+
+	// Nos provedores que não aplicam as opções, elas são ignoradas
+	rec = post(`{"model":"spot/meu-agente","temperature":0.4,"messages":[{"role":"user","content":"oi"}]}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("StackSpot: status %d: %s", rec.Code, rec.Body)
+	}
+
+	// Os intervalos dependem do provedor: a Anthropic só aceita temperature até 1
+	for _, invalid := range []string{
+		`"model":"gpt-4o","temperature":2.5`,
+		`"model":"gpt-4o","max_tokens":0`,
+		`"model":"claude-3-5-sonnet-latest","temperature":1.5`,
+	} {
+		rec = post(`{` + invalid + `,"messages":[{"role":"user","content":"oi"}]}`)
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: status %d, esperado 400", invalid, rec.Code)
+		}
+	}
+	if n := len(fake.Requests(llmtest.RouteAnthropicMessages)); n != 1 {
+		t.Errorf("requisições à Anthropic = %d, esperado 1", n)
+	}
+}
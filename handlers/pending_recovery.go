@@ -0,0 +1,150 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/chatcomStackspotAI/llm"
+	"github.com/chatcomStackspotAI/models"
+	"github.com/chatcomStackspotAI/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.uber.org/zap"
+)
+
+// PendingExecution é uma mensagem de /send que ainda aguarda a resposta do provedor, guardada
+// no ResponseStore ao lado da resposta. ExecutionID fica vazio até que o provedor crie a execução
+// (só os provedores assíncronos, como a StackSpot, informam um ID que permite retomá-la).
+type PendingExecution struct {
+	SessionID   string    `json:"session_id"`
+	MessageID   string    `json:"message_id"`
+	RequestID   string    `json:"request_id,omitempty"`
+	Provider    string    `json:"provider"`
+	Model       string    `json:"model,omitempty"`
+	ExecutionID string    `json:"execution_id,omitempty"`
+	CallbackURL string    `json:"callback_url,omitempty"` // Recebe o resultado quando a mensagem termina
+	StartedAt   time.Time `json:"started_at"`
+}
+
+// sortPending ordena as mensagens pendentes da mais antiga para a mais recente
+func sortPending(executions []PendingExecution) {
+	sort.Slice(executions, func(i, j int) bool {
+		return executions[i].StartedAt.Before(executions[j].StartedAt)
+	})
+}
+
+// ResumePendingExecutions é chamada na inicialização: as mensagens que estavam em andamento
+// voltam ao ResponseStore como "processing", e as execuções com ID no provedor voltam a ser
+// consultadas em background. As demais são concluídas com erro, para que a interface pare de
+// consultar /get-response e o usuário possa reenviar a mensagem. Só um store compartilhado,
+// como o RedisResponseStore, mantém as mensagens pendentes entre reinicializações.
+func ResumePendingExecutions(ctx context.Context, manager *llm.LLMManager, store ResponseStore, webhooks *WebhookDispatcher, logger *zap.Logger) {
+	executions, err := store.ListPending(ctx)
+	if err != nil {
+		logger.Error("Não foi possível ler as execuções pendentes", zap.Error(err))
+		return
+	}
+	if len(executions) == 0 {
+		return
+	}
+	logger.Info("Retomando execuções pendentes", zap.Int("count", len(executions)))
+
+	for _, execution := range executions {
+		err := store.SetResponse(ctx, execution.SessionID, execution.MessageID, &models.ResponseData{
+			Status: "processing",
+		})
+		if err != nil {
+			logger.Warn("Não foi possível restaurar a mensagem pendente", zap.String("message_id", execution.MessageID), zap.Error(err))
+		}
+
+		if execution.ExecutionID == "" {
+			completeMessage(ctx, store, webhooks, execution, "", errInterrupted, logger)
+			continue
+		}
+
+		go resumeExecution(ctx, manager, store, webhooks, execution, logger)
+	}
+}
+
+// errInterrupted indica que o servidor parou antes de o provedor criar a execução
+var errInterrupted = errors.New("a solicitação foi interrompida pela reinicialização do servidor")
+
+func resumeExecution(ctx context.Context, manager *llm.LLMManager, store ResponseStore, webhooks *WebhookDispatcher, execution PendingExecution, logger *zap.Logger) {
+	ctx, span := tracing.Start(ctx, "ResumePendingExecution",
+		attribute.String("message_id", execution.MessageID),
+		attribute.String("llm.provider", execution.Provider),
+		attribute.String("execution_id", execution.ExecutionID))
+
+	ctx, cancel := context.WithTimeout(ctx, completionTimeout)
+	defer cancel()
+
+	var response string
+	client, err := manager.GetClient(ctx, execution.Provider, execution.Model)
+	if err == nil {
+		response, err = llm.Resume(ctx, client, execution.ExecutionID)
+	}
+	if errors.Is(err, llm.ErrNotResumable) {
+		err = errInterrupted
+	}
+	tracing.End(span, err)
+	completeMessage(ctx, store, webhooks, execution, response, err, logger)
+}
+
+// completeMessage armazena a resposta (ou o erro) de uma mensagem de /send, a remove das
+// execuções pendentes e envia o callback, se a mensagem tiver um
+func completeMessage(ctx context.Context, store ResponseStore, webhooks *WebhookDispatcher, execution PendingExecution, response string, err error, logger *zap.Logger) {
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 10*time.Second)
+		defer cancel()
+		if err := store.RemovePending(ctx, execution.MessageID); err != nil {
+			logger.Warn("Não foi possível remover a execução pendente", zap.String("message_id", execution.MessageID), zap.Error(err))
+		}
+	}()
+
+	if err == nil {
+		// Armazenar a resposta com status "completed"
+		data := &models.ResponseData{
+			Status:   "completed",
+			Response: response,
+		}
+		storeResponse(ctx, store, execution, data, logger)
+		webhooks.Deliver(execution, data)
+		return
+	}
+
+	logger.Error("Erro ao obter a resposta da LLM",
+		zap.String("request_id", execution.RequestID),
+		zap.String("message_id", execution.MessageID),
+		zap.Error(err))
+
+	apiErr := &models.APIError{
+		Code:      ErrCodeInterrupted,
+		Message:   "A solicitação foi interrompida pela reinicialização do servidor. Envie a mensagem novamente.",
+		Retryable: true,
+		RequestID: execution.RequestID,
+	}
+	if !errors.Is(err, errInterrupted) {
+		apiErr, _ = NewLLMAPIError(err, execution.RequestID)
+	}
+	data := &models.ResponseData{
+		Status:  "error",
+		Message: apiErr.Message,
+		Error:   apiErr,
+	}
+	storeResponse(ctx, store, execution, data, logger)
+	webhooks.Deliver(execution, data)
+}
+
+// storeResponse grava o resultado da mensagem; o contexto pode já ter expirado junto com a
+// chamada ao provedor, então a gravação usa um prazo próprio
+func storeResponse(ctx context.Context, store ResponseStore, execution PendingExecution, data *models.ResponseData, logger *zap.Logger) {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 10*time.Second)
+	defer cancel()
+
+	if err := store.SetResponse(ctx, execution.SessionID, execution.MessageID, data); err != nil {
+		logger.Error("Erro ao armazenar a resposta",
+			zap.String("message_id", execution.MessageID),
+			zap.Error(err))
+	}
+}
@@ -0,0 +1,25 @@
+package handlers
+
+import (
+	"encoding/json"
+	"github.com/chatcomStackspotAI/llm"
+	"go.uber.org/zap"
+	"net/http"
+)
+
+// ProvidersHandler retorna os provedores registrados e o estado do circuit breaker de cada um;
+// novos clientes devem usar /api/v1/providers
+func ProvidersHandler(manager *llm.LLMManager, logger *zap.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "GET" {
+			WriteError(w, r, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "Método não suportado")
+			return
+		}
+
+		w.Header().Set("Link", `</api/v1/providers>; rel="successor-version"`)
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(manager.ProviderStatuses()); err != nil {
+			logger.Error("Erro ao serializar o status dos provedores", zap.Error(err))
+		}
+	}
+}
@@ -0,0 +1,219 @@
+// handlers/redis_response_store.go
+
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/chatcomStackspotAI/models"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+)
+
+// RedisResponseStoreConfig reúne as opções do RedisResponseStore
+type RedisResponseStoreConfig struct {
+	Client    redis.UniversalClient
+	KeyPrefix string        // Prefixo das chaves e do canal; padrão: "chat:"
+	TTL       time.Duration // Validade das respostas de uma sessão; padrão: 24 horas
+	// Instance identifica a instância dona das mensagens pendentes, que só ela retoma ao
+	// reiniciar; deve se manter entre reinicializações (ex.: DYNO no Heroku). Padrão: o hostname.
+	Instance string
+}
+
+// RedisResponseStore guarda as respostas no Redis (ou em qualquer servidor compatível com o
+// protocolo), para que todas as instâncias do servidor vejam as mesmas mensagens. Cada sessão é
+// um hash indexado por message_id, e as conclusões são publicadas em um canal, acordando as
+// requisições em Wait de qualquer instância. As mensagens pendentes de cada instância ficam em
+// outro hash, também indexado por message_id.
+type RedisResponseStore struct {
+	client   redis.UniversalClient
+	prefix   string
+	ttl      time.Duration
+	instance string
+	pubsub   *redis.PubSub
+	waiters  *responseWaiters
+	logger   *zap.Logger
+}
+
+// redisCompletion é a mensagem publicada quando uma resposta é concluída
+type redisCompletion struct {
+	SessionID string               `json:"session_id"`
+	MessageID string               `json:"message_id"`
+	Data      *models.ResponseData `json:"data"`
+}
+
+// NewRedisResponseStore verifica a conexão e assina o canal de conclusões; chame Close ao final
+func NewRedisResponseStore(ctx context.Context, config RedisResponseStoreConfig, logger *zap.Logger) (*RedisResponseStore, error) {
+	if config.Client == nil {
+		return nil, errors.New("RedisResponseStoreConfig.Client não informado")
+	}
+	if config.KeyPrefix == "" {
+		config.KeyPrefix = "chat:"
+	}
+	if config.TTL <= 0 {
+		config.TTL = 24 * time.Hour
+	}
+	if config.Instance == "" {
+		hostname, err := os.Hostname()
+		if err != nil {
+			return nil, fmt.Errorf("RedisResponseStoreConfig.Instance não informado: %w", err)
+		}
+		config.Instance = hostname
+	}
+
+	if err := config.Client.Ping(ctx).Err(); err != nil {
+		return nil, fmt.Errorf("erro ao conectar ao Redis: %w", err)
+	}
+
+	store := &RedisResponseStore{
+		client:   config.Client,
+		prefix:   config.KeyPrefix,
+		ttl:      config.TTL,
+		instance: config.Instance,
+		waiters:  newResponseWaiters(),
+		logger:   logger,
+	}
+
+	// A assinatura é confirmada antes de retornar, para que nenhuma conclusão se perca
+	store.pubsub = config.Client.Subscribe(ctx, store.channel())
+	if _, err := store.pubsub.Receive(ctx); err != nil {
+		store.pubsub.Close()
+		return nil, fmt.Errorf("erro ao assinar o canal de respostas no Redis: %w", err)
+	}
+	go store.listen()
+
+	return store, nil
+}
+
+func (store *RedisResponseStore) sessionKey(sessionID string) string {
+	return store.prefix + "responses:" + sessionID
+}
+
+func (store *RedisResponseStore) pendingKey() string {
+	return store.prefix + "pending:" + store.instance
+}
+
+func (store *RedisResponseStore) channel() string {
+	return store.prefix + "responses:completed"
+}
+
+func (store *RedisResponseStore) SetResponse(ctx context.Context, sessionID, messageID string, data *models.ResponseData) error {
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	var completion []byte
+	if responseDone(data) {
+		completion, err = json.Marshal(redisCompletion{SessionID: sessionID, MessageID: messageID, Data: data})
+		if err != nil {
+			return err
+		}
+	}
+
+	key := store.sessionKey(sessionID)
+	_, err = store.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(ctx, key, messageID, encoded)
+		pipe.Expire(ctx, key, store.ttl)
+		if completion != nil {
+			pipe.Publish(ctx, store.channel(), completion)
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("erro ao salvar a resposta no Redis: %w", err)
+	}
+	return nil
+}
+
+func (store *RedisResponseStore) GetResponse(ctx context.Context, sessionID, messageID string) (*models.ResponseData, bool, error) {
+	encoded, err := store.client.HGet(ctx, store.sessionKey(sessionID), messageID).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("erro ao ler a resposta do Redis: %w", err)
+	}
+
+	var data models.ResponseData
+	if err := json.Unmarshal(encoded, &data); err != nil {
+		return nil, false, fmt.Errorf("resposta inválida no Redis: %w", err)
+	}
+	return &data, true, nil
+}
+
+func (store *RedisResponseStore) Wait(ctx context.Context, sessionID, messageID string) (*models.ResponseData, bool, error) {
+	return store.waiters.wait(ctx, sessionID, messageID, func() (*models.ResponseData, bool, error) {
+		return store.GetResponse(ctx, sessionID, messageID)
+	})
+}
+
+// AddPending salva a mensagem no hash de pendentes da instância; o hash expira junto com as
+// respostas, para não acumular mensagens de uma instância que deixou de existir
+func (store *RedisResponseStore) AddPending(ctx context.Context, execution PendingExecution) error {
+	encoded, err := json.Marshal(execution)
+	if err != nil {
+		return err
+	}
+
+	key := store.pendingKey()
+	_, err = store.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(ctx, key, execution.MessageID, encoded)
+		pipe.Expire(ctx, key, store.ttl)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("erro ao salvar a mensagem pendente no Redis: %w", err)
+	}
+	return nil
+}
+
+func (store *RedisResponseStore) RemovePending(ctx context.Context, messageID string) error {
+	if err := store.client.HDel(ctx, store.pendingKey(), messageID).Err(); err != nil {
+		return fmt.Errorf("erro ao remover a mensagem pendente do Redis: %w", err)
+	}
+	return nil
+}
+
+func (store *RedisResponseStore) ListPending(ctx context.Context) ([]PendingExecution, error) {
+	encoded, err := store.client.HVals(ctx, store.pendingKey()).Result()
+	if err != nil {
+		return nil, fmt.Errorf("erro ao ler as mensagens pendentes do Redis: %w", err)
+	}
+
+	executions := make([]PendingExecution, 0, len(encoded))
+	for _, value := range encoded {
+		var execution PendingExecution
+		if err := json.Unmarshal([]byte(value), &execution); err != nil {
+			store.logger.Warn("Mensagem pendente inválida no Redis", zap.Error(err))
+			continue
+		}
+		executions = append(executions, execution)
+	}
+	sortPending(executions)
+	return executions, nil
+}
+
+// Close encerra a assinatura do canal; o cliente Redis continua com quem o criou
+func (store *RedisResponseStore) Close() error {
+	return store.pubsub.Close()
+}
+
+// listen repassa as conclusões publicadas por qualquer instância às esperas locais. Durante uma
+// reconexão as publicações podem se perder; as esperas terminam pelo prazo do ctx e o cliente
+// volta a consultar.
+func (store *RedisResponseStore) listen() {
+	for message := range store.pubsub.Channel() {
+		var completion redisCompletion
+		if err := json.Unmarshal([]byte(message.Payload), &completion); err != nil {
+			store.logger.Warn("Conclusão inválida recebida do Redis", zap.Error(err))
+			continue
+		}
+		store.waiters.notify(completion.SessionID, completion.MessageID, completion.Data)
+	}
+}
@@ -0,0 +1,172 @@
+package handlers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/chatcomStackspotAI/models"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+)
+
+// newTestRedisStore cria um RedisResponseStore com o próprio cliente, como uma instância do servidor
+func newTestRedisStore(t *testing.T, server *miniredis.Miniredis, ttl time.Duration) *RedisResponseStore {
+	t.Helper()
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { client.Close() })
+
+	store, err := NewRedisResponseStore(context.Background(), RedisResponseStoreConfig{Client: client, TTL: ttl}, zap.NewNop())
+	if err != nil {
+		t.Fatalf("NewRedisResponseStore: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+	return store
+}
+
+func TestRedisResponseStoreGetAndNotFound(t *testing.T) {
+	server := miniredis.RunT(t)
+	store := newTestRedisStore(t, server, time.Hour)
+	ctx := context.Background()
+
+	if _, exists, err := store.GetResponse(ctx, "sessao", "inexistente"); err != nil || exists {
+		t.Fatalf("GetResponse inexistente = %v, %v", exists, err)
+	}
+	// Wait também retorna imediatamente para mensagens inexistentes
+	if _, exists, err := store.Wait(ctx, "sessao", "inexistente"); err != nil || exists {
+		t.Fatalf("Wait inexistente = %v, %v", exists, err)
+	}
+
+	want := &models.ResponseData{Status: "completed", Response: "olá"}
+	if err := store.SetResponse(ctx, "sessao", "mensagem", want); err != nil {
+		t.Fatalf("SetResponse: %v", err)
+	}
+	got, exists, err := store.GetResponse(ctx, "sessao", "mensagem")
+	if err != nil || !exists || got.Status != want.Status || got.Response != want.Response {
+		t.Fatalf("GetResponse = %+v, %v, %v", got, exists, err)
+	}
+	if _, exists, _ := store.GetResponse(ctx, "outra-sessao", "mensagem"); exists {
+		t.Fatal("a mensagem não deveria aparecer em outra sessão")
+	}
+}
+
+func TestRedisResponseStoreTTL(t *testing.T) {
+	server := miniredis.RunT(t)
+	store := newTestRedisStore(t, server, time.Minute)
+	ctx := context.Background()
+
+	store.SetResponse(ctx, "sessao", "primeira", &models.ResponseData{Status: "completed"})
+	if ttl := server.TTL("chat:responses:sessao"); ttl != time.Minute {
+		t.Fatalf("TTL = %s, esperado %s", ttl, time.Minute)
+	}
+
+	// Cada nova mensagem renova a validade da sessão inteira
+	server.FastForward(40 * time.Second)
+	store.SetResponse(ctx, "sessao", "segunda", &models.ResponseData{Status: "processing"})
+	server.FastForward(40 * time.Second)
+	if _, exists, _ := store.GetResponse(ctx, "sessao", "primeira"); !exists {
+		t.Fatal("a sessão expirou antes do TTL contado da última mensagem")
+	}
+
+	server.FastForward(time.Minute)
+	if _, exists, _ := store.GetResponse(ctx, "sessao", "primeira"); exists {
+		t.Fatal("a sessão deveria ter expirado")
+	}
+}
+
+func TestRedisResponseStoreWaitAcrossInstances(t *testing.T) {
+	server := miniredis.RunT(t)
+	sender := newTestRedisStore(t, server, time.Hour)
+	receiver := newTestRedisStore(t, server, time.Hour)
+	ctx := context.Background()
+
+	if err := sender.SetResponse(ctx, "sessao", "mensagem", &models.ResponseData{Status: "processing"}); err != nil {
+		t.Fatalf("SetResponse: %v", err)
+	}
+
+	type result struct {
+		data   *models.ResponseData
+		exists bool
+		err    error
+	}
+	done := make(chan result, 1)
+	go func() {
+		waitCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+		defer cancel()
+		data, exists, err := receiver.Wait(waitCtx, "sessao", "mensagem")
+		done <- result{data, exists, err}
+	}()
+
+	// A espera na outra instância só termina com a conclusão publicada pelo sender
+	select {
+	case r := <-done:
+		t.Fatalf("Wait retornou antes da conclusão: %+v", r.data)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	if err := sender.SetResponse(ctx, "sessao", "mensagem", &models.ResponseData{Status: "completed", Response: "pronto"}); err != nil {
+		t.Fatalf("SetResponse: %v", err)
+	}
+	select {
+	case r := <-done:
+		if r.err != nil || !r.exists || r.data.Status != "completed" || r.data.Response != "pronto" {
+			t.Fatalf("Wait = %+v, %v, %v", r.data, r.exists, r.err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Wait não foi acordado pela conclusão publicada em outra instância")
+	}
+}
+
+func TestRedisResponseStorePendingSurvivesRestart(t *testing.T) {
+	server := miniredis.RunT(t)
+	ctx := context.Background()
+	newStore := func(instance string) *RedisResponseStore {
+		client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+		t.Cleanup(func() { client.Close() })
+		store, err := NewRedisResponseStore(ctx, RedisResponseStoreConfig{Client: client, TTL: time.Hour, Instance: instance}, zap.NewNop())
+		if err != nil {
+			t.Fatalf("NewRedisResponseStore: %v", err)
+		}
+		t.Cleanup(func() { store.Close() })
+		return store
+	}
+
+	store := newStore("web.1")
+	started := time.Now().UTC()
+	recent := PendingExecution{SessionID: "sessao", MessageID: "recente", Provider: "SPOT", StartedAt: started}
+	old := PendingExecution{SessionID: "sessao", MessageID: "antiga", Provider: "OPENAI", StartedAt: started.Add(-time.Minute)}
+	for _, execution := range []PendingExecution{recent, old} {
+		if err := store.AddPending(ctx, execution); err != nil {
+			t.Fatalf("AddPending: %v", err)
+		}
+	}
+	recent.ExecutionID = "exec-1"
+	store.AddPending(ctx, recent)
+	store.SetResponse(ctx, "sessao", "antiga", &models.ResponseData{Status: "processing"})
+
+	if ttl := server.TTL("chat:pending:web.1"); ttl != time.Hour {
+		t.Fatalf("TTL das pendentes = %v, esperado 1h", ttl)
+	}
+	// Outra instância não enxerga as mensagens pendentes desta
+	if pending, err := newStore("web.2").ListPending(ctx); err != nil || len(pending) != 0 {
+		t.Fatalf("pendentes de outra instância = %v, %v", pending, err)
+	}
+
+	// A mesma instância, depois de reiniciar, encontra as mensagens
+	restarted := newStore("web.1")
+	pending, err := restarted.ListPending(ctx)
+	if err != nil || len(pending) != 2 || pending[0].MessageID != "antiga" || pending[1].ExecutionID != "exec-1" {
+		t.Fatalf("ListPending = %+v, %v", pending, err)
+	}
+
+	restarted.RemovePending(ctx, "recente")
+	ResumePendingExecutions(ctx, nil, restarted, nil, zap.NewNop())
+	data, _, _ := restarted.GetResponse(ctx, "sessao", "antiga")
+	if data == nil || data.Status != "error" || data.Error.Code != ErrCodeInterrupted {
+		t.Fatalf("mensagem interrompida = %+v", data)
+	}
+	if pending, _ := restarted.ListPending(ctx); len(pending) != 0 {
+		t.Fatalf("pendentes depois de retomar = %+v", pending)
+	}
+}
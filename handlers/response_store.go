@@ -3,41 +3,191 @@
 package handlers
 
 import (
-	"github.com/chatcomStackspotAI/models"
+	"context"
 	"sync"
+
+	"github.com/chatcomStackspotAI/models"
 )
 
-type ResponseStore struct {
+// ResponseStore guarda as respostas assíncronas de /send, consultadas em /get-response.
+// Com várias instâncias do servidor, use um store compartilhado (NewRedisResponseStore),
+// pois a mensagem pode ser enviada a uma instância e consultada em outra.
+type ResponseStore interface {
+	// SetResponse armazena a resposta associada ao session_id e ao message_id
+	SetResponse(ctx context.Context, sessionID, messageID string, data *models.ResponseData) error
+	// GetResponse obtém a resposta associada ao session_id e ao message_id
+	GetResponse(ctx context.Context, sessionID, messageID string) (*models.ResponseData, bool, error)
+	// Wait aguarda até que a resposta deixe o status "processing" ou o ctx termine, e retorna
+	// o estado mais recente. Mensagens inexistentes retornam imediatamente.
+	Wait(ctx context.Context, sessionID, messageID string) (*models.ResponseData, bool, error)
+
+	// AddPending registra uma mensagem de /send ainda sem resposta, ou atualiza a existente com o
+	// mesmo message_id, para que seja retomada depois de uma reinicialização
+	AddPending(ctx context.Context, execution PendingExecution) error
+	// RemovePending descarta a mensagem pendente quando a resposta (ou o erro) já foi armazenada
+	RemovePending(ctx context.Context, messageID string) error
+	// ListPending retorna as mensagens pendentes, da mais antiga para a mais recente
+	ListPending(ctx context.Context) ([]PendingExecution, error)
+}
+
+// responseDone indica se a mensagem já foi concluída, com a resposta ou com um erro
+func responseDone(data *models.ResponseData) bool {
+	return data != nil && data.Status != "processing"
+}
+
+// MemoryResponseStore é o ResponseStore em memória, para uma única instância do servidor. As
+// mensagens pendentes se perdem junto com as respostas quando o processo termina.
+type MemoryResponseStore struct {
 	mu        sync.RWMutex
 	responses map[string]map[string]*models.ResponseData // Mapa para armazenar por session_id
+	pending   map[string]PendingExecution                // Indexado por message_id
+	waiters   *responseWaiters
 }
 
-func NewResponseStore() *ResponseStore {
-	return &ResponseStore{
+func NewMemoryResponseStore() *MemoryResponseStore {
+	return &MemoryResponseStore{
 		responses: make(map[string]map[string]*models.ResponseData),
+		pending:   make(map[string]PendingExecution),
+		waiters:   newResponseWaiters(),
 	}
 }
 
 // Armazenar a resposta associada ao session_id e messageID
-func (store *ResponseStore) SetResponse(sessionID, messageID string, data *models.ResponseData) {
+func (store *MemoryResponseStore) SetResponse(_ context.Context, sessionID, messageID string, data *models.ResponseData) error {
 	store.mu.Lock()
-	defer store.mu.Unlock()
-
 	// Verificar se já existe um mapa de respostas para o sessionID
 	if store.responses[sessionID] == nil {
 		store.responses[sessionID] = make(map[string]*models.ResponseData)
 	}
 	store.responses[sessionID][messageID] = data
+	store.mu.Unlock()
+
+	if responseDone(data) {
+		store.waiters.notify(sessionID, messageID, data)
+	}
+	return nil
 }
 
 // Obter a resposta associada ao session_id e messageID
-func (store *ResponseStore) GetResponse(sessionID, messageID string) (*models.ResponseData, bool) {
+func (store *MemoryResponseStore) GetResponse(_ context.Context, sessionID, messageID string) (*models.ResponseData, bool, error) {
 	store.mu.RLock()
 	defer store.mu.RUnlock()
 
 	if responsesForSession, exists := store.responses[sessionID]; exists {
 		data, found := responsesForSession[messageID]
-		return data, found
+		return data, found, nil
+	}
+	return nil, false, nil
+}
+
+func (store *MemoryResponseStore) Wait(ctx context.Context, sessionID, messageID string) (*models.ResponseData, bool, error) {
+	return store.waiters.wait(ctx, sessionID, messageID, func() (*models.ResponseData, bool, error) {
+		return store.GetResponse(ctx, sessionID, messageID)
+	})
+}
+
+func (store *MemoryResponseStore) AddPending(_ context.Context, execution PendingExecution) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	store.pending[execution.MessageID] = execution
+	return nil
+}
+
+func (store *MemoryResponseStore) RemovePending(_ context.Context, messageID string) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	delete(store.pending, messageID)
+	return nil
+}
+
+func (store *MemoryResponseStore) ListPending(_ context.Context) ([]PendingExecution, error) {
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+
+	executions := make([]PendingExecution, 0, len(store.pending))
+	for _, execution := range store.pending {
+		executions = append(executions, execution)
+	}
+	sortPending(executions)
+	return executions, nil
+}
+
+// Size retorna o número total de respostas armazenadas
+func (store *MemoryResponseStore) Size() int {
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+
+	total := 0
+	for _, responsesForSession := range store.responses {
+		total += len(responsesForSession)
+	}
+	return total
+}
+
+// responseWaiters acorda as requisições que aguardam a conclusão de uma mensagem
+type responseWaiters struct {
+	mu      sync.Mutex
+	waiting map[[2]string][]chan *models.ResponseData // Indexado por {session_id, message_id}
+}
+
+func newResponseWaiters() *responseWaiters {
+	return &responseWaiters{waiting: make(map[[2]string][]chan *models.ResponseData)}
+}
+
+// wait registra a espera antes de consultar o estado atual com get, para não perder uma
+// conclusão que aconteça entre a consulta e o registro
+func (w *responseWaiters) wait(ctx context.Context, sessionID, messageID string, get func() (*models.ResponseData, bool, error)) (*models.ResponseData, bool, error) {
+	key := [2]string{sessionID, messageID}
+	ch := make(chan *models.ResponseData, 1)
+
+	w.mu.Lock()
+	w.waiting[key] = append(w.waiting[key], ch)
+	w.mu.Unlock()
+	defer w.remove(key, ch)
+
+	data, found, err := get()
+	if err != nil || !found || responseDone(data) {
+		return data, found, err
+	}
+
+	select {
+	case done := <-ch:
+		return done, true, nil
+	case <-ctx.Done():
+		return data, true, nil
+	}
+}
+
+// notify entrega a resposta concluída a todos que a aguardam
+func (w *responseWaiters) notify(sessionID, messageID string, data *models.ResponseData) {
+	key := [2]string{sessionID, messageID}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	for _, ch := range w.waiting[key] {
+		select {
+		case ch <- data:
+		default:
+		}
+	}
+	delete(w.waiting, key)
+}
+
+func (w *responseWaiters) remove(key [2]string, ch chan *models.ResponseData) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	chans := w.waiting[key]
+	for i, c := range chans {
+		if c == ch {
+			w.waiting[key] = append(chans[:i], chans[i+1:]...)
+			break
+		}
+	}
+	if len(w.waiting[key]) == 0 {
+		delete(w.waiting, key)
 	}
-	return nil, false
 }
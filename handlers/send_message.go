@@ -4,20 +4,26 @@ import (
 	"context"
 	"encoding/json"
 	"github.com/chatcomStackspotAI/llm"
+	"github.com/chatcomStackspotAI/middlewares"
 	"github.com/chatcomStackspotAI/models"
+	"github.com/chatcomStackspotAI/tracing"
 	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
 	"go.uber.org/zap"
 	"net/http"
 	"time"
 )
 
-func SendMessageHandler(manager *llm.LLMManager, store *ResponseStore, logger *zap.Logger) http.HandlerFunc {
+func SendMessageHandler(manager *llm.LLMManager, store ResponseStore, webhooks *WebhookDispatcher, logger *zap.Logger) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != "POST" {
-			http.Error(w, "Método não suportado", http.StatusMethodNotAllowed)
+			WriteError(w, r, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "Método não suportado")
 			return
 		}
 
+		ctx, span := tracing.Start(r.Context(), "SendMessageHandler")
+		defer span.End()
+
 		// Estrutura para receber os dados do corpo da requisição
 		var data struct {
 			Provider  string           `json:"provider"`
@@ -25,27 +31,41 @@ func SendMessageHandler(manager *llm.LLMManager, store *ResponseStore, logger *z
 			Prompt    string           `json:"prompt"`
 			History   []models.Message `json:"history"`
 			SessionID string           `json:"session_id"`
+			// Opcional: recebe um POST assinado com o resultado quando a mensagem termina
+			CallbackURL string `json:"callback_url"`
 		}
 
 		// Decodificar o corpo da requisição
 		err := json.NewDecoder(r.Body).Decode(&data)
 		if err != nil {
 			logger.Error("Erro ao decodificar o JSON", zap.Error(err))
-			http.Error(w, "Dados inválidos", http.StatusBadRequest)
+			WriteError(w, r, http.StatusBadRequest, ErrCodeInvalidRequest, "Dados inválidos")
 			return
 		}
 
 		// Verificar se o session_id foi enviado
 		if data.SessionID == "" {
-			http.Error(w, "session_id não fornecido", http.StatusBadRequest)
+			WriteError(w, r, http.StatusBadRequest, ErrCodeInvalidRequest, "session_id não fornecido")
 			return
 		}
 
+		if data.CallbackURL != "" {
+			if err := webhooks.ValidateURL(data.CallbackURL); err != nil {
+				WriteError(w, r, http.StatusBadRequest, ErrCodeInvalidRequest, err.Error())
+				return
+			}
+		}
+
+		span.SetAttributes(
+			attribute.String("session_id", data.SessionID),
+			attribute.String("llm.provider", data.Provider))
+
 		// Obter o cliente LLM com base no provider e model
-		client, err := manager.GetClient(data.Provider, data.Model)
+		client, err := manager.GetClient(ctx, data.Provider, data.Model)
 		if err != nil {
 			logger.Error("Erro ao obter o cliente LLM", zap.Error(err))
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			span.RecordError(err)
+			WriteLLMError(w, r, err)
 			return
 		}
 
@@ -53,32 +73,53 @@ func SendMessageHandler(manager *llm.LLMManager, store *ResponseStore, logger *z
 		messageID := uuid.New().String()
 
 		// Armazenar o status inicial como "processing"
-		store.SetResponse(data.SessionID, messageID, &models.ResponseData{
+		err = store.SetResponse(ctx, data.SessionID, messageID, &models.ResponseData{
 			Status: "processing",
 		})
+		if err != nil {
+			logger.Error("Erro ao armazenar a mensagem", zap.Error(err))
+			span.RecordError(err)
+			WriteError(w, r, http.StatusInternalServerError, ErrCodeInternal, "Não foi possível registrar a mensagem")
+			return
+		}
+
+		span.SetAttributes(attribute.String("message_id", messageID))
+
+		requestID := middlewares.RequestIDFromContext(ctx)
+
+		// Registra a mensagem como pendente para que uma reinicialização não a deixe órfã
+		execution := PendingExecution{
+			SessionID:   data.SessionID,
+			MessageID:   messageID,
+			RequestID:   requestID,
+			Provider:    data.Provider,
+			Model:       data.Model,
+			CallbackURL: data.CallbackURL,
+			StartedAt:   time.Now().UTC(),
+		}
+		if err := store.AddPending(ctx, execution); err != nil {
+			logger.Warn("Não foi possível registrar a execução pendente", zap.String("message_id", messageID), zap.Error(err))
+		}
+
+		// O contexto da goroutine não é cancelado junto com a requisição, mas mantém o span atual
+		bgCtx := context.WithoutCancel(ctx)
 
 		// Iniciar o processamento em background
-		go func(sessionID, messageID string, client llm.LLMClient, prompt string, history []models.Message) {
-			// Criar um novo contexto com timeout
-			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
-			defer cancel()
-
-			llmResponse, err := client.SendPrompt(ctx, prompt, history)
-			if err != nil {
-				logger.Error("Erro ao obter a resposta da LLM", zap.Error(err))
-				store.SetResponse(sessionID, messageID, &models.ResponseData{
-					Status:  "error",
-					Message: err.Error(),
-				})
-				return
-			}
+		go func(execution PendingExecution, client llm.LLMClient, prompt string, history []models.Message) {
+			ctx, span := tracing.Start(bgCtx, "SendMessageHandler.background", attribute.String("message_id", execution.MessageID))
 
-			// Armazenar a resposta com status "completed"
-			store.SetResponse(sessionID, messageID, &models.ResponseData{
-				Status:   "completed",
-				Response: llmResponse,
+			// Provedores assíncronos informam o ID da execução, que é salvo para ser retomado
+			ctx = llm.WithExecutionObserver(ctx, func(executionID string) {
+				execution.ExecutionID = executionID
+				if err := store.AddPending(ctx, execution); err != nil {
+					logger.Warn("Não foi possível salvar o ID da execução", zap.String("message_id", execution.MessageID), zap.Error(err))
+				}
 			})
-		}(data.SessionID, messageID, client, data.Prompt, data.History)
+
+			llmResponse, _, err := runCompletion(ctx, client, prompt, history)
+			tracing.End(span, err)
+			completeMessage(ctx, store, webhooks, execution, llmResponse, err, logger)
+		}(execution, client, data.Prompt, data.History)
 
 		// Retornar o messageID para o cliente
 		w.Header().Set("Content-Type", "application/json")
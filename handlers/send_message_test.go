@@ -0,0 +1,154 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/chatcomStackspotAI/llm"
+	"github.com/chatcomStackspotAI/llm/llmtest"
+	"github.com/chatcomStackspotAI/models"
+	"go.uber.org/zap"
+)
+
+// newTestManager cria um LLMManager apontado para o servidor falso
+func newTestManager(t *testing.T, fake *llmtest.Server) *llm.LLMManager {
+	t.Helper()
+	fake.Setenv(t)
+	t.Setenv("OPENAI_RETRY_INITIAL_BACKOFF", "1ms")
+	t.Setenv("STACKSPOT_RETRY_INITIAL_BACKOFF", "1ms")
+	t.Setenv("STACKSPOT_POLL_INITIAL_INTERVAL", "1ms")
+	t.Setenv("STACKSPOT_POLL_MAX_INTERVAL", "5ms")
+
+	manager, err := llm.NewLLMManager(zap.NewNop())
+	if err != nil {
+		t.Fatalf("NewLLMManager: %v", err)
+	}
+	t.Cleanup(manager.Close)
+	return manager
+}
+
+// newTestMux registra /send e /get-response com um LLMManager apontado para o servidor falso
+func newTestMux(t *testing.T, fake *llmtest.Server) *http.ServeMux {
+	t.Helper()
+	logger := zap.NewNop()
+	manager := newTestManager(t, fake)
+	store := NewMemoryResponseStore()
+	mux := http.NewServeMux()
+	mux.HandleFunc("/send", SendMessageHandler(manager, store, NewWebhookDispatcher(WebhookConfig{}, logger), logger))
+	mux.HandleFunc("/get-response", GetResponseHandler(store, logger))
+	return mux
+}
+
+// sendAndWait envia a mensagem por /send e aguarda a resposta em /get-response
+func sendAndWait(t *testing.T, mux *http.ServeMux, body string) *models.ResponseData {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/send", strings.NewReader(body)))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("/send: status %d: %s", rec.Code, rec.Body)
+	}
+	var sent struct {
+		MessageID string `json:"message_id"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&sent); err != nil || sent.MessageID == "" {
+		t.Fatalf("/send: resposta inválida: %v", err)
+	}
+
+	query := url.Values{"session_id": {"sessao-1"}, "message_id": {sent.MessageID}, "wait": {"10s"}}
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/get-response?"+query.Encode(), nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("/get-response: status %d: %s", rec.Code, rec.Body)
+	}
+	var data models.ResponseData
+	if err := json.NewDecoder(rec.Body).Decode(&data); err != nil {
+		t.Fatalf("/get-response: resposta inválida: %v", err)
+	}
+	return &data
+}
+
+func TestSendMessageProviders(t *testing.T) {
+	tests := []struct {
+		provider string
+		route    string
+		response string
+	}{
+		{llm.ProviderOpenAI, llmtest.RouteOpenAIChat, "eco: oi"},
+		{llm.ProviderClaudeAI, llmtest.RouteAnthropicMessages, "eco: oi"},
+		{llm.ProviderStackSpot, llmtest.RouteStackSpotCallback, "eco: Usuário: oi"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.provider, func(t *testing.T) {
+			fake := llmtest.NewServer()
+			defer fake.Close()
+			mux := newTestMux(t, fake)
+
+			data := sendAndWait(t, mux, `{"provider":"`+tt.provider+`","prompt":"oi","session_id":"sessao-1"}`)
+			if data.Status != "completed" || data.Response != tt.response {
+				t.Fatalf("resposta = %+v", data)
+			}
+			if len(fake.Requests(tt.route)) == 0 {
+				t.Fatalf("nenhuma requisição em %s", tt.route)
+			}
+		})
+	}
+}
+
+func TestSendMessageProviderFailure(t *testing.T) {
+	fake := llmtest.NewServer()
+	defer fake.Close()
+	fake.StackSpotFailure = true
+	mux := newTestMux(t, fake)
+
+	data := sendAndWait(t, mux, `{"provider":"SPOT","prompt":"oi","session_id":"sessao-1"}`)
+	if data.Status != "error" || data.Error == nil || data.Error.Code != string(llm.ErrCodeProviderError) {
+		t.Fatalf("resposta = %+v", data)
+	}
+}
+
+func TestSendMessageValidation(t *testing.T) {
+	fake := llmtest.NewServer()
+	defer fake.Close()
+	mux := newTestMux(t, fake)
+
+	tests := []struct {
+		name   string
+		body   string
+		status int
+	}{
+		{"sem session_id", `{"provider":"OPENAI","prompt":"oi"}`, http.StatusBadRequest},
+		{"JSON inválido", `{`, http.StatusBadRequest},
+		{"provedor desconhecido", `{"provider":"NENHUM","prompt":"oi","session_id":"sessao-1"}`, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			mux.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/send", strings.NewReader(tt.body)))
+			if rec.Code != tt.status {
+				t.Fatalf("status = %d, esperado %d: %s", rec.Code, tt.status, rec.Body)
+			}
+		})
+	}
+}
+
+func TestGetResponseNotFound(t *testing.T) {
+	fake := llmtest.NewServer()
+	defer fake.Close()
+	mux := newTestMux(t, fake)
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/get-response?session_id=sessao-1&message_id=inexistente", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("status = %d, esperado %d", rec.Code, http.StatusNotFound)
+	}
+
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/get-response?session_id=sessao-1", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("status = %d, esperado %d", rec.Code, http.StatusBadRequest)
+	}
+}
@@ -0,0 +1,323 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/chatcomStackspotAI/metrics"
+	"github.com/chatcomStackspotAI/models"
+	"github.com/chatcomStackspotAI/retry"
+	"github.com/chatcomStackspotAI/tracing"
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+	"go.uber.org/zap"
+)
+
+// defaultMaxWebhookDeliveries é o número padrão de entregas mantidas no log
+const defaultMaxWebhookDeliveries = 1000
+
+// WebhookConfig configura os callbacks enviados ao fim das mensagens de /send
+type WebhookConfig struct {
+	Secret        string       // Chave do HMAC-SHA256 das assinaturas; sem ela os callbacks ficam desativados
+	AllowedHosts  []string     // Hosts aceitos em callback_url; "*.exemplo.com" aceita os subdomínios
+	HTTPClient    *http.Client // Padrão: timeout de 10s, sem seguir redirecionamentos
+	RetryPolicy   retry.Policy // Valor zero: uma única tentativa
+	MaxDeliveries int          // Entregas mantidas no log; padrão: 1000
+}
+
+// WebhookConfigFromEnv lê WEBHOOK_SECRET, WEBHOOK_ALLOWED_HOSTS (separados por vírgula) e a
+// política de novas tentativas WEBHOOK_RETRY_*
+func WebhookConfigFromEnv() WebhookConfig {
+	retryDefaults := retry.DefaultPolicy()
+	retryDefaults.MaxAttempts = 5
+	config := WebhookConfig{
+		Secret:      os.Getenv("WEBHOOK_SECRET"),
+		RetryPolicy: retry.PolicyFromEnv("WEBHOOK", retryDefaults),
+	}
+	for _, host := range strings.Split(os.Getenv("WEBHOOK_ALLOWED_HOSTS"), ",") {
+		if host = strings.ToLower(strings.TrimSpace(host)); host != "" {
+			config.AllowedHosts = append(config.AllowedHosts, host)
+		}
+	}
+	return config
+}
+
+// WebhookDelivery é o registro de um callback, consultável em /api/v1/webhooks/deliveries
+type WebhookDelivery struct {
+	ID          string           `json:"id"`
+	MessageID   string           `json:"message_id"`
+	SessionID   string           `json:"session_id"`
+	URL         string           `json:"url"`
+	Status      string           `json:"status"` // "pending", "delivered" ou "failed"
+	Attempts    []WebhookAttempt `json:"attempts"`
+	CreatedAt   time.Time        `json:"created_at"`
+	CompletedAt *time.Time       `json:"completed_at,omitempty"`
+}
+
+// WebhookAttempt é uma tentativa de entrega
+type WebhookAttempt struct {
+	At         time.Time `json:"at"`
+	StatusCode int       `json:"status_code,omitempty"`
+	Error      string    `json:"error,omitempty"`
+	DurationMs int64     `json:"duration_ms"`
+}
+
+// webhookPayload é o corpo do callback
+type webhookPayload struct {
+	Event      string               `json:"event"`
+	DeliveryID string               `json:"delivery_id"`
+	SessionID  string               `json:"session_id"`
+	MessageID  string               `json:"message_id"`
+	RequestID  string               `json:"request_id,omitempty"`
+	Data       *models.ResponseData `json:"data"`
+}
+
+// WebhookDispatcher envia os callbacks assinados e mantém o log das entregas em memória
+type WebhookDispatcher struct {
+	secret        []byte
+	allowedHosts  []string
+	httpClient    *http.Client
+	retryPolicy   retry.Policy
+	maxDeliveries int
+	logger        *zap.Logger
+
+	mu         sync.Mutex
+	deliveries map[string]*WebhookDelivery
+	order      []string // IDs das entregas, da mais antiga para a mais recente
+}
+
+func NewWebhookDispatcher(config WebhookConfig, logger *zap.Logger) *WebhookDispatcher {
+	client := config.HTTPClient
+	if client == nil {
+		client = &http.Client{
+			Timeout: 10 * time.Second,
+			// Um redirecionamento poderia levar o callback a um host fora da lista permitida
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		}
+	}
+	maxDeliveries := config.MaxDeliveries
+	if maxDeliveries <= 0 {
+		maxDeliveries = defaultMaxWebhookDeliveries
+	}
+	return &WebhookDispatcher{
+		secret:        []byte(config.Secret),
+		allowedHosts:  config.AllowedHosts,
+		httpClient:    client,
+		retryPolicy:   config.RetryPolicy,
+		maxDeliveries: maxDeliveries,
+		logger:        logger,
+		deliveries:    make(map[string]*WebhookDelivery),
+	}
+}
+
+// ValidateURL verifica se callback_url pode ser usada; o erro é exibido ao cliente
+func (d *WebhookDispatcher) ValidateURL(rawURL string) error {
+	if len(d.secret) == 0 || len(d.allowedHosts) == 0 {
+		return errors.New("callback_url não está habilitado neste servidor")
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Hostname() == "" {
+		return errors.New("callback_url deve ser uma URL http ou https")
+	}
+	if !d.hostAllowed(strings.ToLower(u.Hostname())) {
+		return fmt.Errorf("o host %q não está na lista de callbacks permitidos", u.Hostname())
+	}
+	return nil
+}
+
+func (d *WebhookDispatcher) hostAllowed(host string) bool {
+	for _, allowed := range d.allowedHosts {
+		if domain, ok := strings.CutPrefix(allowed, "*."); ok {
+			if strings.HasSuffix(host, "."+domain) {
+				return true
+			}
+		} else if host == allowed {
+			return true
+		}
+	}
+	return false
+}
+
+// Deliver registra e envia em background o callback da mensagem concluída, se ela tiver um
+func (d *WebhookDispatcher) Deliver(execution PendingExecution, data *models.ResponseData) {
+	if d == nil || execution.CallbackURL == "" {
+		return
+	}
+
+	delivery := &WebhookDelivery{
+		ID:        uuid.New().String(),
+		MessageID: execution.MessageID,
+		SessionID: execution.SessionID,
+		URL:       execution.CallbackURL,
+		Status:    "pending",
+		Attempts:  []WebhookAttempt{},
+		CreatedAt: time.Now().UTC(),
+	}
+	d.mu.Lock()
+	d.deliveries[delivery.ID] = delivery
+	d.order = append(d.order, delivery.ID)
+	if len(d.order) > d.maxDeliveries {
+		delete(d.deliveries, d.order[0])
+		d.order = d.order[1:]
+	}
+	d.mu.Unlock()
+
+	body, err := json.Marshal(webhookPayload{
+		Event:      "message.completed",
+		DeliveryID: delivery.ID,
+		SessionID:  execution.SessionID,
+		MessageID:  execution.MessageID,
+		RequestID:  execution.RequestID,
+		Data:       data,
+	})
+	if err != nil {
+		d.finish(delivery, err)
+		return
+	}
+
+	go d.send(delivery, execution.RequestID, body)
+}
+
+func (d *WebhookDispatcher) send(delivery *WebhookDelivery, requestID string, body []byte) {
+	ctx, span := tracing.Start(context.Background(), "webhook.deliver",
+		attribute.String("message_id", delivery.MessageID),
+		attribute.String("webhook.delivery_id", delivery.ID))
+
+	err := retry.Do(ctx, d.retryPolicy, func(ctx context.Context, attempt int) error {
+		start := time.Now()
+		status, err := d.post(ctx, delivery, requestID, body)
+
+		record := WebhookAttempt{At: start.UTC(), StatusCode: status, DurationMs: time.Since(start).Milliseconds()}
+		if err != nil {
+			record.Error = err.Error()
+		}
+		d.mu.Lock()
+		delivery.Attempts = append(delivery.Attempts, record)
+		d.mu.Unlock()
+		return err
+	}, func(attempt int, err error, delay time.Duration) {
+		d.logger.Warn("Falha ao entregar o callback; nova tentativa agendada",
+			zap.String("delivery_id", delivery.ID), zap.Int("attempt", attempt),
+			zap.Duration("retry_in", delay), zap.Error(err))
+	})
+	tracing.End(span, err)
+	d.finish(delivery, err)
+}
+
+// post envia uma tentativa; a assinatura é refeita a cada tentativa, com o horário atual
+func (d *WebhookDispatcher) post(ctx context.Context, delivery *WebhookDelivery, requestID string, body []byte) (int, error) {
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	mac := hmac.New(sha256.New, d.secret)
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, delivery.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, retry.Permanent(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Webhook-ID", delivery.ID)
+	req.Header.Set("X-Webhook-Timestamp", timestamp)
+	req.Header.Set("X-Webhook-Signature", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	if requestID != "" {
+		req.Header.Set("X-Request-ID", requestID)
+	}
+
+	resp, err := d.httpClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return resp.StatusCode, retry.NewHTTPError(resp, respBody)
+	}
+	return resp.StatusCode, nil
+}
+
+func (d *WebhookDispatcher) finish(delivery *WebhookDelivery, err error) {
+	now := time.Now().UTC()
+	d.mu.Lock()
+	delivery.CompletedAt = &now
+	delivery.Status = "delivered"
+	if err != nil {
+		delivery.Status = "failed"
+	}
+	d.mu.Unlock()
+
+	metrics.WebhookDeliveriesTotal.WithLabelValues(delivery.Status).Inc()
+	if err != nil {
+		d.logger.Error("Callback descartado após as tentativas",
+			zap.String("delivery_id", delivery.ID),
+			zap.String("message_id", delivery.MessageID),
+			zap.Error(err))
+	}
+}
+
+// Deliveries retorna as entregas da mais recente para a mais antiga, opcionalmente só as da mensagem
+func (d *WebhookDispatcher) Deliveries(messageID string) []WebhookDelivery {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	deliveries := []WebhookDelivery{}
+	for i := len(d.order) - 1; i >= 0; i-- {
+		delivery := d.deliveries[d.order[i]]
+		if messageID == "" || delivery.MessageID == messageID {
+			deliveries = append(deliveries, copyDelivery(delivery))
+		}
+	}
+	return deliveries
+}
+
+// Delivery retorna uma entrega pelo ID
+func (d *WebhookDispatcher) Delivery(id string) (WebhookDelivery, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	delivery, exists := d.deliveries[id]
+	if !exists {
+		return WebhookDelivery{}, false
+	}
+	return copyDelivery(delivery), true
+}
+
+// copyDelivery evita que a resposta compartilhe as tentativas ainda em andamento
+func copyDelivery(delivery *WebhookDelivery) WebhookDelivery {
+	c := *delivery
+	c.Attempts = append([]WebhookAttempt{}, delivery.Attempts...)
+	return c
+}
+
+func listWebhookDeliveriesV1Handler(webhooks *WebhookDispatcher) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, listResponse{Data: webhooks.Deliveries(r.URL.Query().Get("message_id"))})
+	}
+}
+
+func getWebhookDeliveryV1Handler(webhooks *WebhookDispatcher) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		delivery, exists := webhooks.Delivery(r.PathValue("id"))
+		if !exists {
+			WriteError(w, r, http.StatusNotFound, ErrCodeNotFound, "Entrega não encontrada")
+			return
+		}
+		writeJSON(w, http.StatusOK, delivery)
+	}
+}
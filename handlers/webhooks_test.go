@@ -0,0 +1,230 @@
+package handlers
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/chatcomStackspotAI/models"
+	"github.com/chatcomStackspotAI/retry"
+	"go.uber.org/zap"
+)
+
+const testWebhookSecret = "segredo"
+
+// newTestDispatcher aceita callbacks para o host local, com novas tentativas de 1ms
+func newTestDispatcher(maxDeliveries int) *WebhookDispatcher {
+	return NewWebhookDispatcher(WebhookConfig{
+		Secret:       testWebhookSecret,
+		AllowedHosts: []string{"127.0.0.1"},
+		RetryPolicy: retry.Policy{
+			MaxAttempts:    3,
+			InitialBackoff: time.Millisecond,
+			MaxBackoff:     time.Millisecond,
+			Multiplier:     2,
+		},
+		MaxDeliveries: maxDeliveries,
+	}, zap.NewNop())
+}
+
+// deliverAndWait envia o callback da mensagem e aguarda a conclusão da entrega
+func deliverAndWait(t *testing.T, d *WebhookDispatcher, messageID, callbackURL string) WebhookDelivery {
+	t.Helper()
+	d.Deliver(PendingExecution{SessionID: "sessao", MessageID: messageID, RequestID: "req-1", CallbackURL: callbackURL},
+		&models.ResponseData{Status: "completed", Response: "olá"})
+
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if deliveries := d.Deliveries(messageID); len(deliveries) == 1 && deliveries[0].Status != "pending" {
+			return deliveries[0]
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("a entrega da mensagem %s não terminou", messageID)
+	return WebhookDelivery{}
+}
+
+func attemptStatuses(delivery WebhookDelivery) []int {
+	statuses := make([]int, 0, len(delivery.Attempts))
+	for _, attempt := range delivery.Attempts {
+		statuses = append(statuses, attempt.StatusCode)
+	}
+	return statuses
+}
+
+func TestWebhookSignature(t *testing.T) {
+	var mu sync.Mutex
+	var received *http.Request
+	var body []byte
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		received = r
+		body, _ = io.ReadAll(r.Body)
+	}))
+	defer receiver.Close()
+
+	d := newTestDispatcher(0)
+	delivery := deliverAndWait(t, d, "mensagem", receiver.URL)
+	if delivery.Status != "delivered" || len(delivery.Attempts) != 1 || delivery.Attempts[0].StatusCode != http.StatusOK {
+		t.Fatalf("entrega = %+v", delivery)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	timestamp := received.Header.Get("X-Webhook-Timestamp")
+	mac := hmac.New(sha256.New, []byte(testWebhookSecret))
+	mac.Write([]byte(timestamp + "." + string(body)))
+	if want := "sha256=" + hex.EncodeToString(mac.Sum(nil)); received.Header.Get("X-Webhook-Signature") != want {
+		t.Fatalf("X-Webhook-Signature = %q, esperado %q", received.Header.Get("X-Webhook-Signature"), want)
+	}
+	if received.Header.Get("X-Webhook-ID") != delivery.ID || received.Header.Get("X-Request-ID") != "req-1" {
+		t.Fatalf("cabeçalhos = %v", received.Header)
+	}
+
+	var payload webhookPayload
+	if err := json.Unmarshal(body, &payload); err != nil {
+		t.Fatalf("corpo inválido: %v", err)
+	}
+	if payload.Event != "message.completed" || payload.DeliveryID != delivery.ID || payload.MessageID != "mensagem" || payload.Data.Response != "olá" {
+		t.Fatalf("payload = %+v", payload)
+	}
+}
+
+func TestWebhookHostAllowed(t *testing.T) {
+	d := NewWebhookDispatcher(WebhookConfig{
+		Secret:       testWebhookSecret,
+		AllowedHosts: []string{"*.example.com", "api.parceiro.com"},
+	}, zap.NewNop())
+
+	cases := map[string]bool{
+		"https://hooks.example.com/cb":       true,
+		"https://a.b.example.com/cb":         true,
+		"https://HOOKS.Example.com/cb":       true,
+		"https://example.com/cb":             false,
+		"https://evil-example.com/cb":        false,
+		"https://example.com.evil.com/cb":    false,
+		"http://api.parceiro.com:8443/cb":    true,
+		"https://x.api.parceiro.com/cb":      false,
+		"ftp://hooks.example.com/cb":         false,
+		"https://hooks.example.com@evil.com": false,
+	}
+	for rawURL, allowed := range cases {
+		if err := d.ValidateURL(rawURL); (err == nil) != allowed {
+			t.Errorf("ValidateURL(%q) = %v, esperado permitido = %v", rawURL, err, allowed)
+		}
+	}
+
+	// Sem segredo, os callbacks ficam desativados
+	disabled := NewWebhookDispatcher(WebhookConfig{AllowedHosts: []string{"*.example.com"}}, zap.NewNop())
+	if err := disabled.ValidateURL("https://hooks.example.com/cb"); err == nil {
+		t.Error("callback aceito sem WEBHOOK_SECRET")
+	}
+}
+
+func TestWebhookDoesNotFollowRedirects(t *testing.T) {
+	var redirected atomic.Int32
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		redirected.Add(1)
+	}))
+	defer target.Close()
+	receiver := httptest.NewServer(http.RedirectHandler(target.URL, http.StatusTemporaryRedirect))
+	defer receiver.Close()
+
+	delivery := deliverAndWait(t, newTestDispatcher(0), "mensagem", receiver.URL)
+	if redirected.Load() != 0 {
+		t.Fatal("o callback seguiu o redirecionamento")
+	}
+	if delivery.Status != "failed" || len(delivery.Attempts) != 1 || delivery.Attempts[0].StatusCode != http.StatusTemporaryRedirect {
+		t.Fatalf("entrega = %+v", delivery)
+	}
+}
+
+func TestWebhookRetriesServerErrors(t *testing.T) {
+	var calls atomic.Int32
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer receiver.Close()
+
+	delivery := deliverAndWait(t, newTestDispatcher(0), "mensagem", receiver.URL)
+	statuses := attemptStatuses(delivery)
+	if delivery.Status != "delivered" || len(statuses) != 2 || statuses[0] != http.StatusServiceUnavailable || statuses[1] != http.StatusOK {
+		t.Fatalf("entrega = %+v", delivery)
+	}
+	if delivery.Attempts[0].Error == "" || delivery.Attempts[1].Error != "" || delivery.CompletedAt == nil {
+		t.Fatalf("tentativas = %+v", delivery.Attempts)
+	}
+}
+
+func TestWebhookClientErrorFailsWithoutRetry(t *testing.T) {
+	var calls atomic.Int32
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer receiver.Close()
+
+	delivery := deliverAndWait(t, newTestDispatcher(0), "mensagem", receiver.URL)
+	if delivery.Status != "failed" || len(delivery.Attempts) != 1 || delivery.Attempts[0].StatusCode != http.StatusBadRequest {
+		t.Fatalf("entrega = %+v", delivery)
+	}
+	if calls.Load() != 1 {
+		t.Fatalf("chamadas = %d, esperado 1", calls.Load())
+	}
+}
+
+func TestWebhookDeliveryLog(t *testing.T) {
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer receiver.Close()
+
+	d := newTestDispatcher(2)
+	first := deliverAndWait(t, d, "primeira", receiver.URL)
+	second := deliverAndWait(t, d, "segunda", receiver.URL)
+	third := deliverAndWait(t, d, "terceira", receiver.URL)
+
+	// Mensagens sem callback_url não geram entregas
+	d.Deliver(PendingExecution{MessageID: "sem-callback"}, &models.ResponseData{Status: "completed"})
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/v1/webhooks/deliveries", listWebhookDeliveriesV1Handler(d))
+	mux.HandleFunc("GET /api/v1/webhooks/deliveries/{id}", getWebhookDeliveryV1Handler(d))
+	get := func(path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		return rec
+	}
+
+	// O log mantém as MaxDeliveries mais recentes, da mais recente para a mais antiga
+	var list struct {
+		Data []WebhookDelivery `json:"data"`
+	}
+	json.NewDecoder(get("/api/v1/webhooks/deliveries").Body).Decode(&list)
+	if len(list.Data) != 2 || list.Data[0].ID != third.ID || list.Data[1].ID != second.ID {
+		t.Fatalf("entregas = %+v", list.Data)
+	}
+	json.NewDecoder(get("/api/v1/webhooks/deliveries?message_id=segunda").Body).Decode(&list)
+	if len(list.Data) != 1 || list.Data[0].MessageID != "segunda" {
+		t.Fatalf("entregas da mensagem = %+v", list.Data)
+	}
+
+	var delivery WebhookDelivery
+	rec := get("/api/v1/webhooks/deliveries/" + third.ID)
+	json.NewDecoder(rec.Body).Decode(&delivery)
+	if rec.Code != http.StatusOK || delivery.ID != third.ID || delivery.Status != "delivered" || delivery.URL != receiver.URL {
+		t.Fatalf("entrega: status %d, %+v", rec.Code, delivery)
+	}
+	if rec := get("/api/v1/webhooks/deliveries/" + first.ID); rec.Code != http.StatusNotFound {
+		t.Fatalf("entrega descartada: status %d, esperado 404", rec.Code)
+	}
+}
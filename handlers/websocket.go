@@ -0,0 +1,380 @@
+package handlers
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/chatcomStackspotAI/llm"
+	"github.com/chatcomStackspotAI/middlewares"
+	"github.com/chatcomStackspotAI/models"
+	"github.com/chatcomStackspotAI/tracing"
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+	"go.opentelemetry.io/otel/attribute"
+	"go.uber.org/zap"
+)
+
+const (
+	wsWriteWait    = 10 * time.Second // Prazo de cada escrita na conexão
+	wsPongWait     = 60 * time.Second // Sem pong nesse prazo, a conexão é considerada perdida
+	wsPingInterval = 30 * time.Second
+	wsMaxMessage   = 4 << 20 // Tamanho máximo de um comando, incluindo o histórico
+	wsMaxInFlight  = 8       // Mensagens em andamento por conexão
+	wsMaxFinished  = 50      // Mensagens concluídas mantidas por conexão para regenerate
+)
+
+// O Upgrader padrão só aceita conexões da mesma origem da página
+var wsUpgrader = websocket.Upgrader{}
+
+// wsCommand é uma mensagem enviada pelo cliente em /ws
+type wsCommand struct {
+	Type      string           `json:"type"` // "prompt", "cancel", "regenerate" ou "set_provider"
+	MessageID string           `json:"message_id,omitempty"`
+	SessionID string           `json:"session_id,omitempty"`
+	Provider  string           `json:"provider,omitempty"`
+	Model     string           `json:"model,omitempty"`
+	Prompt    string           `json:"prompt,omitempty"`
+	History   []models.Message `json:"history,omitempty"`
+}
+
+// wsEvent é uma mensagem enviada pelo servidor em /ws
+type wsEvent struct {
+	Type            string           `json:"type"` // "accepted", "delta", "progress", "usage", "done", "cancelled", "error" ou "provider"
+	MessageID       string           `json:"message_id,omitempty"`
+	RegeneratedFrom string           `json:"regenerated_from,omitempty"`
+	Provider        string           `json:"provider,omitempty"`
+	Model           string           `json:"model,omitempty"`
+	Content         string           `json:"content,omitempty"`
+	Progress        float64          `json:"progress,omitempty"`
+	Usage           *llm.Usage       `json:"usage,omitempty"`
+	Error           *models.APIError `json:"error,omitempty"`
+}
+
+// wsMessage guarda o que é preciso para cancelar ou regenerar uma mensagem da conexão
+type wsMessage struct {
+	execution PendingExecution
+	prompt    string
+	history   []models.Message
+	cancel    context.CancelFunc // nil depois da conclusão
+}
+
+// wsConnection é o estado de uma conexão em /ws
+type wsConnection struct {
+	conn      *websocket.Conn
+	writeMu   sync.Mutex
+	ctx       context.Context
+	requestID string
+	manager   *llm.LLMManager
+	store     ResponseStore
+	logger    *zap.Logger
+
+	mu        sync.Mutex
+	sessionID string // Padrão dos prompts sem session_id (parâmetro session_id da URL)
+	provider  string // Padrão dos prompts sem provider, alterado por set_provider
+	model     string
+	messages  map[string]*wsMessage
+	finished  []string // message_id das mensagens concluídas, da mais antiga para a mais recente
+}
+
+// WebSocketHandler atende /ws: o cliente envia prompts e comandos de controle, e o servidor
+// envia os trechos da resposta, o progresso, o consumo e os erros de cada mensagem, identificada
+// pelo message_id. As mensagens também passam pelo ResponseStore, então podem ser consultadas
+// em /get-response, inclusive se a conexão cair antes da conclusão.
+func WebSocketHandler(manager *llm.LLMManager, store ResponseStore, logger *zap.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		conn, err := wsUpgrader.Upgrade(w, r, nil)
+		if err != nil {
+			// O Upgrader já respondeu com o erro
+			logger.Warn("Falha no upgrade para WebSocket", zap.Error(err))
+			return
+		}
+		defer conn.Close()
+
+		c := &wsConnection{
+			conn:      conn,
+			ctx:       r.Context(),
+			requestID: middlewares.RequestIDFromContext(r.Context()),
+			manager:   manager,
+			store:     store,
+			logger:    logger,
+			sessionID: r.URL.Query().Get("session_id"),
+			provider:  r.URL.Query().Get("provider"),
+			model:     r.URL.Query().Get("model"),
+			messages:  make(map[string]*wsMessage),
+		}
+		c.run()
+	}
+}
+
+func (c *wsConnection) run() {
+	c.conn.SetReadLimit(wsMaxMessage)
+	c.conn.SetReadDeadline(time.Now().Add(wsPongWait))
+	c.conn.SetPongHandler(func(string) error {
+		return c.conn.SetReadDeadline(time.Now().Add(wsPongWait))
+	})
+
+	done := make(chan struct{})
+	defer close(done)
+	go c.ping(done)
+
+	for {
+		_, payload, err := c.conn.ReadMessage()
+		if err != nil {
+			if websocket.IsUnexpectedCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+				c.logger.Warn("Conexão WebSocket encerrada", zap.String("request_id", c.requestID), zap.Error(err))
+			}
+			return
+		}
+
+		var command wsCommand
+		if err := json.Unmarshal(payload, &command); err != nil {
+			c.sendError("", ErrCodeInvalidRequest, "Comando inválido: "+err.Error())
+			continue
+		}
+		c.dispatch(command)
+	}
+}
+
+// ping mantém a conexão viva atrás de proxies e detecta clientes que sumiram
+func (c *wsConnection) ping(done <-chan struct{}) {
+	ticker := time.NewTicker(wsPingInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			if err := c.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(wsWriteWait)); err != nil {
+				return
+			}
+		}
+	}
+}
+
+func (c *wsConnection) dispatch(command wsCommand) {
+	switch command.Type {
+	case "prompt":
+		c.start(command, command.Prompt, command.History, "")
+	case "cancel":
+		c.cancel(command.MessageID)
+	case "regenerate":
+		c.regenerate(command)
+	case "set_provider":
+		c.setProvider(command)
+	default:
+		c.sendError(command.MessageID, ErrCodeInvalidRequest,
+			fmt.Sprintf("Tipo de comando desconhecido: %q", command.Type))
+	}
+}
+
+// start registra a mensagem e inicia a resposta em background
+func (c *wsConnection) start(command wsCommand, prompt string, history []models.Message, regeneratedFrom string) {
+	c.mu.Lock()
+	sessionID := cmp.Or(command.SessionID, c.sessionID)
+	provider, model := command.Provider, command.Model
+	if provider == "" {
+		provider, model = c.provider, cmp.Or(model, c.model)
+	}
+	inFlight := 0
+	for _, message := range c.messages {
+		if message.cancel != nil {
+			inFlight++
+		}
+	}
+	messageID := command.MessageID
+	if regeneratedFrom != "" || messageID == "" {
+		messageID = uuid.New().String()
+	}
+	_, duplicated := c.messages[messageID]
+	c.mu.Unlock()
+
+	switch {
+	case sessionID == "":
+		c.sendError(command.MessageID, ErrCodeInvalidRequest, "session_id não fornecido")
+		return
+	case provider == "":
+		c.sendError(command.MessageID, ErrCodeInvalidRequest, "provider não fornecido")
+		return
+	case duplicated:
+		c.sendError(command.MessageID, ErrCodeInvalidRequest, "message_id já utilizado nesta conexão")
+		return
+	case inFlight >= wsMaxInFlight:
+		c.sendError(command.MessageID, ErrCodeInvalidRequest,
+			fmt.Sprintf("Limite de %d mensagens simultâneas por conexão atingido", wsMaxInFlight))
+		return
+	}
+
+	client, err := c.manager.GetClientWithModel(c.ctx, provider, model)
+	if err != nil {
+		c.logger.Error("Erro ao obter o cliente LLM", zap.Error(err))
+		apiErr, _ := NewLLMAPIError(err, c.requestID)
+		c.send(wsEvent{Type: "error", MessageID: command.MessageID, Error: apiErr})
+		return
+	}
+
+	execution := PendingExecution{
+		SessionID: sessionID,
+		MessageID: messageID,
+		RequestID: c.requestID,
+		Provider:  provider,
+		Model:     model,
+		StartedAt: time.Now().UTC(),
+	}
+	if err := c.store.SetResponse(c.ctx, sessionID, messageID, &models.ResponseData{Status: "processing"}); err != nil {
+		c.logger.Error("Erro ao armazenar a mensagem", zap.Error(err))
+		c.sendError(command.MessageID, ErrCodeInternal, "Não foi possível registrar a mensagem")
+		return
+	}
+	if err := c.store.AddPending(c.ctx, execution); err != nil {
+		c.logger.Warn("Não foi possível registrar a execução pendente", zap.String("message_id", messageID), zap.Error(err))
+	}
+
+	// A resposta continua se a conexão cair; só o comando cancel a interrompe
+	ctx, cancel := context.WithCancel(context.WithoutCancel(c.ctx))
+	c.mu.Lock()
+	c.messages[messageID] = &wsMessage{execution: execution, prompt: prompt, history: history, cancel: cancel}
+	c.mu.Unlock()
+
+	c.send(wsEvent{Type: "accepted", MessageID: messageID, RegeneratedFrom: regeneratedFrom, Provider: provider, Model: model})
+	go c.respond(ctx, cancel, client, execution, prompt, history)
+}
+
+// respond transmite a resposta da LLM e a armazena, como em /send
+func (c *wsConnection) respond(ctx context.Context, cancel context.CancelFunc, client llm.LLMClient, execution PendingExecution, prompt string, history []models.Message) {
+	defer cancel()
+
+	ctx, span := tracing.Start(ctx, "WebSocketHandler.message", attribute.String("message_id", execution.MessageID))
+	ctx = llm.WithExecutionObserver(ctx, func(executionID string) {
+		execution.ExecutionID = executionID
+		if err := c.store.AddPending(ctx, execution); err != nil {
+			c.logger.Warn("Não foi possível salvar o ID da execução", zap.String("message_id", execution.MessageID), zap.Error(err))
+		}
+	})
+	ctx = llm.WithProgressObserver(ctx, func(progress float64) {
+		c.send(wsEvent{Type: "progress", MessageID: execution.MessageID, Progress: progress})
+	})
+
+	streamCtx, stop := context.WithTimeout(ctx, completionTimeout)
+	streamCtx, usage := llm.WithUsage(streamCtx)
+	// Falhas de escrita não interrompem a resposta, que segue para o ResponseStore
+	response, err := llm.Stream(streamCtx, client, prompt, history, func(chunk string) error {
+		c.send(wsEvent{Type: "delta", MessageID: execution.MessageID, Content: chunk})
+		return nil
+	})
+	stop()
+	tracing.End(span, err)
+
+	// As mensagens concluídas continuam disponíveis para regenerate, até o limite por conexão
+	c.mu.Lock()
+	c.messages[execution.MessageID].cancel = nil
+	c.finished = append(c.finished, execution.MessageID)
+	if len(c.finished) > wsMaxFinished {
+		delete(c.messages, c.finished[0])
+		c.finished = c.finished[1:]
+	}
+	c.mu.Unlock()
+
+	switch {
+	case err == nil:
+		total := usage()
+		c.send(wsEvent{Type: "usage", MessageID: execution.MessageID, Usage: &total})
+		c.send(wsEvent{Type: "done", MessageID: execution.MessageID, Content: response})
+	case errors.Is(ctx.Err(), context.Canceled):
+		c.send(wsEvent{Type: "cancelled", MessageID: execution.MessageID})
+	default:
+		apiErr, _ := NewLLMAPIError(err, c.requestID)
+		c.send(wsEvent{Type: "error", MessageID: execution.MessageID, Error: apiErr})
+	}
+	completeMessage(ctx, c.store, nil, execution, response, err, c.logger)
+}
+
+func (c *wsConnection) cancel(messageID string) {
+	c.mu.Lock()
+	message, exists := c.messages[messageID]
+	var cancel context.CancelFunc
+	if exists {
+		cancel = message.cancel
+	}
+	c.mu.Unlock()
+
+	if !exists {
+		c.sendError(messageID, ErrCodeNotFound, "message_id não encontrado nesta conexão")
+		return
+	}
+	// Mensagens já concluídas não têm o que cancelar
+	if cancel != nil {
+		cancel()
+	}
+}
+
+// regenerate reenvia o prompt de uma mensagem da conexão com um novo message_id, cancelando a
+// original se ainda estiver em andamento. O provider e o model podem ser trocados no comando.
+func (c *wsConnection) regenerate(command wsCommand) {
+	c.mu.Lock()
+	message, exists := c.messages[command.MessageID]
+	var original wsMessage
+	if exists {
+		original = *message
+	}
+	c.mu.Unlock()
+
+	if !exists {
+		c.sendError(command.MessageID, ErrCodeNotFound, "message_id não encontrado nesta conexão")
+		return
+	}
+	if original.cancel != nil {
+		original.cancel()
+	}
+
+	retry := wsCommand{
+		MessageID: command.MessageID, // Os erros se referem à mensagem original
+		SessionID: original.execution.SessionID,
+		Provider:  original.execution.Provider,
+		Model:     original.execution.Model,
+	}
+	if command.Provider != "" {
+		retry.Provider, retry.Model = command.Provider, command.Model
+	}
+	c.start(retry, original.prompt, original.history, command.MessageID)
+}
+
+// setProvider troca o provedor usado pelos próximos prompts que não informarem um
+func (c *wsConnection) setProvider(command wsCommand) {
+	if _, err := c.manager.GetClientWithModel(c.ctx, command.Provider, command.Model); err != nil {
+		apiErr, _ := NewLLMAPIError(err, c.requestID)
+		c.send(wsEvent{Type: "error", Error: apiErr})
+		return
+	}
+
+	c.mu.Lock()
+	c.provider, c.model = command.Provider, command.Model
+	c.mu.Unlock()
+
+	c.send(wsEvent{Type: "provider", Provider: command.Provider, Model: command.Model})
+}
+
+func (c *wsConnection) sendError(messageID, code, message string) {
+	c.send(wsEvent{Type: "error", MessageID: messageID, Error: &models.APIError{
+		Code:      code,
+		Message:   message,
+		RequestID: c.requestID,
+	}})
+}
+
+// send escreve um evento; as escritas são serializadas, pois a conexão admite um escritor por vez
+func (c *wsConnection) send(event wsEvent) {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	c.conn.SetWriteDeadline(time.Now().Add(wsWriteWait))
+	if err := c.conn.WriteJSON(event); err != nil {
+		c.logger.Debug("Não foi possível enviar o evento pelo WebSocket",
+			zap.String("type", event.Type), zap.String("message_id", event.MessageID), zap.Error(err))
+	}
+}
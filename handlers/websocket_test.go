@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/chatcomStackspotAI/llm/llmtest"
+	"github.com/gorilla/websocket"
+	"go.uber.org/zap"
+)
+
+// dialTestWebSocket abre uma conexão em /ws com um LLMManager apontado para o servidor falso
+func dialTestWebSocket(t *testing.T, fake *llmtest.Server) *websocket.Conn {
+	t.Helper()
+	handler := WebSocketHandler(newTestManager(t, fake), NewMemoryResponseStore(), zap.NewNop())
+	ts := httptest.NewServer(handler)
+	t.Cleanup(ts.Close)
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(ts.URL, "http")+"?session_id=sessao", nil)
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+// readUntil lê os eventos até encontrar um dos tipos informados
+func readUntil(t *testing.T, conn *websocket.Conn, types ...string) wsEvent {
+	t.Helper()
+	conn.SetReadDeadline(time.Now().Add(10 * time.Second))
+	for {
+		var event wsEvent
+		if err := conn.ReadJSON(&event); err != nil {
+			t.Fatalf("ReadJSON: %v", err)
+		}
+		for _, eventType := range types {
+			if event.Type == eventType {
+				return event
+			}
+		}
+	}
+}
+
+func TestWebSocketUsesRequestedModel(t *testing.T) {
+	fake := llmtest.NewServer()
+	defer fake.Close()
+	conn := dialTestWebSocket(t, fake)
+
+	conn.WriteJSON(wsCommand{Type: "set_provider", Provider: "OPENAI", Model: "gpt-4o-mini"})
+	if event := readUntil(t, conn, "provider", "error"); event.Type != "provider" || event.Model != "gpt-4o-mini" {
+		t.Fatalf("set_provider = %+v", event)
+	}
+
+	conn.WriteJSON(wsCommand{Type: "prompt", MessageID: "m1", Prompt: "oi"})
+	if event := readUntil(t, conn, "done", "error"); event.Type != "done" {
+		t.Fatalf("prompt = %+v", event)
+	}
+	if body := fake.Requests(llmtest.RouteOpenAIChat)[0].Body; !strings.Contains(body, `"model":"gpt-4o-mini"`) {
+		t.Fatalf("corpo enviado à OpenAI sem o modelo escolhido: %s", body)
+	}
+}
+
+func TestWebSocketKeepsLimitedFinishedMessages(t *testing.T) {
+	fake := llmtest.NewServer()
+	defer fake.Close()
+	conn := dialTestWebSocket(t, fake)
+
+	for i := 0; i <= wsMaxFinished; i++ {
+		conn.WriteJSON(wsCommand{Type: "prompt", MessageID: fmt.Sprintf("m%d", i), Provider: "OPENAI", Prompt: "oi"})
+		if event := readUntil(t, conn, "done", "error"); event.Type != "done" {
+			t.Fatalf("prompt %d = %+v", i, event)
+		}
+	}
+
+	// A mais antiga foi descartada; a mais recente ainda pode ser regenerada
+	conn.WriteJSON(wsCommand{Type: "regenerate", MessageID: "m0"})
+	if event := readUntil(t, conn, "accepted", "error"); event.Type != "error" || event.Error.Code != ErrCodeNotFound {
+		t.Fatalf("regenerate da mensagem descartada = %+v", event)
+	}
+	conn.WriteJSON(wsCommand{Type: "regenerate", MessageID: fmt.Sprintf("m%d", wsMaxFinished)})
+	if event := readUntil(t, conn, "accepted", "error"); event.Type != "accepted" {
+		t.Fatalf("regenerate da mensagem recente = %+v", event)
+	}
+}
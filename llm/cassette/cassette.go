@@ -0,0 +1,321 @@
+// Package cassette grava e reproduz o tráfego HTTP com os provedores de LLM,
+// permitindo demos determinísticas, testes de regressão e desenvolvimento offline.
+package cassette
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// Modos de operação aceitos em LLM_CASSETTE_MODE
+const (
+	ModeRecord = "record"
+	ModeReplay = "replay"
+)
+
+const redacted = "REDACTED"
+
+// Cabeçalhos e campos que nunca são gravados em claro
+var (
+	sensitiveHeaders = []string{"Authorization", "X-Api-Key", "Proxy-Authorization", "Cookie", "Set-Cookie"}
+	sensitiveFields  = map[string]bool{
+		"client_id":     true,
+		"client_secret": true,
+		"access_token":  true,
+		"refresh_token": true,
+		"id_token":      true,
+		"api_key":       true,
+	}
+	// Parâmetros gerados a cada chamada que não participam da identificação da interação
+	volatileQueryParams = []string{"conversation_id"}
+)
+
+// ErrInteractionNotFound é retornado no modo replay quando a requisição não foi gravada
+var ErrInteractionNotFound = errors.New("interação não encontrada no cassette")
+
+// Interaction é um par requisição/resposta gravado
+type Interaction struct {
+	Key      string   `json:"key"`
+	Request  Request  `json:"request"`
+	Response Response `json:"response"`
+}
+
+type Request struct {
+	Method string      `json:"method"`
+	URL    string      `json:"url"`
+	Header http.Header `json:"header,omitempty"`
+	Body   string      `json:"body,omitempty"`
+}
+
+type Response struct {
+	StatusCode int         `json:"status_code"`
+	Header     http.Header `json:"header,omitempty"`
+	Body       string      `json:"body,omitempty"`
+}
+
+type cassetteFile struct {
+	Interactions []Interaction `json:"interactions"`
+}
+
+// Path retorna o arquivo do cassette com o nome informado dentro do diretório
+func Path(dir, name string) string {
+	if name == "" {
+		name = "default"
+	}
+	return filepath.Join(dir, name+".json")
+}
+
+// Recorder é um http.RoundTripper que repassa as requisições e grava as interações redigidas
+type Recorder struct {
+	next http.RoundTripper
+	path string
+
+	mu           sync.Mutex
+	interactions []Interaction
+}
+
+// NewRecorder cria um Recorder que acrescenta as interações ao cassette em path
+func NewRecorder(next http.RoundTripper, path string) (*Recorder, error) {
+	existing, err := load(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("erro ao criar o diretório do cassette: %w", err)
+	}
+	return &Recorder{
+		next:         next,
+		path:         path,
+		interactions: existing,
+	}, nil
+}
+
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	reqBody, err := readRequestBody(req)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := r.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	respBody, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(respBody))
+
+	recordedReq := Request{
+		Method: req.Method,
+		URL:    req.URL.String(),
+		Header: redactHeader(req.Header),
+		Body:   redactBody(reqBody, req.Header.Get("Content-Type")),
+	}
+	interaction := Interaction{
+		Key:     interactionKey(req, recordedReq.Body),
+		Request: recordedReq,
+		Response: Response{
+			StatusCode: resp.StatusCode,
+			Header:     redactHeader(resp.Header),
+			Body:       redactBody(respBody, resp.Header.Get("Content-Type")),
+		},
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.interactions = append(r.interactions, interaction)
+	if err := save(r.path, r.interactions); err != nil {
+		return nil, err
+	}
+
+	return resp, nil
+}
+
+// Player é um http.RoundTripper que responde a partir de um cassette gravado, sem acessar a rede.
+// Requisições idênticas (ex.: consultas ao callback da StackSpot) recebem as respostas na ordem
+// em que foram gravadas; a última se repete quando a sequência se esgota.
+type Player struct {
+	mu        sync.Mutex
+	responses map[string][]Response
+	served    map[string]int
+}
+
+// NewPlayer carrega o cassette em path
+func NewPlayer(path string) (*Player, error) {
+	interactions, err := load(path)
+	if err != nil {
+		return nil, err
+	}
+
+	player := &Player{
+		responses: make(map[string][]Response),
+		served:    make(map[string]int),
+	}
+	for _, interaction := range interactions {
+		player.responses[interaction.Key] = append(player.responses[interaction.Key], interaction.Response)
+	}
+	return player, nil
+}
+
+func (p *Player) RoundTrip(req *http.Request) (*http.Response, error) {
+	reqBody, err := readRequestBody(req)
+	if err != nil {
+		return nil, err
+	}
+	key := interactionKey(req, redactBody(reqBody, req.Header.Get("Content-Type")))
+
+	p.mu.Lock()
+	responses := p.responses[key]
+	if len(responses) == 0 {
+		p.mu.Unlock()
+		return nil, fmt.Errorf("%w: %s %s", ErrInteractionNotFound, req.Method, req.URL.Path)
+	}
+	index := p.served[key]
+	if index >= len(responses) {
+		index = len(responses) - 1
+	}
+	p.served[key]++
+	recorded := responses[index]
+	p.mu.Unlock()
+
+	header := recorded.Header.Clone()
+	if header == nil {
+		header = http.Header{}
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", recorded.StatusCode, http.StatusText(recorded.StatusCode)),
+		StatusCode:    recorded.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(strings.NewReader(recorded.Body)),
+		ContentLength: int64(len(recorded.Body)),
+		Request:       req,
+	}, nil
+}
+
+// readRequestBody lê o corpo da requisição e o restaura para o transporte seguinte
+func readRequestBody(req *http.Request) ([]byte, error) {
+	if req.Body == nil {
+		return nil, nil
+	}
+	body, err := io.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return nil, fmt.Errorf("erro ao ler o corpo da requisição: %w", err)
+	}
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	return body, nil
+}
+
+// interactionKey identifica a requisição pelo método, URL estável e corpo redigido
+func interactionKey(req *http.Request, redactedBody string) string {
+	u := *req.URL
+	query := u.Query()
+	for _, param := range volatileQueryParams {
+		query.Del(param)
+	}
+	u.RawQuery = query.Encode()
+
+	sum := sha256.Sum256([]byte(req.Method + " " + u.String() + "\n" + redactedBody))
+	return hex.EncodeToString(sum[:])
+}
+
+func redactHeader(header http.Header) http.Header {
+	clone := header.Clone()
+	for _, name := range sensitiveHeaders {
+		if clone.Get(name) != "" {
+			clone.Set(name, redacted)
+		}
+	}
+	return clone
+}
+
+// redactBody remove credenciais de corpos JSON e de formulários
+func redactBody(body []byte, contentType string) string {
+	if len(body) == 0 {
+		return ""
+	}
+
+	if strings.HasPrefix(contentType, "application/x-www-form-urlencoded") {
+		values, err := url.ParseQuery(string(body))
+		if err == nil {
+			for field := range values {
+				if sensitiveFields[field] {
+					values.Set(field, redacted)
+				}
+			}
+			return values.Encode()
+		}
+	}
+
+	var payload interface{}
+	if err := json.Unmarshal(body, &payload); err == nil {
+		if redactJSON(payload) {
+			if redactedBody, err := json.Marshal(payload); err == nil {
+				return string(redactedBody)
+			}
+		}
+	}
+	return string(body)
+}
+
+// redactJSON substitui os campos sensíveis e indica se algo foi alterado
+func redactJSON(value interface{}) bool {
+	changed := false
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, inner := range v {
+			if sensitiveFields[key] {
+				v[key] = redacted
+				changed = true
+				continue
+			}
+			changed = redactJSON(inner) || changed
+		}
+	case []interface{}:
+		for _, inner := range v {
+			changed = redactJSON(inner) || changed
+		}
+	}
+	return changed
+}
+
+func load(path string) ([]Interaction, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var file cassetteFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("cassette %s inválido: %w", path, err)
+	}
+	return file.Interactions, nil
+}
+
+func save(path string, interactions []Interaction) error {
+	data, err := json.MarshalIndent(cassetteFile{Interactions: interactions}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("erro ao serializar o cassette: %w", err)
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return fmt.Errorf("erro ao gravar o cassette: %w", err)
+	}
+	return os.Rename(tmp, path)
+}
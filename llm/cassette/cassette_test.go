@@ -0,0 +1,142 @@
+package cassette_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/chatcomStackspotAI/llm"
+	"github.com/chatcomStackspotAI/llm/cassette"
+	"github.com/chatcomStackspotAI/llm/llmtest"
+	"go.uber.org/zap"
+)
+
+// sendPrompt envia o prompt por um LLMManager configurado pelas variáveis de ambiente atuais
+func sendPrompt(t *testing.T, provider, prompt string) (string, error) {
+	t.Helper()
+	manager, err := llm.NewLLMManager(zap.NewNop())
+	if err != nil {
+		t.Fatalf("NewLLMManager: %v", err)
+	}
+	defer manager.Close()
+
+	client, err := manager.GetClient(context.Background(), provider, "")
+	if err != nil {
+		t.Fatalf("GetClient(%s): %v", provider, err)
+	}
+	return client.SendPrompt(context.Background(), prompt, nil)
+}
+
+func TestRecordAndReplay(t *testing.T) {
+	dir := t.TempDir()
+	fake := llmtest.NewServer()
+	fake.StackSpotPollsUntilDone = 3
+	fake.Setenv(t)
+	t.Setenv("STACKSPOT_POLL_INITIAL_INTERVAL", "1ms")
+	t.Setenv("STACKSPOT_POLL_MAX_INTERVAL", "5ms")
+	t.Setenv("LLM_CASSETTE_DIR", dir)
+	t.Setenv("LLM_CASSETTE_NAME", "fluxo")
+
+	// Gravação contra o servidor falso
+	t.Setenv("LLM_CASSETTE_MODE", cassette.ModeRecord)
+	recorded := make(map[string]string)
+	for _, provider := range []string{llm.ProviderOpenAI, llm.ProviderClaudeAI, llm.ProviderStackSpot} {
+		response, err := sendPrompt(t, provider, "oi")
+		if err != nil {
+			t.Fatalf("gravação %s: %v", provider, err)
+		}
+		recorded[provider] = response
+	}
+	fake.Close()
+
+	data, err := os.ReadFile(filepath.Join(dir, "fluxo.json"))
+	if err != nil {
+		t.Fatalf("cassette não gravado: %v", err)
+	}
+	for _, secret := range []string{"fake-openai-key", "fake-anthropic-key", "fake-client-id", "fake-client-secret", "fake-token-"} {
+		if strings.Contains(string(data), secret) {
+			t.Errorf("o cassette contém %q em claro", secret)
+		}
+	}
+
+	// Reprodução sem credenciais e com o servidor desligado
+	t.Setenv("LLM_CASSETTE_MODE", cassette.ModeReplay)
+	for _, key := range []string{"OPENAI_API_KEY", "CLAUDEAI_API_KEY", "CLIENT_ID", "CLIENT_SECRET"} {
+		t.Setenv(key, "")
+	}
+	for provider, want := range recorded {
+		response, err := sendPrompt(t, provider, "oi")
+		if err != nil {
+			t.Fatalf("reprodução %s: %v", provider, err)
+		}
+		if response != want {
+			t.Errorf("reprodução %s = %q, gravado %q", provider, response, want)
+		}
+	}
+}
+
+func TestPlayerRepeatsSequenceAndRejectsUnknown(t *testing.T) {
+	fake := llmtest.NewServer()
+	defer fake.Close()
+	fake.StackSpotPollsUntilDone = 2
+	path := cassette.Path(t.TempDir(), "")
+
+	recorder, err := cassette.NewRecorder(http.DefaultTransport, path)
+	if err != nil {
+		t.Fatalf("NewRecorder: %v", err)
+	}
+	// createAndPoll cria a execução e retorna o status informado em cada consulta ao callback
+	createAndPoll := func(client *http.Client) []string {
+		token := "Bearer fake-token-1"
+		req, _ := http.NewRequest(http.MethodPost, fake.StackSpotURL()+"/quick-commands/create-execution/fake-slug", strings.NewReader(`{"input_data":"oi"}`))
+		req.Header.Set("Authorization", token)
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatalf("create-execution: %v", err)
+		}
+		resp.Body.Close()
+
+		var statuses []string
+		for i := 0; i < 3; i++ {
+			req, _ := http.NewRequest(http.MethodGet, fake.StackSpotURL()+"/quick-commands/callback/exec-1", nil)
+			req.Header.Set("Authorization", token)
+			resp, err := client.Do(req)
+			if err != nil {
+				t.Fatalf("callback: %v", err)
+			}
+			var callback llm.CallbackResponse
+			json.NewDecoder(resp.Body).Decode(&callback)
+			resp.Body.Close()
+			statuses = append(statuses, callback.Progress.Status)
+		}
+		return statuses
+	}
+	want := createAndPoll(&http.Client{Transport: recorder})
+	if strings.Join(want, ",") != "RUNNING,COMPLETED,COMPLETED" {
+		t.Fatalf("consultas gravadas = %v", want)
+	}
+
+	player, err := cassette.NewPlayer(path)
+	if err != nil {
+		t.Fatalf("NewPlayer: %v", err)
+	}
+	client := &http.Client{Transport: player}
+	if got := createAndPoll(client); strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Fatalf("consultas reproduzidas = %v, gravadas %v", got, want)
+	}
+	// Além das 3 consultas gravadas, a última resposta se repete
+	req, _ := http.NewRequest(http.MethodGet, fake.StackSpotURL()+"/quick-commands/callback/exec-1", nil)
+	if resp, err := client.Do(req); err != nil || resp.StatusCode != http.StatusOK {
+		t.Fatalf("consulta extra: %v", err)
+	}
+
+	_, err = client.Get(fake.StackSpotURL() + "/quick-commands/callback/outra")
+	if !errors.Is(err, cassette.ErrInteractionNotFound) {
+		t.Fatalf("erro = %v, esperado ErrInteractionNotFound", err)
+	}
+}
@@ -0,0 +1,259 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/chatcomStackspotAI/metrics"
+	"github.com/chatcomStackspotAI/models"
+	"github.com/chatcomStackspotAI/retry"
+	"go.uber.org/zap"
+)
+
+// Estados possíveis do circuit breaker
+const (
+	BreakerClosed   = "closed"
+	BreakerOpen     = "open"
+	BreakerHalfOpen = "half-open"
+)
+
+// BreakerConfig define quando o circuito abre e por quanto tempo permanece aberto
+type BreakerConfig struct {
+	FailureThreshold int           // Falhas consecutivas que abrem o circuito
+	OpenTimeout      time.Duration // Tempo em aberto antes de permitir chamadas de teste
+	HalfOpenMaxCalls int           // Chamadas de teste simultâneas no estado half-open
+}
+
+// DefaultBreakerConfig retorna a configuração padrão: abre após 5 falhas por 30s
+func DefaultBreakerConfig() BreakerConfig {
+	return BreakerConfig{
+		FailureThreshold: 5,
+		OpenTimeout:      30 * time.Second,
+		HalfOpenMaxCalls: 1,
+	}
+}
+
+// BreakerConfigFromEnv sobrescreve a configuração com <PREFIX>_BREAKER_FAILURE_THRESHOLD,
+// <PREFIX>_BREAKER_OPEN_TIMEOUT e <PREFIX>_BREAKER_HALF_OPEN_MAX_CALLS, quando definidas
+func BreakerConfigFromEnv(prefix string, base BreakerConfig) BreakerConfig {
+	if v, err := strconv.Atoi(os.Getenv(prefix + "_BREAKER_FAILURE_THRESHOLD")); err == nil && v > 0 {
+		base.FailureThreshold = v
+	}
+	if v, err := time.ParseDuration(os.Getenv(prefix + "_BREAKER_OPEN_TIMEOUT")); err == nil && v > 0 {
+		base.OpenTimeout = v
+	}
+	if v, err := strconv.Atoi(os.Getenv(prefix + "_BREAKER_HALF_OPEN_MAX_CALLS")); err == nil && v > 0 {
+		base.HalfOpenMaxCalls = v
+	}
+	return base
+}
+
+// CircuitOpenError é retornado sem chamar o provedor enquanto o circuito está aberto
+type CircuitOpenError struct {
+	Provider string
+	RetryIn  time.Duration
+}
+
+func (e *CircuitOpenError) Error() string {
+	return fmt.Sprintf("o provedor %s está temporariamente indisponível; tente novamente em %s",
+		e.Provider, e.RetryIn.Round(time.Second))
+}
+
+// BreakerStatus é o retrato do estado atual de um circuit breaker
+type BreakerStatus struct {
+	State               string     `json:"state"`
+	ConsecutiveFailures int        `json:"consecutive_failures"`
+	OpenUntil           *time.Time `json:"open_until,omitempty"`
+}
+
+// BreakerTicket identifica uma chamada autorizada por Allow e deve ser devolvido a Done
+type BreakerTicket struct {
+	generation uint64 // Período de estado em que a chamada foi autorizada
+	probe      bool   // Chamada de teste do estado half-open
+}
+
+// CircuitBreaker interrompe as chamadas a um provedor após falhas consecutivas
+type CircuitBreaker struct {
+	provider string
+	config   BreakerConfig
+	logger   *zap.Logger
+
+	mu               sync.Mutex
+	state            string
+	generation       uint64 // Incrementado a cada mudança de estado
+	failures         int
+	openedAt         time.Time
+	halfOpenInFlight int
+}
+
+func NewCircuitBreaker(provider string, config BreakerConfig, logger *zap.Logger) *CircuitBreaker {
+	cb := &CircuitBreaker{
+		provider: provider,
+		config:   config,
+		logger:   logger,
+		state:    BreakerClosed,
+	}
+	cb.publishState()
+	return cb
+}
+
+// Allow verifica se uma chamada pode prosseguir; quando permitida, Done deve ser chamado com o
+// ticket retornado e o resultado
+func (cb *CircuitBreaker) Allow() (BreakerTicket, error) {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	switch cb.state {
+	case BreakerOpen:
+		remaining := cb.config.OpenTimeout - time.Since(cb.openedAt)
+		if remaining > 0 {
+			return BreakerTicket{}, &CircuitOpenError{Provider: cb.provider, RetryIn: remaining}
+		}
+		cb.setState(BreakerHalfOpen)
+		fallthrough
+	case BreakerHalfOpen:
+		if cb.halfOpenInFlight >= cb.config.HalfOpenMaxCalls {
+			return BreakerTicket{}, &CircuitOpenError{Provider: cb.provider, RetryIn: cb.config.OpenTimeout}
+		}
+		cb.halfOpenInFlight++
+		return BreakerTicket{generation: cb.generation, probe: true}, nil
+	}
+	return BreakerTicket{generation: cb.generation}, nil
+}
+
+// Done registra o resultado de uma chamada autorizada por Allow. Só os resultados de chamadas
+// autorizadas no estado atual mudam o circuito: uma chamada lenta iniciada antes de o circuito
+// abrir não o fecha nem conta como teste, e cancelamentos não mudam o estado.
+func (cb *CircuitBreaker) Done(ticket BreakerTicket, err error) {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	if ticket.generation != cb.generation {
+		return
+	}
+	if ticket.probe && cb.halfOpenInFlight > 0 {
+		cb.halfOpenInFlight--
+	}
+	if errors.Is(err, context.Canceled) {
+		return
+	}
+
+	if !isProviderFailure(err) {
+		// Só o sucesso zera a contagem: erros de requisição (ex.: 400, 401) intercalados com
+		// falhas do provedor não podem impedir a abertura durante uma indisponibilidade. A
+		// chamada de teste que chega ao provedor, com sucesso ou não, fecha o circuito.
+		if err == nil || ticket.probe {
+			cb.failures = 0
+		}
+		if ticket.probe {
+			cb.setState(BreakerClosed)
+		}
+		return
+	}
+
+	cb.failures++
+	if ticket.probe || cb.failures >= cb.config.FailureThreshold {
+		cb.openedAt = time.Now()
+		cb.setState(BreakerOpen)
+	}
+}
+
+// Status retorna o estado atual do circuito
+func (cb *CircuitBreaker) Status() BreakerStatus {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	status := BreakerStatus{
+		State:               cb.state,
+		ConsecutiveFailures: cb.failures,
+	}
+	if cb.state == BreakerOpen {
+		openUntil := cb.openedAt.Add(cb.config.OpenTimeout)
+		status.OpenUntil = &openUntil
+	}
+	return status
+}
+
+// setState inicia um novo período de estado; deve ser chamado com o mutex adquirido
+func (cb *CircuitBreaker) setState(state string) {
+	if cb.state == state {
+		return
+	}
+	cb.logger.Warn("Mudança de estado do circuit breaker",
+		zap.String("provider", cb.provider),
+		zap.String("from", cb.state),
+		zap.String("to", state),
+		zap.Int("consecutive_failures", cb.failures))
+	cb.state = state
+	cb.generation++
+	cb.halfOpenInFlight = 0
+	cb.publishState()
+}
+
+func (cb *CircuitBreaker) publishState() {
+	value := 0.0
+	switch cb.state {
+	case BreakerHalfOpen:
+		value = 1
+	case BreakerOpen:
+		value = 2
+	}
+	metrics.CircuitBreakerState.WithLabelValues(cb.provider).Set(value)
+}
+
+// isProviderFailure indica se o erro sugere indisponibilidade do provedor;
+// cancelamentos e erros de requisição (ex.: 400, 401) não abrem o circuito
+func isProviderFailure(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) {
+		return false
+	}
+	return retry.Retryable(err) || errors.Is(err, context.DeadlineExceeded)
+}
+
+// breakerClient envolve um LLMClient com o circuit breaker do seu provedor
+type breakerClient struct {
+	LLMClient
+	breaker *CircuitBreaker
+}
+
+func newBreakerClient(client LLMClient, breaker *CircuitBreaker) *breakerClient {
+	return &breakerClient{
+		LLMClient: client,
+		breaker:   breaker,
+	}
+}
+
+func (c *breakerClient) SendPrompt(ctx context.Context, prompt string, history []models.Message) (string, error) {
+	ticket, err := c.breaker.Allow()
+	if err != nil {
+		return "", err
+	}
+	response, err := c.LLMClient.SendPrompt(ctx, prompt, history)
+	c.breaker.Done(ticket, err)
+	return response, err
+}
+
+func (c *breakerClient) SendPromptStream(ctx context.Context, prompt string, history []models.Message, onChunk func(chunk string) error) (string, error) {
+	ticket, err := c.breaker.Allow()
+	if err != nil {
+		return "", err
+	}
+	response, err := Stream(ctx, c.LLMClient, prompt, history, onChunk)
+	c.breaker.Done(ticket, err)
+	return response, err
+}
+
+func (c *breakerClient) ResumeExecution(ctx context.Context, executionID string) (string, error) {
+	ticket, err := c.breaker.Allow()
+	if err != nil {
+		return "", err
+	}
+	response, err := Resume(ctx, c.LLMClient, executionID)
+	c.breaker.Done(ticket, err)
+	return response, err
+}
@@ -0,0 +1,136 @@
+package llm
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/chatcomStackspotAI/retry"
+	"go.uber.org/zap"
+)
+
+// providerFailure é um erro que conta como indisponibilidade do provedor
+var providerFailure = &retry.HTTPError{StatusCode: http.StatusServiceUnavailable}
+
+func newTestBreaker(t *testing.T) *CircuitBreaker {
+	t.Helper()
+	return NewCircuitBreaker("TEST", BreakerConfig{
+		FailureThreshold: 2,
+		OpenTimeout:      20 * time.Millisecond,
+		HalfOpenMaxCalls: 1,
+	}, zap.NewNop())
+}
+
+func mustAllow(t *testing.T, cb *CircuitBreaker) BreakerTicket {
+	t.Helper()
+	ticket, err := cb.Allow()
+	if err != nil {
+		t.Fatalf("Allow recusou a chamada: %v", err)
+	}
+	return ticket
+}
+
+// trip abre o circuito com falhas consecutivas
+func trip(t *testing.T, cb *CircuitBreaker) {
+	t.Helper()
+	for i := 0; i < 2; i++ {
+		cb.Done(mustAllow(t, cb), providerFailure)
+	}
+	if state := cb.Status().State; state != BreakerOpen {
+		t.Fatalf("estado = %s, esperado %s", state, BreakerOpen)
+	}
+}
+
+func TestCircuitBreakerOpensAndRecovers(t *testing.T) {
+	cb := newTestBreaker(t)
+	trip(t, cb)
+
+	if _, err := cb.Allow(); err == nil {
+		t.Fatal("Allow deveria recusar com o circuito aberto")
+	}
+
+	time.Sleep(25 * time.Millisecond)
+	probe := mustAllow(t, cb)
+	if _, err := cb.Allow(); err == nil {
+		t.Fatal("Allow deveria limitar as chamadas de teste do estado half-open")
+	}
+	cb.Done(probe, nil)
+	if state := cb.Status().State; state != BreakerClosed {
+		t.Fatalf("estado = %s, esperado %s", state, BreakerClosed)
+	}
+}
+
+func TestCircuitBreakerFailedProbeReopens(t *testing.T) {
+	cb := newTestBreaker(t)
+	trip(t, cb)
+	time.Sleep(25 * time.Millisecond)
+
+	cb.Done(mustAllow(t, cb), providerFailure)
+	if state := cb.Status().State; state != BreakerOpen {
+		t.Fatalf("estado = %s, esperado %s", state, BreakerOpen)
+	}
+}
+
+func TestCircuitBreakerIgnoresLateCallsFromBeforeTrip(t *testing.T) {
+	cb := newTestBreaker(t)
+	slow := mustAllow(t, cb)
+	trip(t, cb)
+
+	// A chamada lenta autorizada com o circuito fechado termina com sucesso depois de ele abrir
+	cb.Done(slow, nil)
+	if state := cb.Status().State; state != BreakerOpen {
+		t.Fatalf("sucesso atrasado mudou o estado para %s", state)
+	}
+
+	// Nem libera a vaga da chamada de teste do estado half-open
+	time.Sleep(25 * time.Millisecond)
+	probe := mustAllow(t, cb)
+	cb.Done(slow, nil)
+	if _, err := cb.Allow(); err == nil {
+		t.Fatal("chamada atrasada liberou a vaga da chamada de teste")
+	}
+	cb.Done(probe, nil)
+	if state := cb.Status().State; state != BreakerClosed {
+		t.Fatalf("estado = %s, esperado %s", state, BreakerClosed)
+	}
+}
+
+func TestCircuitBreakerCancelledProbeKeepsHalfOpen(t *testing.T) {
+	cb := newTestBreaker(t)
+	trip(t, cb)
+	time.Sleep(25 * time.Millisecond)
+
+	cb.Done(mustAllow(t, cb), context.Canceled)
+	if state := cb.Status().State; state != BreakerHalfOpen {
+		t.Fatalf("estado = %s, esperado %s", state, BreakerHalfOpen)
+	}
+	// A vaga de teste é liberada para uma nova tentativa
+	cb.Done(mustAllow(t, cb), nil)
+	if state := cb.Status().State; state != BreakerClosed {
+		t.Fatalf("estado = %s, esperado %s", state, BreakerClosed)
+	}
+}
+
+func TestCircuitBreakerClientErrorsDoNotResetFailures(t *testing.T) {
+	cb := newTestBreaker(t)
+	clientError := &retry.HTTPError{StatusCode: http.StatusBadRequest}
+
+	cb.Done(mustAllow(t, cb), providerFailure)
+	cb.Done(mustAllow(t, cb), clientError)
+	cb.Done(mustAllow(t, cb), providerFailure)
+	if state := cb.Status().State; state != BreakerOpen {
+		t.Fatalf("estado = %s, esperado %s", state, BreakerOpen)
+	}
+}
+
+func TestCircuitBreakerSuccessResetsFailures(t *testing.T) {
+	cb := newTestBreaker(t)
+
+	cb.Done(mustAllow(t, cb), providerFailure)
+	cb.Done(mustAllow(t, cb), nil)
+	cb.Done(mustAllow(t, cb), providerFailure)
+	if state := cb.Status().State; state != BreakerClosed {
+		t.Fatalf("estado = %s, esperado %s", state, BreakerClosed)
+	}
+}
@@ -5,28 +5,46 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"github.com/chatcomStackspotAI/metrics"
 	"github.com/chatcomStackspotAI/models"
+	"github.com/chatcomStackspotAI/retry"
+	"github.com/chatcomStackspotAI/tracing"
+	"go.opentelemetry.io/otel/attribute"
 	"go.uber.org/zap"
 	"io"
 	"net/http"
 	"time"
 )
 
+// DefaultClaudeAIBaseURL é a URL base da API da Anthropic
+const DefaultClaudeAIBaseURL = "https://api.anthropic.com/v1"
+
+// ClaudeAIConfig reúne as opções de construção do ClaudeAIClient
+type ClaudeAIConfig struct {
+	APIKey      string
+	Model       string
+	BaseURL     string       // Padrão: DefaultClaudeAIBaseURL
+	HTTPClient  *http.Client // Padrão: cliente com timeout de 180s
+	RetryPolicy retry.Policy // Valor zero: uma única tentativa
+}
+
 type ClaudeAIClient struct {
-	apiKey string
-	model  string
-	logger *zap.Logger
-	client *http.Client
+	apiKey      string
+	model       string
+	baseURL     string
+	retryPolicy retry.Policy
+	logger      *zap.Logger
+	client      *http.Client
 }
 
-func NewClaudeAIClient(apiKey, model string, logger *zap.Logger) *ClaudeAIClient {
+func NewClaudeAIClient(config ClaudeAIConfig, logger *zap.Logger) *ClaudeAIClient {
 	return &ClaudeAIClient{
-		apiKey: apiKey,
-		model:  model,
-		logger: logger,
-		client: &http.Client{
-			Timeout: time.Second * 30,
-		},
+		apiKey:      config.APIKey,
+		model:       config.Model,
+		baseURL:     baseURLOrDefault(config.BaseURL, DefaultClaudeAIBaseURL),
+		retryPolicy: config.RetryPolicy,
+		logger:      logger,
+		client:      httpClientOrDefault(config.HTTPClient, 180*time.Second),
 	}
 }
 
@@ -43,13 +61,44 @@ func (c *ClaudeAIClient) SendPrompt(ctx context.Context, prompt string, history
 		"max_tokens": 8192,
 		"system":     "You are a helpful AI assistant.", // Opcional: mensagem do sistema
 	}
+	options := generationOptions(ctx)
+	if options.Temperature != nil {
+		reqBody["temperature"] = *options.Temperature
+	}
+	if options.MaxTokens != nil {
+		reqBody["max_tokens"] = *options.MaxTokens
+	}
 
 	jsonData, err := json.Marshal(reqBody)
 	if err != nil {
 		return "", fmt.Errorf("erro ao serializar request: %w", err)
 	}
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, "https://api.anthropic.com/v1/messages", bytes.NewBuffer(jsonData))
+	var response string
+	err = retry.Do(ctx, c.retryPolicy, func(ctx context.Context, attempt int) error {
+		var err error
+		response, err = c.doRequest(ctx, jsonData, attempt)
+		return err
+	}, func(attempt int, err error, delay time.Duration) {
+		c.logger.Warn("Erro temporário ao chamar ClaudeAI",
+			zap.Int("attempt", attempt), zap.Duration("retry_in", delay), zap.Error(err))
+		metrics.LLMRetriesTotal.WithLabelValues(ProviderClaudeAI, "messages").Inc()
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return response, nil
+}
+
+// doRequest executa uma única chamada HTTP à API de mensagens
+func (c *ClaudeAIClient) doRequest(ctx context.Context, body []byte, attempt int) (response string, err error) {
+	ctx, span := tracing.Start(ctx, "anthropic.messages",
+		attribute.String("llm.model", c.model),
+		attribute.Int("llm.attempt", attempt))
+	defer func() { tracing.End(span, err) }()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+"/messages", bytes.NewBuffer(body))
 	if err != nil {
 		c.logger.Error("Erro ao criar a requisição", zap.Error(err))
 		return "", fmt.Errorf("erro ao criar requisição: %w", err)
@@ -67,16 +116,17 @@ func (c *ClaudeAIClient) SendPrompt(ctx context.Context, prompt string, history
 		return "", fmt.Errorf("erro na requisição: %w", err)
 	}
 	defer resp.Body.Close()
+	span.SetAttributes(attribute.Int("http.status_code", resp.StatusCode))
 
 	if resp.StatusCode != http.StatusOK {
 		bodyBytes, _ := io.ReadAll(resp.Body)
 		c.logger.Error("Erro na resposta da API",
 			zap.Int("status", resp.StatusCode),
 			zap.String("response", string(bodyBytes)))
-		return "", fmt.Errorf("erro na API (status %d): %s", resp.StatusCode, string(bodyBytes))
+		return "", fmt.Errorf("erro na API: %w", retry.NewHTTPError(resp, bodyBytes))
 	}
 
-	return c.parseResponse(resp)
+	return c.parseResponse(ctx, resp)
 }
 
 func (c *ClaudeAIClient) buildMessages(prompt string, history []models.Message) []map[string]string {
@@ -101,13 +151,18 @@ func (c *ClaudeAIClient) buildMessages(prompt string, history []models.Message)
 	return messages
 }
 
-func (c *ClaudeAIClient) parseResponse(resp *http.Response) (string, error) {
+func (c *ClaudeAIClient) parseResponse(ctx context.Context, resp *http.Response) (string, error) {
 	var result struct {
 		Content []struct {
 			Type string `json:"type"`
 			Text string `json:"text"`
 		} `json:"content"`
+		Usage struct {
+			InputTokens  int `json:"input_tokens"`
+			OutputTokens int `json:"output_tokens"`
+		} `json:"usage"`
 		Error *struct {
+			Type    string `json:"type"`
 			Message string `json:"message"`
 		} `json:"error,omitempty"`
 	}
@@ -117,9 +172,16 @@ func (c *ClaudeAIClient) parseResponse(resp *http.Response) (string, error) {
 	}
 
 	if result.Error != nil {
-		return "", fmt.Errorf("erro da API: %s", result.Error.Message)
+		err := fmt.Errorf("erro da API: %s", result.Error.Message)
+		if code, ok := claudeErrorCodes[result.Error.Type]; ok {
+			return "", NewError(code, ProviderClaudeAI, err)
+		}
+		return "", err
 	}
 
+	metrics.ObserveTokens(ProviderClaudeAI, c.model, result.Usage.InputTokens, result.Usage.OutputTokens)
+	recordUsage(ctx, result.Usage.InputTokens, result.Usage.OutputTokens)
+
 	var responseText string
 	for _, content := range result.Content {
 		if content.Type == "text" {
@@ -133,3 +195,13 @@ func (c *ClaudeAIClient) parseResponse(resp *http.Response) (string, error) {
 
 	return responseText, nil
 }
+
+// claudeErrorCodes mapeia os tipos de erro documentados pela Anthropic para os códigos do pacote
+var claudeErrorCodes = map[string]ErrorCode{
+	"authentication_error": ErrCodeAuthFailed,
+	"permission_error":     ErrCodeAuthFailed,
+	"rate_limit_error":     ErrCodeRateLimited,
+	"overloaded_error":     ErrCodeProviderUnavailable,
+	"api_error":            ErrCodeProviderUnavailable,
+	"timeout_error":        ErrCodeTimeout,
+}
@@ -0,0 +1,65 @@
+package llm
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/chatcomStackspotAI/llm/llmtest"
+	"go.uber.org/zap"
+)
+
+func newTestClaudeAIClient(fake *llmtest.Server) *ClaudeAIClient {
+	return NewClaudeAIClient(ClaudeAIConfig{
+		APIKey:      "fake-anthropic-key",
+		Model:       "claude-3-5-sonnet-20240620",
+		BaseURL:     fake.AnthropicURL(),
+		RetryPolicy: testRetryPolicy,
+	}, zap.NewNop())
+}
+
+func TestClaudeAIClientSendPrompt(t *testing.T) {
+	fake := llmtest.NewServer()
+	defer fake.Close()
+
+	ctx, usage := WithUsage(context.Background())
+	response, err := newTestClaudeAIClient(fake).SendPrompt(ctx, "qual a capital?", nil)
+	if err != nil {
+		t.Fatalf("SendPrompt: %v", err)
+	}
+	if response != "eco: qual a capital?" {
+		t.Fatalf("resposta = %q", response)
+	}
+	if got := usage(); got.PromptTokens != 3 || got.CompletionTokens != 4 {
+		t.Fatalf("uso = %+v", got)
+	}
+
+	requests := fake.Requests(llmtest.RouteAnthropicMessages)
+	if len(requests) != 1 {
+		t.Fatalf("requisições = %d, esperado 1", len(requests))
+	}
+	if key := requests[0].Header.Get("x-api-key"); key != "fake-anthropic-key" {
+		t.Fatalf("x-api-key = %q", key)
+	}
+}
+
+func TestClaudeAIClientRetriesRateLimit(t *testing.T) {
+	fake := llmtest.NewServer()
+	defer fake.Close()
+	fake.FailNext(llmtest.RouteAnthropicMessages, 1, llmtest.Failure{
+		Status:     http.StatusTooManyRequests,
+		Body:       `{"type":"error","error":{"type":"rate_limit_error","message":"rate limited"}}`,
+		RetryAfter: "0",
+	})
+
+	response, err := newTestClaudeAIClient(fake).SendPrompt(context.Background(), "oi", nil)
+	if err != nil {
+		t.Fatalf("SendPrompt: %v", err)
+	}
+	if response != "eco: oi" {
+		t.Fatalf("resposta = %q", response)
+	}
+	if n := len(fake.Requests(llmtest.RouteAnthropicMessages)); n != 2 {
+		t.Fatalf("tentativas = %d, esperado 2", n)
+	}
+}
@@ -0,0 +1,169 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/chatcomStackspotAI/retry"
+)
+
+// ErrorCode classifica as falhas dos provedores de forma independente do provedor
+type ErrorCode string
+
+const (
+	ErrCodeAuthFailed          ErrorCode = "auth_failed"          // Credenciais inválidas ou sem permissão
+	ErrCodeRateLimited         ErrorCode = "rate_limited"         // Limite de requisições ou de cota atingido
+	ErrCodeContextTooLong      ErrorCode = "context_too_long"     // Prompt e histórico excedem o contexto do modelo
+	ErrCodeProviderUnavailable ErrorCode = "provider_unavailable" // Provedor fora do ar, sobrecarregado ou com circuito aberto
+	ErrCodeContentFiltered     ErrorCode = "content_filtered"     // Conteúdo bloqueado pelas políticas do provedor
+	ErrCodeTimeout             ErrorCode = "timeout"              // Prazo esgotado antes da resposta
+	ErrCodeCancelled           ErrorCode = "cancelled"            // Requisição cancelada pelo cliente
+	ErrCodeProviderError       ErrorCode = "provider_error"       // Qualquer outra falha do provedor
+)
+
+// Mensagens exibidas aos usuários, sem detalhes internos ou corpos das respostas dos provedores
+var userMessages = map[ErrorCode]string{
+	ErrCodeAuthFailed:          "Falha na autenticação com o provedor de LLM. Verifique as credenciais configuradas.",
+	ErrCodeRateLimited:         "O limite de requisições do provedor foi atingido. Aguarde alguns instantes e tente novamente.",
+	ErrCodeContextTooLong:      "A conversa excedeu o tamanho máximo suportado pelo modelo. Inicie uma nova conversa ou reduza a mensagem.",
+	ErrCodeProviderUnavailable: "O provedor de LLM está temporariamente indisponível. Tente novamente em instantes.",
+	ErrCodeContentFiltered:     "A mensagem ou a resposta foi bloqueada pelas políticas de conteúdo do provedor.",
+	ErrCodeTimeout:             "O provedor de LLM demorou demais para responder. Tente novamente.",
+	ErrCodeCancelled:           "A requisição foi cancelada.",
+	ErrCodeProviderError:       "O provedor de LLM não pôde processar a solicitação.",
+}
+
+// Error é a falha tipada retornada pelos clientes obtidos em LLMManager.GetClient
+type Error struct {
+	Code     ErrorCode
+	Provider string
+	Err      error // Causa original, apenas para logs
+}
+
+// NewError cria um Error com o código e a causa informados
+func NewError(code ErrorCode, provider string, err error) *Error {
+	return &Error{Code: code, Provider: provider, Err: err}
+}
+
+func (e *Error) Error() string {
+	if e.Err == nil {
+		return fmt.Sprintf("%s (%s)", e.Code, e.Provider)
+	}
+	return fmt.Sprintf("%s (%s): %v", e.Code, e.Provider, e.Err)
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// UserMessage retorna a mensagem segura para exibição ao usuário
+func (e *Error) UserMessage() string {
+	if msg, ok := userMessages[e.Code]; ok {
+		return msg
+	}
+	return userMessages[ErrCodeProviderError]
+}
+
+// Retryable indica se repetir a mesma requisição mais tarde pode ter sucesso
+func (e *Error) Retryable() bool {
+	switch e.Code {
+	case ErrCodeRateLimited, ErrCodeProviderUnavailable, ErrCodeTimeout:
+		return true
+	}
+	return false
+}
+
+// Trechos dos corpos de erro que identificam contexto excedido e conteúdo filtrado em cada provedor
+var (
+	contextTooLongMarkers = map[string][]string{
+		ProviderOpenAI:    {"context_length_exceeded", "maximum context length", "string_above_max_length"},
+		ProviderClaudeAI:  {"prompt is too long", "too many tokens", "exceeds the maximum"},
+		ProviderStackSpot: {"context_length_exceeded", "maximum context length", "token limit"},
+	}
+	contentFilteredMarkers = map[string][]string{
+		ProviderOpenAI:    {"content_filter", "content_policy_violation", "content management policy"},
+		ProviderClaudeAI:  {"content filtering", "output blocked"},
+		ProviderStackSpot: {"content_filter", "content management policy"},
+	}
+)
+
+// ClassifyError converte o erro de um provedor em *Error; erros já classificados são mantidos
+func ClassifyError(provider string, err error) error {
+	if err == nil {
+		return nil
+	}
+
+	var classified *Error
+	if errors.As(err, &classified) {
+		return err
+	}
+
+	return NewError(classify(provider, err), provider, err)
+}
+
+func classify(provider string, err error) ErrorCode {
+	if errors.Is(err, context.Canceled) {
+		return ErrCodeCancelled
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return ErrCodeTimeout
+	}
+
+	var openErr *CircuitOpenError
+	if errors.As(err, &openErr) {
+		return ErrCodeProviderUnavailable
+	}
+
+	var httpErr *retry.HTTPError
+	if errors.As(err, &httpErr) {
+		return classifyHTTPError(provider, httpErr)
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		if netErr.Timeout() {
+			return ErrCodeTimeout
+		}
+		return ErrCodeProviderUnavailable
+	}
+
+	return ErrCodeProviderError
+}
+
+func classifyHTTPError(provider string, httpErr *retry.HTTPError) ErrorCode {
+	body := strings.ToLower(httpErr.Body)
+	switch {
+	case containsAny(body, contextTooLongMarkers[provider]):
+		return ErrCodeContextTooLong
+	case containsAny(body, contentFilteredMarkers[provider]):
+		return ErrCodeContentFiltered
+	}
+
+	switch httpErr.StatusCode {
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return ErrCodeAuthFailed
+	case http.StatusTooManyRequests:
+		return ErrCodeRateLimited
+	case http.StatusRequestEntityTooLarge:
+		return ErrCodeContextTooLong
+	case http.StatusRequestTimeout, http.StatusGatewayTimeout:
+		return ErrCodeTimeout
+	}
+	if retry.IsRetryableStatus(httpErr.StatusCode) {
+		return ErrCodeProviderUnavailable
+	}
+	return ErrCodeProviderError
+}
+
+func containsAny(s string, substrings []string) bool {
+	for _, sub := range substrings {
+		if strings.Contains(s, sub) {
+			return true
+		}
+	}
+	return false
+}
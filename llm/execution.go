@@ -0,0 +1,55 @@
+package llm
+
+import (
+	"context"
+	"errors"
+)
+
+// ResumableLLMClient é implementado pelos clientes cujas execuções continuam no provedor
+// (como os quick commands da StackSpot) e podem ser retomadas pelo ID, por exemplo depois
+// de uma reinicialização do servidor
+type ResumableLLMClient interface {
+	LLMClient
+	ResumeExecution(ctx context.Context, executionID string) (response string, err error)
+}
+
+// ErrNotResumable indica que o provedor não permite retomar uma execução pelo ID
+var ErrNotResumable = errors.New("o provedor não permite retomar execuções")
+
+// Resume aguarda o resultado de uma execução já criada no provedor
+func Resume(ctx context.Context, client LLMClient, executionID string) (string, error) {
+	if resumable, ok := client.(ResumableLLMClient); ok {
+		return resumable.ResumeExecution(ctx, executionID)
+	}
+	return "", ErrNotResumable
+}
+
+type executionObserverKey struct{}
+
+// WithExecutionObserver registra no contexto uma função chamada assim que um provedor
+// assíncrono cria a execução, com o ID que permite retomá-la via Resume
+func WithExecutionObserver(ctx context.Context, observe func(executionID string)) context.Context {
+	return context.WithValue(ctx, executionObserverKey{}, observe)
+}
+
+// notifyExecutionStarted avisa o observador do contexto, se houver, sobre a nova execução
+func notifyExecutionStarted(ctx context.Context, executionID string) {
+	if observe, ok := ctx.Value(executionObserverKey{}).(func(string)); ok {
+		observe(executionID)
+	}
+}
+
+type progressObserverKey struct{}
+
+// WithProgressObserver registra no contexto uma função chamada quando um provedor assíncrono
+// informa o progresso da execução, entre 0 e 1
+func WithProgressObserver(ctx context.Context, observe func(progress float64)) context.Context {
+	return context.WithValue(ctx, progressObserverKey{}, observe)
+}
+
+// notifyProgress avisa o observador do contexto, se houver, sobre o progresso da execução
+func notifyProgress(ctx context.Context, progress float64) {
+	if observe, ok := ctx.Value(progressObserverKey{}).(func(float64)); ok {
+		observe(progress)
+	}
+}
@@ -0,0 +1,57 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// GenerationOptions ajusta a geração da resposta; campos nil mantêm o padrão do provedor
+type GenerationOptions struct {
+	Temperature *float64
+	MaxTokens   *int
+}
+
+type generationOptionsKey struct{}
+
+// WithGenerationOptions registra no contexto as opções de geração da chamada. Só os provedores
+// indicados por SupportsGenerationOptions as aplicam; os demais as ignoram.
+func WithGenerationOptions(ctx context.Context, options GenerationOptions) context.Context {
+	return context.WithValue(ctx, generationOptionsKey{}, options)
+}
+
+// generationOptions retorna as opções registradas no contexto, ou o valor zero
+func generationOptions(ctx context.Context) GenerationOptions {
+	options, _ := ctx.Value(generationOptionsKey{}).(GenerationOptions)
+	return options
+}
+
+// SupportsGenerationOptions indica se o provedor aplica as GenerationOptions. A StackSpot executa
+// quick commands com parâmetros definidos na plataforma, e o MOCK não gera texto.
+func SupportsGenerationOptions(provider string) bool {
+	return provider == ProviderOpenAI || provider == ProviderClaudeAI
+}
+
+// maxTemperature é o maior valor de temperature aceito por cada provedor que aplica as opções
+var maxTemperature = map[string]float64{
+	ProviderOpenAI:   2,
+	ProviderClaudeAI: 1,
+}
+
+// ValidateGenerationOptions verifica se as opções estão nos intervalos aceitos pelo provedor,
+// para recusá-las antes da chamada; nos provedores que não as aplicam, vale o intervalo da OpenAI
+func ValidateGenerationOptions(provider string, options GenerationOptions) error {
+	if options.Temperature != nil {
+		limit, ok := maxTemperature[provider]
+		if !ok {
+			limit = 2
+		}
+		if *options.Temperature < 0 || *options.Temperature > limit {
+			return fmt.Errorf("temperature deve ser um número entre 0 e %g para o provedor %s", limit, provider)
+		}
+	}
+	if options.MaxTokens != nil && *options.MaxTokens < 1 {
+		return errors.New("max_tokens deve ser um inteiro positivo")
+	}
+	return nil
+}
@@ -0,0 +1,114 @@
+package llm
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// TransportConfig reúne as opções do transporte HTTP compartilhado pelos provedores
+type TransportConfig struct {
+	ProxyURL            string        // Proxy explícito; vazio usa HTTPS_PROXY/HTTP_PROXY/NO_PROXY
+	CABundle            string        // Arquivo PEM com CAs adicionais (ex.: proxy corporativo)
+	MaxIdleConnsPerHost int           // Conexões ociosas mantidas por host
+	IdleConnTimeout     time.Duration // Tempo até fechar uma conexão ociosa
+}
+
+// TransportConfigFromEnv lê LLM_PROXY_URL, LLM_CA_BUNDLE, LLM_MAX_IDLE_CONNS_PER_HOST e LLM_IDLE_CONN_TIMEOUT
+func TransportConfigFromEnv() TransportConfig {
+	config := TransportConfig{
+		ProxyURL:            os.Getenv("LLM_PROXY_URL"),
+		CABundle:            os.Getenv("LLM_CA_BUNDLE"),
+		MaxIdleConnsPerHost: 10,
+		IdleConnTimeout:     90 * time.Second,
+	}
+	if v, err := strconv.Atoi(os.Getenv("LLM_MAX_IDLE_CONNS_PER_HOST")); err == nil && v > 0 {
+		config.MaxIdleConnsPerHost = v
+	}
+	if v, err := time.ParseDuration(os.Getenv("LLM_IDLE_CONN_TIMEOUT")); err == nil && v > 0 {
+		config.IdleConnTimeout = v
+	}
+	return config
+}
+
+// NewHTTPTransport cria o transporte com pool de conexões keep-alive compartilhado entre os clientes
+func NewHTTPTransport(config TransportConfig) (*http.Transport, error) {
+	proxy := http.ProxyFromEnvironment
+	if config.ProxyURL != "" {
+		proxyURL, err := url.Parse(config.ProxyURL)
+		if err != nil {
+			return nil, fmt.Errorf("LLM_PROXY_URL inválida: %w", err)
+		}
+		proxy = http.ProxyURL(proxyURL)
+	}
+
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	if config.CABundle != "" {
+		pemData, err := os.ReadFile(config.CABundle)
+		if err != nil {
+			return nil, fmt.Errorf("erro ao ler o bundle de CAs: %w", err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil || pool == nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pemData) {
+			return nil, fmt.Errorf("nenhum certificado válido encontrado em %s", config.CABundle)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	return &http.Transport{
+		Proxy: proxy,
+		DialContext: (&net.Dialer{
+			Timeout:   10 * time.Second,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		TLSClientConfig:       tlsConfig,
+		ForceAttemptHTTP2:     true,
+		MaxIdleConns:          100,
+		MaxIdleConnsPerHost:   config.MaxIdleConnsPerHost,
+		IdleConnTimeout:       config.IdleConnTimeout,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: time.Second,
+	}, nil
+}
+
+// NewHTTPClient cria um http.Client sobre o transporte compartilhado com o timeout do provedor
+func NewHTTPClient(transport http.RoundTripper, timeout time.Duration) *http.Client {
+	return &http.Client{
+		Transport: transport,
+		Timeout:   timeout,
+	}
+}
+
+// timeoutFromEnv lê <PREFIX>_TIMEOUT, usando o valor padrão quando ausente ou inválido
+func timeoutFromEnv(prefix string, fallback time.Duration) time.Duration {
+	if v, err := time.ParseDuration(os.Getenv(prefix + "_TIMEOUT")); err == nil && v > 0 {
+		return v
+	}
+	return fallback
+}
+
+// httpClientOrDefault retorna o cliente informado ou um cliente próprio com o timeout padrão
+func httpClientOrDefault(client *http.Client, timeout time.Duration) *http.Client {
+	if client != nil {
+		return client
+	}
+	return &http.Client{Timeout: timeout}
+}
+
+// baseURLOrDefault retorna a URL informada sem a barra final, ou a URL padrão
+func baseURLOrDefault(baseURL, fallback string) string {
+	if baseURL == "" {
+		return fallback
+	}
+	return strings.TrimRight(baseURL, "/")
+}
@@ -0,0 +1,75 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/chatcomStackspotAI/metrics"
+	"github.com/chatcomStackspotAI/models"
+	"github.com/chatcomStackspotAI/tracing"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// instrumentedClient envolve um LLMClient registrando a latência e o span de cada chamada
+// e convertendo as falhas do provedor em *Error
+type instrumentedClient struct {
+	LLMClient
+	provider string
+	usage    *UsageTracker
+}
+
+func newInstrumentedClient(client LLMClient, provider string, usage *UsageTracker) *instrumentedClient {
+	return &instrumentedClient{
+		LLMClient: client,
+		provider:  provider,
+		usage:     usage,
+	}
+}
+
+func (c *instrumentedClient) SendPrompt(ctx context.Context, prompt string, history []models.Message) (string, error) {
+	return c.observe(ctx, history, func(ctx context.Context) (string, error) {
+		return c.LLMClient.SendPrompt(ctx, prompt, history)
+	})
+}
+
+func (c *instrumentedClient) SendPromptStream(ctx context.Context, prompt string, history []models.Message, onChunk func(chunk string) error) (string, error) {
+	return c.observe(ctx, history, func(ctx context.Context) (string, error) {
+		return Stream(ctx, c.LLMClient, prompt, history, onChunk)
+	})
+}
+
+func (c *instrumentedClient) ResumeExecution(ctx context.Context, executionID string) (string, error) {
+	return c.observe(ctx, nil, func(ctx context.Context) (string, error) {
+		return Resume(ctx, c.LLMClient, executionID)
+	})
+}
+
+func (c *instrumentedClient) observe(ctx context.Context, history []models.Message, call func(ctx context.Context) (string, error)) (string, error) {
+	ctx, span := tracing.Start(ctx, "llm.SendPrompt",
+		attribute.String("llm.provider", c.provider),
+		attribute.String("llm.model", c.GetModelName()),
+		attribute.Int("llm.history_length", len(history)))
+
+	// Coleta o consumo desta chamada e o repassa a quem chamou, se também estiver coletando
+	parentCtx := ctx
+	ctx, callUsage := WithUsage(ctx)
+
+	start := time.Now()
+	response, err := call(ctx)
+	err = ClassifyError(c.provider, err)
+	tracing.End(span, err)
+
+	usage := callUsage()
+	recordUsage(parentCtx, usage.PromptTokens, usage.CompletionTokens)
+	c.usage.Record(c.provider, c.GetModelName(), usage, err)
+
+	outcome := "success"
+	var llmErr *Error
+	if errors.As(err, &llmErr) {
+		outcome = string(llmErr.Code)
+	}
+	metrics.LLMRequestDuration.WithLabelValues(c.provider, c.GetModelName(), outcome).Observe(time.Since(start).Seconds())
+
+	return response, err
+}
@@ -9,3 +9,27 @@ type LLMClient interface {
 	SendPrompt(ctx context.Context, prompt string, history []models.Message) (response string, err error)
 	GetModelName() string
 }
+
+// StreamingLLMClient é implementado pelos clientes capazes de entregar a resposta em partes.
+// onChunk recebe cada trecho assim que disponível; a resposta completa também é retornada.
+type StreamingLLMClient interface {
+	LLMClient
+	SendPromptStream(ctx context.Context, prompt string, history []models.Message, onChunk func(chunk string) error) (response string, err error)
+}
+
+// Stream envia o prompt em modo streaming quando o cliente suporta; caso contrário,
+// entrega a resposta completa como um único trecho
+func Stream(ctx context.Context, client LLMClient, prompt string, history []models.Message, onChunk func(chunk string) error) (string, error) {
+	if streaming, ok := client.(StreamingLLMClient); ok {
+		return streaming.SendPromptStream(ctx, prompt, history, onChunk)
+	}
+
+	response, err := client.SendPrompt(ctx, prompt, history)
+	if err != nil {
+		return "", err
+	}
+	if err := onChunk(response); err != nil {
+		return "", err
+	}
+	return response, nil
+}
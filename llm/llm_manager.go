@@ -1,100 +1,400 @@
 package llm
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"github.com/chatcomStackspotAI/llm/cassette"
+	"github.com/chatcomStackspotAI/retry"
+	"github.com/chatcomStackspotAI/tracing"
+	"go.opentelemetry.io/otel/attribute"
 	"go.uber.org/zap"
+	"net/http"
 	"os"
+	"sort"
+	"strings"
+	"time"
 )
 
+// Identificadores dos provedores registrados no LLMManager
+const (
+	ProviderOpenAI    = "OPENAI"
+	ProviderStackSpot = "SPOT"
+	ProviderClaudeAI  = "CLAUDEAI"
+)
+
+// ErrUnsupportedProvider indica que o provedor solicitado não está registrado no LLMManager
+var ErrUnsupportedProvider = errors.New("provedor LLM não suportado")
+
+// ErrInvalidModel indica um nome de modelo malformado, como um slug da StackSpot com caracteres inválidos
+var ErrInvalidModel = errors.New("modelo inválido")
+
 type LLMManager struct {
-	clients map[string]func(string) (LLMClient, error)
-	logger  *zap.Logger
+	clients  map[string]func(string) (LLMClient, error)
+	breakers map[string]*CircuitBreaker
+	usage    *UsageTracker
+	logger   *zap.Logger
+
+	stackSpot *stackSpotAccounts // Contas da StackSpot, se configuradas
+}
+
+// ProviderStatus descreve um provedor registrado e o estado do seu circuit breaker
+type ProviderStatus struct {
+	Provider  string `json:"provider"`
+	Available bool   `json:"available"`
+	BreakerStatus
 }
 
 func NewLLMManager(logger *zap.Logger) (*LLMManager, error) {
 	manager := &LLMManager{
-		clients: make(map[string]func(string) (LLMClient, error)),
-		logger:  logger,
+		clients:  make(map[string]func(string) (LLMClient, error)),
+		breakers: make(map[string]*CircuitBreaker),
+		usage:    NewUsageTracker(),
+		logger:   logger,
+	}
+
+	// Transporte HTTP compartilhado por todos os provedores, reaproveitando conexões
+	httpTransport, err := NewHTTPTransport(TransportConfigFromEnv())
+	if err != nil {
+		return nil, fmt.Errorf("erro ao configurar o transporte HTTP: %w", err)
+	}
+
+	// Opcionalmente grava ou reproduz o tráfego com os provedores
+	transport, replaying, err := cassetteTransport(httpTransport, logger)
+	if err != nil {
+		return nil, err
+	}
+
+	// No modo replay as credenciais não são necessárias, pois nada chega aos provedores
+	secret := func(key string) string {
+		if value := os.Getenv(key); value != "" || !replaying {
+			return value
+		}
+		return "cassette-replay"
 	}
 
 	// Configurar a fábrica para OpenAI
-	apiKey := os.Getenv("OPENAI_API_KEY")
+	apiKey := secret("OPENAI_API_KEY")
 	if apiKey == "" {
 		logger.Warn("OPENAI_API_KEY não está definido")
 	} else {
-		manager.clients["OPENAI"] = func(model string) (LLMClient, error) {
-			model = os.Getenv("OPENAI_MODEL")
-			if model == "" {
-				model = "gpt-3.5-turbo" // Modelo padrão
-			}
-			return NewOpenAIClient(apiKey, model, logger), nil
+		openAIHTTP := NewHTTPClient(transport, timeoutFromEnv(envPrefix(ProviderOpenAI), 60*time.Second))
+		openAIRetry := retry.PolicyFromEnv(envPrefix(ProviderOpenAI), retry.DefaultPolicy())
+		manager.clients[ProviderOpenAI] = func(model string) (LLMClient, error) {
+			return NewOpenAIClient(OpenAIConfig{
+				APIKey:      apiKey,
+				Model:       model,
+				BaseURL:     os.Getenv("OPENAI_BASE_URL"),
+				HTTPClient:  openAIHTTP,
+				RetryPolicy: openAIRetry,
+			}, logger), nil
 		}
 	}
 
-	// Configurar a fábrica para StackSpot
-	clientID := os.Getenv("CLIENT_ID")
-	clientSecret := os.Getenv("CLIENT_SECRET")
+	// Configurar a fábrica para StackSpot. CLIENT_ID e CLIENT_SECRET formam a conta padrão;
+	// STACKSPOT_ACCOUNTS acrescenta contas nomeadas para slugs de outros times ou realms.
+	stackSpotRealm := os.Getenv("STACKSPOT_REALM")
+	namedAccounts, err := stackSpotAccountsFromEnv(secret, stackSpotRealm)
+	if err != nil {
+		return nil, err
+	}
 	slug := os.Getenv("SLUG_NAME")
-	if clientID == "" || clientSecret == "" || slug == "" {
+	clientID := secret("CLIENT_ID")
+	clientSecret := secret("CLIENT_SECRET")
+	var accounts []StackSpotAccount
+	if clientID != "" && clientSecret != "" && slug != "" {
+		accounts = append(accounts, StackSpotAccount{
+			Name:         defaultStackSpotAccount,
+			ClientID:     clientID,
+			ClientSecret: clientSecret,
+			Realm:        stackSpotRealm,
+		})
+	}
+	accounts = append(accounts, namedAccounts...)
+
+	if len(accounts) == 0 {
 		logger.Warn("As credenciais do StackSpot não estão definidas")
 	} else {
-		tokenManager := NewTokenManager(clientID, clientSecret, logger)
-		manager.clients["SPOT"] = func(model string) (LLMClient, error) {
-			return NewStackSpotClient(tokenManager, slug, logger), nil
+		stackSpotHTTP := NewHTTPClient(transport, timeoutFromEnv(envPrefix(ProviderStackSpot), 30*time.Second))
+		stackSpotRetryDefaults := retry.DefaultPolicy()
+		stackSpotRetryDefaults.MaxAttempts = 5
+		stackSpotRetry := retry.PolicyFromEnv(envPrefix(ProviderStackSpot), stackSpotRetryDefaults)
+		stackSpotPoll := PollPolicyFromEnv(envPrefix(ProviderStackSpot), DefaultPollPolicy())
+		tokenRenewBefore, _ := time.ParseDuration(os.Getenv("STACKSPOT_TOKEN_RENEW_BEFORE"))
+
+		manager.stackSpot = &stackSpotAccounts{
+			tokens:      make(map[string]*TokenManager),
+			bySlug:      make(map[string]string),
+			defaultSlug: slug,
+		}
+		for _, account := range accounts {
+			manager.stackSpot.tokens[account.Name] = NewTokenManager(TokenManagerConfig{
+				Account:      account.Name,
+				ClientID:     account.ClientID,
+				ClientSecret: account.ClientSecret,
+				Realm:        account.Realm,
+				IDMURL:       os.Getenv("STACKSPOT_IDM_URL"),
+				HTTPClient:   stackSpotHTTP,
+				RetryPolicy:  stackSpotRetry,
+				RenewBefore:  tokenRenewBefore,
+			}, logger)
+			for _, accountSlug := range account.Slugs {
+				manager.stackSpot.bySlug[accountSlug] = account.Name
+			}
+		}
+
+		manager.clients[ProviderStackSpot] = func(model string) (LLMClient, error) {
+			// O modelo da StackSpot é o slug do quick command; o padrão usa SLUG_NAME
+			tokenManager, commandSlug, err := manager.stackSpot.resolve(model)
+			if err != nil {
+				return nil, err
+			}
+			return NewStackSpotClient(tokenManager, StackSpotConfig{
+				Slug:        commandSlug,
+				BaseURL:     os.Getenv("STACKSPOT_BASE_URL"),
+				HTTPClient:  stackSpotHTTP,
+				RetryPolicy: stackSpotRetry,
+				PollPolicy:  stackSpotPoll,
+			}, logger), nil
 		}
 	}
 
 	// Configurar a fábrica para ClaudeAI
-	claudeAPIKey := os.Getenv("CLAUDEAI_API_KEY")
+	claudeAPIKey := secret("CLAUDEAI_API_KEY")
 	if claudeAPIKey == "" {
 		logger.Warn("CLAUDEAI_API_KEY não está definido")
 	} else {
-		manager.clients["CLAUDEAI"] = func(model string) (LLMClient, error) {
-			model = os.Getenv("CLAUDEAI_MODEL")
-			if model == "" {
-				model = "claude-3-5-sonnet-20241022" // Modelo padrão
-			}
-			return NewClaudeAIClient(claudeAPIKey, model, logger), nil
+		// Respostas longas (max_tokens 8192) podem levar mais de um minuto
+		claudeHTTP := NewHTTPClient(transport, timeoutFromEnv(envPrefix(ProviderClaudeAI), 180*time.Second))
+		claudeRetry := retry.PolicyFromEnv(envPrefix(ProviderClaudeAI), retry.DefaultPolicy())
+		manager.clients[ProviderClaudeAI] = func(model string) (LLMClient, error) {
+			return NewClaudeAIClient(ClaudeAIConfig{
+				APIKey:      claudeAPIKey,
+				Model:       model,
+				BaseURL:     os.Getenv("CLAUDEAI_BASE_URL"),
+				HTTPClient:  claudeHTTP,
+				RetryPolicy: claudeRetry,
+			}, logger), nil
 		}
 	}
 
+	// Configurar o provedor MOCK: habilitado com MOCK_ENABLED=true, ou automaticamente
+	// fora de produção quando nenhum provedor real está configurado
+	mockEnabled := os.Getenv("MOCK_ENABLED") == "true"
+	if !mockEnabled && len(manager.clients) == 0 && os.Getenv("ENV") != "prod" {
+		logger.Warn("Nenhum provedor de LLM configurado; habilitando o provedor MOCK")
+		mockEnabled = true
+	}
+	if mockEnabled {
+		mockConfig, err := MockConfigFromEnv()
+		if err != nil {
+			return nil, err
+		}
+		// Valida a configuração na inicialização em vez de falhar na primeira mensagem
+		if _, err := NewMockClient(mockConfig, logger); err != nil {
+			return nil, err
+		}
+		manager.clients[ProviderMock] = func(model string) (LLMClient, error) {
+			return NewMockClient(mockConfig, logger)
+		}
+	}
+
+	// Cada provedor registrado recebe o seu próprio circuit breaker
+	for provider := range manager.clients {
+		config := BreakerConfigFromEnv(envPrefix(provider), DefaultBreakerConfig())
+		manager.breakers[provider] = NewCircuitBreaker(provider, config, logger)
+	}
+
 	return manager, nil
 }
 
-func (m *LLMManager) GetClient(provider string, model string) (LLMClient, error) {
-	factoryFunc, ok := m.clients[provider]
-	if !ok {
-		return nil, fmt.Errorf("Provedor LLM '%s' não suportado", provider)
+// cassetteTransport aplica o modo definido em LLM_CASSETTE_MODE ("record" ou "replay") usando o
+// cassette LLM_CASSETTE_NAME dentro de LLM_CASSETTE_DIR; retorna se o modo replay está ativo
+func cassetteTransport(transport http.RoundTripper, logger *zap.Logger) (http.RoundTripper, bool, error) {
+	mode := os.Getenv("LLM_CASSETTE_MODE")
+	if mode == "" {
+		return transport, false, nil
+	}
+
+	dir := os.Getenv("LLM_CASSETTE_DIR")
+	if dir == "" {
+		dir = "cassettes"
+	}
+	path := cassette.Path(dir, os.Getenv("LLM_CASSETTE_NAME"))
+
+	switch mode {
+	case cassette.ModeRecord:
+		recorder, err := cassette.NewRecorder(transport, path)
+		if err != nil {
+			return nil, false, fmt.Errorf("erro ao iniciar a gravação do cassette: %w", err)
+		}
+		logger.Warn("Gravando o tráfego dos provedores em cassette", zap.String("path", path))
+		return recorder, false, nil
+	case cassette.ModeReplay:
+		player, err := cassette.NewPlayer(path)
+		if err != nil {
+			return nil, false, fmt.Errorf("erro ao carregar o cassette: %w", err)
+		}
+		logger.Warn("Respondendo a partir do cassette, sem acessar os provedores", zap.String("path", path))
+		return player, true, nil
+	default:
+		return nil, false, fmt.Errorf("LLM_CASSETTE_MODE inválido: %q", mode)
+	}
+}
+
+// envPrefix retorna o prefixo das variáveis de ambiente de configuração do provedor
+func envPrefix(provider string) string {
+	if provider == ProviderStackSpot {
+		return "STACKSPOT"
 	}
+	return provider
+}
 
-	// Cada provedor deve usar seu próprio modelo específico
-	var selectedModel string
+// defaultModel retorna o modelo configurado para o provedor
+func defaultModel(provider string) string {
 	switch provider {
-	case "OPENAI":
-		selectedModel = os.Getenv("OPENAI_MODEL")
-		if selectedModel == "" {
-			selectedModel = "gpt-3.5-turbo" // Modelo padrão OpenAI
+	case ProviderOpenAI:
+		if model := os.Getenv("OPENAI_MODEL"); model != "" {
+			return model
 		}
-		m.logger.Info("Selecionando modelo OpenAI", zap.String("model", selectedModel))
-	case "CLAUDEAI":
-		selectedModel = os.Getenv("CLAUDEAI_MODEL")
-		if selectedModel == "" {
-			selectedModel = "claude-3-5-sonnet-20241022" // Modelo padrão Claude
+		return "gpt-3.5-turbo" // Modelo padrão OpenAI
+	case ProviderClaudeAI:
+		if model := os.Getenv("CLAUDEAI_MODEL"); model != "" {
+			return model
 		}
-		m.logger.Info("Selecionando modelo ClaudeAI", zap.String("model", selectedModel))
-	case "SPOT":
-		selectedModel = "spot-default"
-		m.logger.Info("Selecionando modelo GPT-4o", zap.String("model", selectedModel))
+		return "claude-3-5-sonnet-20241022" // Modelo padrão Claude
+	case ProviderStackSpot:
+		return "spot-default"
+	case ProviderMock:
+		return "mock"
+	}
+	return ""
+}
+
+// ModelInfo descreve o modelo usado por um provedor registrado
+type ModelInfo struct {
+	Provider string `json:"provider"`
+	Model    string `json:"model"`
+}
+
+// Models retorna o modelo de cada provedor registrado, ordenados pelo provedor
+func (m *LLMManager) Models() []ModelInfo {
+	models := make([]ModelInfo, 0, len(m.clients))
+	for provider := range m.clients {
+		models = append(models, ModelInfo{Provider: provider, Model: defaultModel(provider)})
+	}
+	sort.Slice(models, func(i, j int) bool {
+		return models[i].Provider < models[j].Provider
+	})
+	return models
+}
+
+// Usage retorna o consumo acumulado por provedor e modelo desde a inicialização
+func (m *LLMManager) Usage() (time.Time, []UsageSummary) {
+	return m.usage.Snapshot()
+}
+
+// ProviderStatuses retorna o estado dos provedores registrados, ordenados pelo nome
+func (m *LLMManager) ProviderStatuses() []ProviderStatus {
+	statuses := make([]ProviderStatus, 0, len(m.breakers))
+	for provider, breaker := range m.breakers {
+		status := breaker.Status()
+		statuses = append(statuses, ProviderStatus{
+			Provider:      provider,
+			Available:     status.State != BreakerOpen,
+			BreakerStatus: status,
+		})
+	}
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].Provider < statuses[j].Provider
+	})
+	return statuses
+}
+
+// Close interrompe as tarefas em background do gerenciador, como a renovação dos tokens da StackSpot
+func (m *LLMManager) Close() {
+	if m.stackSpot != nil {
+		m.stackSpot.close()
+	}
+}
+
+// GetClient cria o cliente do provedor com o modelo configurado para ele; o modelo informado
+// pela interface web é apenas registrado, pois cada provedor deve usar seu próprio modelo
+func (m *LLMManager) GetClient(ctx context.Context, provider string, model string) (LLMClient, error) {
+	return m.newClient(ctx, provider, model, "")
+}
+
+// GetClientWithModel cria o cliente do provedor para o modelo informado (para a StackSpot, o slug
+// do quick command); vazio usa o modelo configurado
+func (m *LLMManager) GetClientWithModel(ctx context.Context, provider string, model string) (LLMClient, error) {
+	return m.newClient(ctx, provider, model, model)
+}
+
+func (m *LLMManager) newClient(ctx context.Context, provider, requestedModel, model string) (client LLMClient, err error) {
+	_, span := tracing.Start(ctx, "LLMManager.GetClient",
+		attribute.String("llm.provider", provider),
+		attribute.String("llm.requested_model", requestedModel))
+	defer func() { tracing.End(span, err) }()
+
+	factoryFunc, ok := m.clients[provider]
+	if !ok {
+		return nil, fmt.Errorf("%w: '%s'", ErrUnsupportedProvider, provider)
+	}
+
+	selectedModel := model
+	if selectedModel == "" {
+		selectedModel = defaultModel(provider)
 	}
 
 	m.logger.Info("Criando cliente LLM",
 		zap.String("provider", provider),
 		zap.String("selectedModel", selectedModel))
 
-	client, err := factoryFunc(selectedModel)
+	client, err = factoryFunc(selectedModel)
 	if err != nil {
 		return nil, fmt.Errorf("erro ao criar cliente para provedor %s: %w", provider, err)
 	}
 
-	return client, nil
+	return newInstrumentedClient(newBreakerClient(client, m.breakers[provider]), provider, m.usage), nil
+}
+
+// Prefixos aceitos em ResolveModel para escolher o provedor pelo nome do modelo
+var modelPrefixes = map[string]string{
+	"openai":    ProviderOpenAI,
+	"claude":    ProviderClaudeAI,
+	"anthropic": ProviderClaudeAI,
+	"spot":      ProviderStackSpot,
+	"stackspot": ProviderStackSpot,
+	"mock":      ProviderMock,
+}
+
+// ResolveModel converte nomes no formato "<provedor>/<modelo>" (ex.: "spot/meu-quick-command",
+// "claude/claude-3-5-haiku-latest") no provedor e no modelo. Sem prefixo, o nome pode ser só o
+// provedor ("claude"), um modelo da OpenAI ("gpt-4o", "o1-mini") ou da Anthropic ("claude-3-opus").
+func ResolveModel(name string) (provider, model string, err error) {
+	prefix, model, hasSlash := strings.Cut(name, "/")
+	if provider, ok := modelPrefixes[strings.ToLower(prefix)]; ok {
+		// O slug da StackSpot vai para a URL da API; a validação também é refeita na resolução da conta
+		if provider == ProviderStackSpot && model != "" {
+			_, slug, hasAccount := strings.Cut(model, "/")
+			if !hasAccount {
+				slug = model
+			}
+			if err := validateSlug(slug); err != nil {
+				return "", "", err
+			}
+		}
+		return provider, model, nil
+	}
+	if hasSlash {
+		return "", "", fmt.Errorf("%w: '%s'", ErrUnsupportedProvider, prefix)
+	}
+
+	switch {
+	case strings.HasPrefix(name, "gpt-"), strings.HasPrefix(name, "o1"), strings.HasPrefix(name, "o3"):
+		return ProviderOpenAI, name, nil
+	case strings.HasPrefix(name, "claude-"):
+		return ProviderClaudeAI, name, nil
+	}
+	return "", "", fmt.Errorf("%w: modelo '%s'", ErrUnsupportedProvider, name)
 }
@@ -0,0 +1,421 @@
+// Package llmtest fornece um servidor falso, baseado em httptest, que emula as APIs
+// da OpenAI, da Anthropic e da StackSpot para testes herméticos de clientes e handlers.
+package llmtest
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Rotas emuladas, usadas para programar falhas e consultar as requisições recebidas
+const (
+	RouteOpenAIChat        = "openai.chat_completions"
+	RouteAnthropicMessages = "anthropic.messages"
+	RouteStackSpotToken    = "stackspot.token"
+	RouteStackSpotCreate   = "stackspot.create_execution"
+	RouteStackSpotCallback = "stackspot.callback"
+)
+
+const (
+	defaultStackSpotPolls   = 1
+	defaultTokenTTLSeconds  = 3600
+	issuedAccessTokenPrefix = "fake-token-"
+)
+
+// Failure descreve uma resposta de erro programada para uma rota
+type Failure struct {
+	Status     int
+	Body       string
+	RetryAfter string // Valor do cabeçalho Retry-After, se houver
+}
+
+// RecordedRequest é uma requisição recebida pelo servidor falso
+type RecordedRequest struct {
+	Route  string
+	Method string
+	Path   string
+	Header http.Header
+	Body   string
+}
+
+// Server emula os provedores de LLM. Os campos exportados podem ser ajustados antes das chamadas.
+type Server struct {
+	*httptest.Server
+
+	// Reply gera a resposta do modelo a partir do prompt; o padrão ecoa o prompt. Para trocá-la
+	// com requisições em andamento, use SetReply.
+	Reply func(route, prompt string) string
+	// Latency atrasa todas as respostas, simulando um provedor lento
+	Latency time.Duration
+	// StackSpotPollsUntilDone define quantas consultas ao callback são necessárias até o resultado
+	StackSpotPollsUntilDone int
+	// StackSpotFailure faz as execuções da StackSpot terminarem com status FAILURE
+	StackSpotFailure bool
+	// TokenTTL é o expires_in, em segundos, dos tokens emitidos pelo IDM
+	TokenTTL int
+
+	mu         sync.Mutex
+	requests   []RecordedRequest
+	failures   map[string][]Failure
+	executions map[string]*execution
+	sequence   int
+}
+
+type execution struct {
+	prompt string
+	polls  int
+}
+
+// NewServer inicia o servidor falso; chame Close ao final do teste
+func NewServer() *Server {
+	s := &Server{
+		StackSpotPollsUntilDone: defaultStackSpotPolls,
+		TokenTTL:                defaultTokenTTLSeconds,
+		failures:                make(map[string][]Failure),
+		executions:              make(map[string]*execution),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /openai/v1/chat/completions", s.wrap(RouteOpenAIChat, s.handleOpenAIChat))
+	mux.HandleFunc("POST /anthropic/v1/messages", s.wrap(RouteAnthropicMessages, s.handleAnthropicMessages))
+	mux.HandleFunc("POST /idm/{realm}/oidc/oauth/token", s.wrap(RouteStackSpotToken, s.handleStackSpotToken))
+	mux.HandleFunc("POST /stackspot/v1/quick-commands/create-execution/{slug}", s.wrap(RouteStackSpotCreate, s.handleStackSpotCreate))
+	mux.HandleFunc("GET /stackspot/v1/quick-commands/callback/{id}", s.wrap(RouteStackSpotCallback, s.handleStackSpotCallback))
+
+	s.Server = httptest.NewServer(mux)
+	return s
+}
+
+// OpenAIURL retorna a URL base a ser usada em OpenAIConfig.BaseURL
+func (s *Server) OpenAIURL() string { return s.URL + "/openai/v1" }
+
+// AnthropicURL retorna a URL base a ser usada em ClaudeAIConfig.BaseURL
+func (s *Server) AnthropicURL() string { return s.URL + "/anthropic/v1" }
+
+// StackSpotURL retorna a URL base a ser usada em StackSpotConfig.BaseURL
+func (s *Server) StackSpotURL() string { return s.URL + "/stackspot/v1" }
+
+// IDMURL retorna a URL base a ser usada em TokenManagerConfig.IDMURL
+func (s *Server) IDMURL() string { return s.URL + "/idm" }
+
+// Env retorna as variáveis de ambiente que apontam o LLMManager para o servidor falso
+func (s *Server) Env() map[string]string {
+	return map[string]string{
+		"OPENAI_API_KEY":     "fake-openai-key",
+		"OPENAI_BASE_URL":    s.OpenAIURL(),
+		"CLAUDEAI_API_KEY":   "fake-anthropic-key",
+		"CLAUDEAI_BASE_URL":  s.AnthropicURL(),
+		"CLIENT_ID":          "fake-client-id",
+		"CLIENT_SECRET":      "fake-client-secret",
+		"SLUG_NAME":          "fake-slug",
+		"STACKSPOT_BASE_URL": s.StackSpotURL(),
+		"STACKSPOT_IDM_URL":  s.IDMURL(),
+	}
+}
+
+// Setenv aplica Env usando o Setenv do teste (ex.: *testing.T), que restaura os valores ao final
+func (s *Server) Setenv(t interface{ Setenv(key, value string) }) {
+	for key, value := range s.Env() {
+		t.Setenv(key, value)
+	}
+}
+
+// FailNext faz as próximas chamadas à rota responderem com a falha informada
+func (s *Server) FailNext(route string, times int, failure Failure) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := 0; i < times; i++ {
+		s.failures[route] = append(s.failures[route], failure)
+	}
+}
+
+// Requests retorna as requisições recebidas pela rota, ou todas quando route é vazio
+func (s *Server) Requests(route string) []RecordedRequest {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var result []RecordedRequest
+	for _, req := range s.requests {
+		if route == "" || req.Route == route {
+			result = append(result, req)
+		}
+	}
+	return result
+}
+
+// wrap registra a requisição, aplica a latência e as falhas programadas
+func (s *Server) wrap(route string, handler func(w http.ResponseWriter, r *http.Request, body []byte)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+
+		s.mu.Lock()
+		s.requests = append(s.requests, RecordedRequest{
+			Route:  route,
+			Method: r.Method,
+			Path:   r.URL.Path,
+			Header: r.Header.Clone(),
+			Body:   string(body),
+		})
+		var failure *Failure
+		if queue := s.failures[route]; len(queue) > 0 {
+			failure = &queue[0]
+			s.failures[route] = queue[1:]
+		}
+		latency := s.Latency
+		s.mu.Unlock()
+
+		if latency > 0 {
+			select {
+			case <-time.After(latency):
+			case <-r.Context().Done():
+				return
+			}
+		}
+
+		if failure != nil {
+			if failure.RetryAfter != "" {
+				w.Header().Set("Retry-After", failure.RetryAfter)
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(failure.Status)
+			io.WriteString(w, failure.Body)
+			return
+		}
+
+		handler(w, r, body)
+	}
+}
+
+// SetReply troca a função de resposta; pode ser chamada enquanto o servidor atende requisições
+func (s *Server) SetReply(reply func(route, prompt string) string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Reply = reply
+}
+
+func (s *Server) reply(route, prompt string) string {
+	s.mu.Lock()
+	reply := s.Reply
+	s.mu.Unlock()
+
+	if reply != nil {
+		return reply(route, prompt)
+	}
+	return "eco: " + prompt
+}
+
+func (s *Server) handleOpenAIChat(w http.ResponseWriter, r *http.Request, body []byte) {
+	if !strings.HasPrefix(r.Header.Get("Authorization"), "Bearer ") {
+		writeJSON(w, http.StatusUnauthorized, map[string]interface{}{
+			"error": map[string]string{"message": "Incorrect API key provided", "type": "invalid_request_error", "code": "invalid_api_key"},
+		})
+		return
+	}
+
+	var req struct {
+		Model    string `json:"model"`
+		Messages []struct {
+			Role    string `json:"role"`
+			Content string `json:"content"`
+		} `json:"messages"`
+	}
+	if err := json.Unmarshal(body, &req); err != nil || len(req.Messages) == 0 {
+		writeJSON(w, http.StatusBadRequest, map[string]interface{}{
+			"error": map[string]string{"message": "invalid request body", "type": "invalid_request_error"},
+		})
+		return
+	}
+
+	var promptWords int
+	for _, msg := range req.Messages {
+		promptWords += countTokens(msg.Content)
+	}
+	answer := s.reply(RouteOpenAIChat, req.Messages[len(req.Messages)-1].Content)
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"id":     "chatcmpl-" + s.nextID(),
+		"object": "chat.completion",
+		"model":  req.Model,
+		"choices": []map[string]interface{}{{
+			"index":         0,
+			"message":       map[string]string{"role": "assistant", "content": answer},
+			"finish_reason": "stop",
+		}},
+		"usage": map[string]int{
+			"prompt_tokens":     promptWords,
+			"completion_tokens": countTokens(answer),
+			"total_tokens":      promptWords + countTokens(answer),
+		},
+	})
+}
+
+func (s *Server) handleAnthropicMessages(w http.ResponseWriter, r *http.Request, body []byte) {
+	if r.Header.Get("x-api-key") == "" {
+		writeJSON(w, http.StatusUnauthorized, map[string]interface{}{
+			"type":  "error",
+			"error": map[string]string{"type": "authentication_error", "message": "invalid x-api-key"},
+		})
+		return
+	}
+
+	var req struct {
+		Model    string `json:"model"`
+		Messages []struct {
+			Role    string `json:"role"`
+			Content string `json:"content"`
+		} `json:"messages"`
+	}
+	if err := json.Unmarshal(body, &req); err != nil || len(req.Messages) == 0 {
+		writeJSON(w, http.StatusBadRequest, map[string]interface{}{
+			"type":  "error",
+			"error": map[string]string{"type": "invalid_request_error", "message": "messages: field required"},
+		})
+		return
+	}
+
+	var inputTokens int
+	for _, msg := range req.Messages {
+		inputTokens += countTokens(msg.Content)
+	}
+	answer := s.reply(RouteAnthropicMessages, req.Messages[len(req.Messages)-1].Content)
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"id":          "msg_" + s.nextID(),
+		"type":        "message",
+		"role":        "assistant",
+		"model":       req.Model,
+		"content":     []map[string]string{{"type": "text", "text": answer}},
+		"stop_reason": "end_turn",
+		"usage":       map[string]int{"input_tokens": inputTokens, "output_tokens": countTokens(answer)},
+	})
+}
+
+func (s *Server) handleStackSpotToken(w http.ResponseWriter, r *http.Request, body []byte) {
+	r.Body = io.NopCloser(strings.NewReader(string(body)))
+	if err := r.ParseForm(); err != nil || r.PostForm.Get("grant_type") != "client_credentials" ||
+		r.PostForm.Get("client_id") == "" || r.PostForm.Get("client_secret") == "" {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{
+			"error":             "invalid_client",
+			"error_description": "Invalid client credentials",
+		})
+		return
+	}
+
+	s.mu.Lock()
+	ttl := s.TokenTTL
+	s.mu.Unlock()
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"access_token": issuedAccessTokenPrefix + s.nextID(),
+		"token_type":   "Bearer",
+		"expires_in":   ttl,
+	})
+}
+
+func (s *Server) handleStackSpotCreate(w http.ResponseWriter, r *http.Request, body []byte) {
+	if !strings.HasPrefix(r.Header.Get("Authorization"), "Bearer "+issuedAccessTokenPrefix) {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"detail": "Unauthorized"})
+		return
+	}
+
+	var req struct {
+		InputData string `json:"input_data"`
+	}
+	if err := json.Unmarshal(body, &req); err != nil {
+		writeJSON(w, http.StatusUnprocessableEntity, map[string]string{"detail": "input_data is required"})
+		return
+	}
+
+	executionID := "exec-" + s.nextID()
+	s.mu.Lock()
+	s.executions[executionID] = &execution{prompt: req.InputData}
+	s.mu.Unlock()
+
+	writeJSON(w, http.StatusOK, executionID)
+}
+
+func (s *Server) handleStackSpotCallback(w http.ResponseWriter, r *http.Request, _ []byte) {
+	if !strings.HasPrefix(r.Header.Get("Authorization"), "Bearer "+issuedAccessTokenPrefix) {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"detail": "Unauthorized"})
+		return
+	}
+
+	// As consultas da mesma execução podem ser simultâneas; os campos são copiados sob o mutex
+	executionID := r.PathValue("id")
+	var polls int
+	var prompt string
+	s.mu.Lock()
+	exec, ok := s.executions[executionID]
+	if ok {
+		exec.polls++
+		polls, prompt = exec.polls, exec.prompt
+	}
+	pollsUntilDone := s.StackSpotPollsUntilDone
+	failure := s.StackSpotFailure
+	s.mu.Unlock()
+
+	if !ok {
+		writeJSON(w, http.StatusNotFound, map[string]string{"detail": "Execution not found"})
+		return
+	}
+
+	response := map[string]interface{}{
+		"execution_id":       executionID,
+		"quick_command_slug": "fake-slug",
+		"conversation_id":    "fake-conversation",
+	}
+
+	switch {
+	case polls < pollsUntilDone:
+		response["progress"] = map[string]interface{}{
+			"start":                time.Now().UTC().Format(time.RFC3339),
+			"execution_percentage": float64(polls) / float64(pollsUntilDone),
+			"status":               "RUNNING",
+		}
+	case failure:
+		response["progress"] = map[string]interface{}{
+			"execution_percentage": 1.0,
+			"status":               "FAILURE",
+		}
+	default:
+		response["progress"] = map[string]interface{}{
+			"execution_percentage": 1.0,
+			"status":               "COMPLETED",
+		}
+		response["steps"] = []map[string]interface{}{{
+			"step_name":       "answer",
+			"execution_order": 1,
+			"type":            "LLM",
+			"step_result": map[string]interface{}{
+				"answer":  s.reply(RouteStackSpotCallback, prompt),
+				"sources": []interface{}{},
+			},
+		}}
+	}
+
+	writeJSON(w, http.StatusOK, response)
+}
+
+func (s *Server) nextID() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sequence++
+	return fmt.Sprintf("%d", s.sequence)
+}
+
+// countTokens aproxima a contagem de tokens pelo número de palavras
+func countTokens(text string) int {
+	return len(strings.Fields(text))
+}
+
+func writeJSON(w http.ResponseWriter, status int, payload interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(payload)
+}
@@ -0,0 +1,58 @@
+package llmtest
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func chat(t *testing.T, s *Server, prompt string) *http.Response {
+	t.Helper()
+	req, _ := http.NewRequest(http.MethodPost, s.OpenAIURL()+"/chat/completions",
+		strings.NewReader(`{"model":"gpt-4o","messages":[{"role":"user","content":"`+prompt+`"}]}`))
+	req.Header.Set("Authorization", "Bearer chave")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("requisição: %v", err)
+	}
+	return resp
+}
+
+func TestSetReplyWhileServing(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp := chat(t, s, "oi")
+			resp.Body.Close()
+		}()
+	}
+	s.SetReply(func(route, prompt string) string { return "fixa" })
+	wg.Wait()
+
+	if n := len(s.Requests(RouteOpenAIChat)); n != 4 {
+		t.Fatalf("requisições = %d, esperado 4", n)
+	}
+}
+
+func TestFailNext(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+	s.FailNext(RouteOpenAIChat, 1, Failure{Status: http.StatusServiceUnavailable, RetryAfter: "2"})
+
+	resp := chat(t, s, "oi")
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusServiceUnavailable || resp.Header.Get("Retry-After") != "2" {
+		t.Fatalf("primeira resposta = %d, Retry-After %q", resp.StatusCode, resp.Header.Get("Retry-After"))
+	}
+	resp = chat(t, s, "oi")
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("segunda resposta = %d, esperado 200", resp.StatusCode)
+	}
+}
@@ -0,0 +1,234 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/chatcomStackspotAI/models"
+	"github.com/chatcomStackspotAI/retry"
+	"go.uber.org/zap"
+)
+
+// ProviderMock é o provedor embutido para desenvolvimento e testes de interface
+const ProviderMock = "MOCK"
+
+// Modos de resposta do provedor MOCK
+const (
+	MockModeEcho   = "echo"   // Devolve o prompt recebido
+	MockModeCanned = "canned" // Devolve sempre a mesma resposta
+	MockModeScript = "script" // Escolhe a resposta pela primeira regra que casar com o prompt
+)
+
+const defaultMockResponse = "Esta é uma resposta simulada do provedor MOCK."
+
+// MockRule é uma regra do modo script; Match é uma expressão regular aplicada ao prompt
+type MockRule struct {
+	Match    string `json:"match"`
+	Response string `json:"response,omitempty"`
+	Status   int    `json:"status,omitempty"`  // Quando definido, a regra responde com erro HTTP
+	Latency  string `json:"latency,omitempty"` // Sobrescreve a latência padrão (ex.: "3s")
+
+	pattern *regexp.Regexp
+	latency time.Duration
+}
+
+// MockConfig define o comportamento do provedor MOCK
+type MockConfig struct {
+	Mode        string
+	Response    string        // Resposta fixa do modo canned e padrão do modo script
+	Rules       []MockRule    // Regras do modo script, avaliadas em ordem
+	Latency     time.Duration // Atraso antes de responder
+	ChunkDelay  time.Duration // Atraso entre os trechos no modo streaming
+	ErrorRate   float64       // Probabilidade (0 a 1) de falhar com ErrorStatus
+	ErrorStatus int
+}
+
+// MockConfigFromEnv lê MOCK_MODE, MOCK_RESPONSE, MOCK_SCRIPT_FILE, MOCK_LATENCY,
+// MOCK_CHUNK_DELAY, MOCK_ERROR_RATE e MOCK_ERROR_STATUS
+func MockConfigFromEnv() (MockConfig, error) {
+	config := MockConfig{
+		Mode:        os.Getenv("MOCK_MODE"),
+		Response:    os.Getenv("MOCK_RESPONSE"),
+		ChunkDelay:  50 * time.Millisecond,
+		ErrorStatus: 503,
+	}
+	if config.Mode == "" {
+		config.Mode = MockModeEcho
+	}
+	if v, err := time.ParseDuration(os.Getenv("MOCK_LATENCY")); err == nil && v > 0 {
+		config.Latency = v
+	}
+	if v, err := time.ParseDuration(os.Getenv("MOCK_CHUNK_DELAY")); err == nil && v >= 0 {
+		config.ChunkDelay = v
+	}
+	if v, err := strconv.ParseFloat(os.Getenv("MOCK_ERROR_RATE"), 64); err == nil && v > 0 {
+		config.ErrorRate = v
+	}
+	if v, err := strconv.Atoi(os.Getenv("MOCK_ERROR_STATUS")); err == nil && v >= 400 {
+		config.ErrorStatus = v
+	}
+
+	if path := os.Getenv("MOCK_SCRIPT_FILE"); path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return config, fmt.Errorf("erro ao ler MOCK_SCRIPT_FILE: %w", err)
+		}
+		if err := json.Unmarshal(data, &config.Rules); err != nil {
+			return config, fmt.Errorf("MOCK_SCRIPT_FILE inválido: %w", err)
+		}
+		if os.Getenv("MOCK_MODE") == "" {
+			config.Mode = MockModeScript
+		}
+	}
+	return config, nil
+}
+
+type MockClient struct {
+	config MockConfig
+	logger *zap.Logger
+}
+
+// NewMockClient valida a configuração e compila as regras do modo script
+func NewMockClient(config MockConfig, logger *zap.Logger) (*MockClient, error) {
+	switch config.Mode {
+	case MockModeEcho, MockModeCanned, MockModeScript:
+	default:
+		return nil, fmt.Errorf("MOCK_MODE inválido: %q", config.Mode)
+	}
+	if config.Response == "" {
+		config.Response = defaultMockResponse
+	}
+
+	rules := make([]MockRule, len(config.Rules))
+	for i, rule := range config.Rules {
+		pattern, err := regexp.Compile(rule.Match)
+		if err != nil {
+			return nil, fmt.Errorf("regra %d do MOCK inválida: %w", i, err)
+		}
+		rule.pattern = pattern
+		if rule.Latency != "" {
+			if rule.latency, err = time.ParseDuration(rule.Latency); err != nil {
+				return nil, fmt.Errorf("latência da regra %d do MOCK inválida: %w", i, err)
+			}
+		}
+		rules[i] = rule
+	}
+	config.Rules = rules
+
+	return &MockClient{
+		config: config,
+		logger: logger,
+	}, nil
+}
+
+func (c *MockClient) GetModelName() string {
+	return "mock-" + c.config.Mode
+}
+
+func (c *MockClient) SendPrompt(ctx context.Context, prompt string, history []models.Message) (string, error) {
+	return c.SendPromptStream(ctx, prompt, history, nil)
+}
+
+// SendPromptStream entrega a resposta palavra a palavra, aguardando ChunkDelay entre os trechos
+func (c *MockClient) SendPromptStream(ctx context.Context, prompt string, history []models.Message, onChunk func(chunk string) error) (string, error) {
+	response, latency, err := c.resolve(prompt)
+
+	c.logger.Info("Respondendo com o provedor MOCK",
+		zap.String("mode", c.config.Mode),
+		zap.Duration("latency", latency),
+		zap.Bool("error", err != nil))
+
+	if err := sleep(ctx, latency); err != nil {
+		return "", err
+	}
+	if err != nil {
+		return "", err
+	}
+	if onChunk == nil {
+		return response, nil
+	}
+
+	for i, chunk := range splitChunks(response) {
+		if i > 0 {
+			if err := sleep(ctx, c.config.ChunkDelay); err != nil {
+				return "", err
+			}
+		}
+		if err := onChunk(chunk); err != nil {
+			return "", err
+		}
+	}
+	return response, nil
+}
+
+// resolve determina a resposta, a latência e o erro simulado para o prompt
+func (c *MockClient) resolve(prompt string) (string, time.Duration, error) {
+	latency := c.config.Latency
+
+	if c.config.ErrorRate > 0 && rand.Float64() < c.config.ErrorRate {
+		return "", latency, mockError(c.config.ErrorStatus)
+	}
+
+	switch c.config.Mode {
+	case MockModeEcho:
+		return "Echo: " + prompt, latency, nil
+	case MockModeScript:
+		for _, rule := range c.config.Rules {
+			if !rule.pattern.MatchString(prompt) {
+				continue
+			}
+			if rule.latency > 0 {
+				latency = rule.latency
+			}
+			if rule.Status != 0 {
+				return "", latency, mockError(rule.Status)
+			}
+			return rule.Response, latency, nil
+		}
+	}
+	return c.config.Response, latency, nil
+}
+
+// mockError simula a resposta de erro de um provedor real, passando pelo mesmo tratamento de retry
+func mockError(status int) error {
+	return fmt.Errorf("erro simulado pelo provedor MOCK: %w", &retry.HTTPError{
+		StatusCode: status,
+		Body:       fmt.Sprintf(`{"error":"simulated %d"}`, status),
+	})
+}
+
+// splitChunks divide o texto em trechos que preservam os espaços originais
+func splitChunks(text string) []string {
+	var chunks []string
+	for len(text) > 0 {
+		next := strings.IndexAny(text[1:], " \n")
+		if next < 0 {
+			chunks = append(chunks, text)
+			break
+		}
+		chunks = append(chunks, text[:next+1])
+		text = text[next+1:]
+	}
+	return chunks
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
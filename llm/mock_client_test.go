@@ -0,0 +1,107 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/chatcomStackspotAI/retry"
+	"go.uber.org/zap"
+)
+
+func newTestMockClient(t *testing.T, config MockConfig) *MockClient {
+	t.Helper()
+	client, err := NewMockClient(config, zap.NewNop())
+	if err != nil {
+		t.Fatalf("NewMockClient: %v", err)
+	}
+	return client
+}
+
+func TestMockClientModes(t *testing.T) {
+	tests := []struct {
+		name   string
+		config MockConfig
+		prompt string
+		want   string
+	}{
+		{"echo", MockConfig{Mode: MockModeEcho}, "oi", "Echo: oi"},
+		{"canned", MockConfig{Mode: MockModeCanned, Response: "sempre igual"}, "oi", "sempre igual"},
+		{"canned padrão", MockConfig{Mode: MockModeCanned}, "oi", defaultMockResponse},
+		{"script", MockConfig{Mode: MockModeScript, Rules: []MockRule{{Match: "(?i)tempo", Response: "Ensolarado"}}}, "Qual o TEMPO?", "Ensolarado"},
+		{"script sem regra", MockConfig{Mode: MockModeScript, Response: "padrão", Rules: []MockRule{{Match: "tempo", Response: "Ensolarado"}}}, "oi", "padrão"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			response, err := newTestMockClient(t, tt.config).SendPrompt(context.Background(), tt.prompt, nil)
+			if err != nil {
+				t.Fatalf("SendPrompt: %v", err)
+			}
+			if response != tt.want {
+				t.Fatalf("resposta = %q, esperado %q", response, tt.want)
+			}
+		})
+	}
+}
+
+func TestMockClientScriptedError(t *testing.T) {
+	client := newTestMockClient(t, MockConfig{Mode: MockModeScript, Rules: []MockRule{{Match: "falhe", Status: http.StatusTooManyRequests}}})
+
+	_, err := client.SendPrompt(context.Background(), "por favor falhe", nil)
+	var httpErr *retry.HTTPError
+	if !errors.As(err, &httpErr) || httpErr.StatusCode != http.StatusTooManyRequests {
+		t.Fatalf("erro = %v, esperado HTTP 429", err)
+	}
+	var llmErr *Error
+	if !errors.As(ClassifyError(ProviderMock, err), &llmErr) || llmErr.Code != ErrCodeRateLimited {
+		t.Fatalf("classificação = %v, esperado %s", err, ErrCodeRateLimited)
+	}
+}
+
+func TestMockClientErrorRate(t *testing.T) {
+	client := newTestMockClient(t, MockConfig{Mode: MockModeEcho, ErrorRate: 1, ErrorStatus: http.StatusServiceUnavailable})
+
+	_, err := client.SendPrompt(context.Background(), "oi", nil)
+	var httpErr *retry.HTTPError
+	if !errors.As(err, &httpErr) || httpErr.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("erro = %v, esperado HTTP 503", err)
+	}
+}
+
+func TestMockClientStreamsChunks(t *testing.T) {
+	client := newTestMockClient(t, MockConfig{Mode: MockModeCanned, Response: "uma resposta\nem partes"})
+
+	var chunks []string
+	response, err := Stream(context.Background(), client, "oi", nil, func(chunk string) error {
+		chunks = append(chunks, chunk)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Stream: %v", err)
+	}
+	if len(chunks) != 4 || strings.Join(chunks, "") != response || response != "uma resposta\nem partes" {
+		t.Fatalf("trechos = %q, resposta = %q", chunks, response)
+	}
+}
+
+func TestMockClientLatencyRespectsContext(t *testing.T) {
+	client := newTestMockClient(t, MockConfig{Mode: MockModeEcho, Latency: time.Minute})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := client.SendPrompt(ctx, "oi", nil); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("erro = %v, esperado context.DeadlineExceeded", err)
+	}
+}
+
+func TestNewMockClientRejectsInvalidConfig(t *testing.T) {
+	if _, err := NewMockClient(MockConfig{Mode: "aleatorio"}, zap.NewNop()); err == nil {
+		t.Fatal("modo inválido deveria ser recusado")
+	}
+	if _, err := NewMockClient(MockConfig{Mode: MockModeScript, Rules: []MockRule{{Match: "("}}}, zap.NewNop()); err == nil {
+		t.Fatal("expressão regular inválida deveria ser recusada")
+	}
+}
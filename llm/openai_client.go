@@ -5,24 +5,46 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"github.com/chatcomStackspotAI/metrics"
 	"github.com/chatcomStackspotAI/models"
+	"github.com/chatcomStackspotAI/retry"
+	"github.com/chatcomStackspotAI/tracing"
+	"go.opentelemetry.io/otel/attribute"
 	"go.uber.org/zap"
 	"io/ioutil"
 	"net/http"
 	"time"
 )
 
+// DefaultOpenAIBaseURL é a URL base da API da OpenAI
+const DefaultOpenAIBaseURL = "https://api.openai.com/v1"
+
+// OpenAIConfig reúne as opções de construção do OpenAIClient
+type OpenAIConfig struct {
+	APIKey      string
+	Model       string
+	BaseURL     string       // Padrão: DefaultOpenAIBaseURL
+	HTTPClient  *http.Client // Padrão: cliente com timeout de 60s
+	RetryPolicy retry.Policy // Valor zero: uma única tentativa
+}
+
 type OpenAIClient struct {
-	apiKey string
-	model  string
-	logger *zap.Logger
+	apiKey      string
+	model       string
+	baseURL     string
+	httpClient  *http.Client
+	retryPolicy retry.Policy
+	logger      *zap.Logger
 }
 
-func NewOpenAIClient(apiKey, model string, logger *zap.Logger) *OpenAIClient {
+func NewOpenAIClient(config OpenAIConfig, logger *zap.Logger) *OpenAIClient {
 	return &OpenAIClient{
-		apiKey: apiKey,
-		model:  model,
-		logger: logger,
+		apiKey:      config.APIKey,
+		model:       config.Model,
+		baseURL:     baseURLOrDefault(config.BaseURL, DefaultOpenAIBaseURL),
+		httpClient:  httpClientOrDefault(config.HTTPClient, 60*time.Second),
+		retryPolicy: config.RetryPolicy,
+		logger:      logger,
 	}
 }
 
@@ -31,7 +53,7 @@ func (c *OpenAIClient) GetModelName() string {
 }
 
 func (c *OpenAIClient) SendPrompt(ctx context.Context, prompt string, history []models.Message) (string, error) {
-	url := "https://api.openai.com/v1/chat/completions"
+	url := c.baseURL + "/chat/completions"
 
 	// Construir o array de mensagens
 	messages := []map[string]string{}
@@ -54,63 +76,87 @@ func (c *OpenAIClient) SendPrompt(ctx context.Context, prompt string, history []
 		"model":    c.model,
 		"messages": messages,
 	}
+	options := generationOptions(ctx)
+	if options.Temperature != nil {
+		payload["temperature"] = *options.Temperature
+	}
+	if options.MaxTokens != nil {
+		payload["max_tokens"] = *options.MaxTokens
+	}
 
 	jsonValue, _ := json.Marshal(payload)
 
-	maxAttempts := 3
-	backoff := time.Second
-
-	for attempt := 1; attempt <= maxAttempts; attempt++ {
-		req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(jsonValue))
-		if err != nil {
-			return "", fmt.Errorf("erro ao criar a requisição: %w", err)
-		}
-		req.Header.Set("Content-Type", "application/json")
-		req.Header.Set("Authorization", "Bearer "+c.apiKey)
-
-		client := &http.Client{
-			Timeout: 30 * time.Second,
-		}
-		resp, err := client.Do(req)
-		if err != nil {
-			if isTemporaryError(err) {
-				c.logger.Warn("Erro temporário ao chamar OpenAI", zap.Int("attempt", attempt), zap.Error(err))
-				if attempt < maxAttempts {
-					time.Sleep(backoff)
-					backoff *= 2 // Backoff exponencial
-					continue
-				}
-			}
-			return "", fmt.Errorf("erro ao fazer a requisição para OpenAI: %w", err)
-		}
-		defer resp.Body.Close()
-
-		bodyBytes, err := ioutil.ReadAll(resp.Body)
-		if err != nil {
-			return "", fmt.Errorf("erro ao ler a resposta da OpenAI: %w", err)
-		}
-
-		if resp.StatusCode != http.StatusOK {
-			errMsg := fmt.Sprintf("Erro na requisição à OpenAI: status %d, resposta: %s", resp.StatusCode, string(bodyBytes))
-			return "", fmt.Errorf(errMsg)
-		}
-
-		var result map[string]interface{}
-		if err := json.Unmarshal(bodyBytes, &result); err != nil {
-			return "", fmt.Errorf("erro ao decodificar a resposta da OpenAI: %w", err)
-		}
-
-		choices, ok := result["choices"].([]interface{})
-		if !ok || len(choices) == 0 {
-			return "", fmt.Errorf("Nenhuma resposta recebida da OpenAI")
-		}
-
-		firstChoice := choices[0].(map[string]interface{})
-		message := firstChoice["message"].(map[string]interface{})
-		content := message["content"].(string)
-
-		return content, nil
+	var bodyBytes []byte
+	err := retry.Do(ctx, c.retryPolicy, func(ctx context.Context, attempt int) error {
+		var err error
+		bodyBytes, err = c.doRequest(ctx, url, jsonValue, attempt)
+		return err
+	}, func(attempt int, err error, delay time.Duration) {
+		c.logger.Warn("Erro temporário ao chamar OpenAI",
+			zap.Int("attempt", attempt), zap.Duration("retry_in", delay), zap.Error(err))
+		metrics.LLMRetriesTotal.WithLabelValues(ProviderOpenAI, "chat_completions").Inc()
+	})
+	if err != nil {
+		return "", err
+	}
+
+	var result map[string]interface{}
+	if err := json.Unmarshal(bodyBytes, &result); err != nil {
+		return "", fmt.Errorf("erro ao decodificar a resposta da OpenAI: %w", err)
+	}
+
+	choices, ok := result["choices"].([]interface{})
+	if !ok || len(choices) == 0 {
+		return "", fmt.Errorf("Nenhuma resposta recebida da OpenAI")
+	}
+
+	firstChoice := choices[0].(map[string]interface{})
+	if finishReason, _ := firstChoice["finish_reason"].(string); finishReason == "content_filter" {
+		return "", NewError(ErrCodeContentFiltered, ProviderOpenAI, fmt.Errorf("resposta bloqueada pelo filtro de conteúdo da OpenAI"))
+	}
+	message := firstChoice["message"].(map[string]interface{})
+	content, _ := message["content"].(string)
+
+	// Registrar o consumo de tokens informado pela API
+	if usage, ok := result["usage"].(map[string]interface{}); ok {
+		promptTokens, _ := usage["prompt_tokens"].(float64)
+		completionTokens, _ := usage["completion_tokens"].(float64)
+		metrics.ObserveTokens(ProviderOpenAI, c.model, int(promptTokens), int(completionTokens))
+		recordUsage(ctx, int(promptTokens), int(completionTokens))
+	}
+
+	return content, nil
+}
+
+// doRequest executa uma única chamada HTTP ao endpoint de chat completions
+func (c *OpenAIClient) doRequest(ctx context.Context, url string, body []byte, attempt int) (bodyBytes []byte, err error) {
+	ctx, span := tracing.Start(ctx, "openai.chat_completions",
+		attribute.String("llm.model", c.model),
+		attribute.Int("llm.attempt", attempt))
+	defer func() { tracing.End(span, err) }()
+
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(body))
+	if err != nil {
+		return nil, fmt.Errorf("erro ao criar a requisição: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+c.apiKey)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao fazer a requisição para OpenAI: %w", err)
+	}
+	defer resp.Body.Close()
+	span.SetAttributes(attribute.Int("http.status_code", resp.StatusCode))
+
+	bodyBytes, err = ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao ler a resposta da OpenAI: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("Erro na requisição à OpenAI: %w", retry.NewHTTPError(resp, bodyBytes))
 	}
 
-	return "", fmt.Errorf("Falha ao obter resposta da OpenAI após %d tentativas", maxAttempts)
+	return bodyBytes, nil
 }
@@ -0,0 +1,88 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/chatcomStackspotAI/llm/llmtest"
+	"github.com/chatcomStackspotAI/models"
+	"github.com/chatcomStackspotAI/retry"
+	"go.uber.org/zap"
+)
+
+// testRetryPolicy repete rapidamente, para que os testes de falha não esperem o backoff real
+var testRetryPolicy = retry.Policy{MaxAttempts: 3, InitialBackoff: time.Millisecond, MaxBackoff: time.Millisecond, Multiplier: 1}
+
+func newTestOpenAIClient(fake *llmtest.Server) *OpenAIClient {
+	return NewOpenAIClient(OpenAIConfig{
+		APIKey:      "fake-openai-key",
+		Model:       "gpt-4o",
+		BaseURL:     fake.OpenAIURL(),
+		RetryPolicy: testRetryPolicy,
+	}, zap.NewNop())
+}
+
+func TestOpenAIClientSendPrompt(t *testing.T) {
+	fake := llmtest.NewServer()
+	defer fake.Close()
+
+	ctx, usage := WithUsage(context.Background())
+	history := []models.Message{{Role: "user", Content: "oi"}, {Role: "assistant", Content: "olá"}}
+	response, err := newTestOpenAIClient(fake).SendPrompt(ctx, "tudo bem?", history)
+	if err != nil {
+		t.Fatalf("SendPrompt: %v", err)
+	}
+	if response != "eco: tudo bem?" {
+		t.Fatalf("resposta = %q", response)
+	}
+	if got := usage(); got.PromptTokens != 4 || got.CompletionTokens != 3 {
+		t.Fatalf("uso = %+v", got)
+	}
+
+	requests := fake.Requests(llmtest.RouteOpenAIChat)
+	if len(requests) != 1 {
+		t.Fatalf("requisições = %d, esperado 1", len(requests))
+	}
+	if auth := requests[0].Header.Get("Authorization"); auth != "Bearer fake-openai-key" {
+		t.Fatalf("Authorization = %q", auth)
+	}
+	if !strings.Contains(requests[0].Body, `"olá"`) {
+		t.Fatalf("histórico ausente no corpo: %s", requests[0].Body)
+	}
+}
+
+func TestOpenAIClientRetriesUnavailable(t *testing.T) {
+	fake := llmtest.NewServer()
+	defer fake.Close()
+	fake.FailNext(llmtest.RouteOpenAIChat, 2, llmtest.Failure{Status: http.StatusServiceUnavailable, Body: `{"error":{"message":"overloaded"}}`})
+
+	response, err := newTestOpenAIClient(fake).SendPrompt(context.Background(), "oi", nil)
+	if err != nil {
+		t.Fatalf("SendPrompt: %v", err)
+	}
+	if response != "eco: oi" {
+		t.Fatalf("resposta = %q", response)
+	}
+	if n := len(fake.Requests(llmtest.RouteOpenAIChat)); n != 3 {
+		t.Fatalf("tentativas = %d, esperado 3", n)
+	}
+}
+
+func TestOpenAIClientClassifiesAuthError(t *testing.T) {
+	fake := llmtest.NewServer()
+	defer fake.Close()
+	fake.FailNext(llmtest.RouteOpenAIChat, 1, llmtest.Failure{Status: http.StatusUnauthorized, Body: `{"error":{"message":"Incorrect API key provided"}}`})
+
+	_, err := newTestOpenAIClient(fake).SendPrompt(context.Background(), "oi", nil)
+	var llmErr *Error
+	if !errors.As(ClassifyError(ProviderOpenAI, err), &llmErr) || llmErr.Code != ErrCodeAuthFailed {
+		t.Fatalf("erro = %v, esperado %s", err, ErrCodeAuthFailed)
+	}
+	if n := len(fake.Requests(llmtest.RouteOpenAIChat)); n != 1 {
+		t.Fatalf("tentativas = %d; erro de autenticação não deve ser repetido", n)
+	}
+}
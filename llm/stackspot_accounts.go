@@ -0,0 +1,121 @@
+package llm
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// defaultStackSpotAccount é o nome da conta configurada por CLIENT_ID e CLIENT_SECRET
+const defaultStackSpotAccount = "default"
+
+// slugPattern é o formato aceito para os slugs de quick commands
+var slugPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]*$`)
+
+// validateSlug recusa slugs fora de slugPattern, que poderiam alterar o caminho da URL da API
+func validateSlug(slug string) error {
+	if !slugPattern.MatchString(slug) {
+		return fmt.Errorf("%w: slug '%s' da StackSpot; use letras minúsculas, números e hífens", ErrInvalidModel, slug)
+	}
+	return nil
+}
+
+// StackSpotAccount é um par de credenciais da StackSpot e os slugs de quick commands que ele atende
+type StackSpotAccount struct {
+	Name         string
+	ClientID     string
+	ClientSecret string
+	Realm        string
+	Slugs        []string
+}
+
+// stackSpotAccountsFromEnv lê as contas nomeadas listadas em STACKSPOT_ACCOUNTS (ex.: "time-a,time-b").
+// Cada conta usa STACKSPOT_ACCOUNT_<NOME>_CLIENT_ID, _CLIENT_SECRET, _REALM e _SLUGS, com o nome em
+// maiúsculas e "-" trocado por "_"; sem _REALM, vale o realm padrão informado.
+func stackSpotAccountsFromEnv(secret func(string) string, defaultRealm string) ([]StackSpotAccount, error) {
+	var accounts []StackSpotAccount
+	for _, name := range splitList(os.Getenv("STACKSPOT_ACCOUNTS")) {
+		if name == defaultStackSpotAccount {
+			return nil, fmt.Errorf("o nome de conta '%s' é reservado para CLIENT_ID e CLIENT_SECRET", name)
+		}
+		prefix := "STACKSPOT_ACCOUNT_" + strings.ToUpper(strings.ReplaceAll(name, "-", "_")) + "_"
+		account := StackSpotAccount{
+			Name:         name,
+			ClientID:     secret(prefix + "CLIENT_ID"),
+			ClientSecret: secret(prefix + "CLIENT_SECRET"),
+			Realm:        os.Getenv(prefix + "REALM"),
+			Slugs:        splitList(os.Getenv(prefix + "SLUGS")),
+		}
+		if account.ClientID == "" || account.ClientSecret == "" {
+			return nil, fmt.Errorf("a conta '%s' da StackSpot precisa de %sCLIENT_ID e %sCLIENT_SECRET", name, prefix, prefix)
+		}
+		if account.Realm == "" {
+			account.Realm = defaultRealm
+		}
+		accounts = append(accounts, account)
+	}
+	return accounts, nil
+}
+
+// splitList separa uma lista por vírgulas, ignorando espaços e itens vazios
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// stackSpotAccounts escolhe a conta (e o TokenManager) usada por cada slug
+type stackSpotAccounts struct {
+	tokens      map[string]*TokenManager // Indexado pelo nome da conta
+	bySlug      map[string]string        // Slug -> nome da conta
+	defaultSlug string                   // SLUG_NAME
+}
+
+// resolve interpreta o modelo pedido para a StackSpot: vazio usa o SLUG_NAME, "<conta>/<slug>"
+// escolhe a conta explicitamente, e um slug sozinho usa a conta que o declarou em _SLUGS ou,
+// na falta dela, a conta padrão.
+func (a *stackSpotAccounts) resolve(model string) (*TokenManager, string, error) {
+	slug := model
+	if model == "" || model == defaultModel(ProviderStackSpot) {
+		slug = a.defaultSlug
+	}
+	if slug == "" {
+		return nil, "", fmt.Errorf("%w: nenhum slug informado e SLUG_NAME não está definido", ErrUnsupportedProvider)
+	}
+
+	if name, commandSlug, ok := strings.Cut(slug, "/"); ok {
+		if err := validateSlug(commandSlug); err != nil {
+			return nil, "", err
+		}
+		tokenManager, exists := a.tokens[name]
+		if !exists {
+			return nil, "", fmt.Errorf("%w: conta '%s' da StackSpot", ErrUnsupportedProvider, name)
+		}
+		return tokenManager, commandSlug, nil
+	}
+	if err := validateSlug(slug); err != nil {
+		return nil, "", err
+	}
+
+	name, exists := a.bySlug[slug]
+	if !exists {
+		name = defaultStackSpotAccount
+	}
+	tokenManager, exists := a.tokens[name]
+	if !exists {
+		return nil, "", fmt.Errorf("%w: nenhuma conta da StackSpot atende o slug '%s'", ErrUnsupportedProvider, slug)
+	}
+	return tokenManager, slug, nil
+}
+
+// close interrompe a renovação em background dos tokens de todas as contas
+func (a *stackSpotAccounts) close() {
+	for _, tokenManager := range a.tokens {
+		tokenManager.Close()
+	}
+}
@@ -0,0 +1,61 @@
+package llm
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestResolveModelValidatesStackSpotSlug(t *testing.T) {
+	valid := []string{"spot", "spot/meu-quick-command", "stackspot/time-a/qc-2"}
+	for _, name := range valid {
+		if provider, _, err := ResolveModel(name); err != nil || provider != ProviderStackSpot {
+			t.Errorf("ResolveModel(%q) = %s, %v", name, provider, err)
+		}
+	}
+
+	invalid := []string{
+		"spot/../../admin",
+		"spot/slug?conversation_id=x",
+		"spot/time-a/",
+		"spot/Slug",
+		"spot/-slug",
+		"spot/slug%2F..",
+	}
+	for _, name := range invalid {
+		if _, _, err := ResolveModel(name); !errors.Is(err, ErrInvalidModel) {
+			t.Errorf("ResolveModel(%q) = %v, esperado ErrInvalidModel", name, err)
+		}
+	}
+}
+
+func TestStackSpotAccountsResolve(t *testing.T) {
+	defaultTokens, teamTokens := &TokenManager{}, &TokenManager{}
+	accounts := &stackSpotAccounts{
+		tokens:      map[string]*TokenManager{defaultStackSpotAccount: defaultTokens, "time-a": teamTokens},
+		bySlug:      map[string]string{"qc-time-a": "time-a"},
+		defaultSlug: "qc-padrao",
+	}
+
+	tests := []struct {
+		model  string
+		tokens *TokenManager
+		slug   string
+	}{
+		{"", defaultTokens, "qc-padrao"},
+		{"outro-qc", defaultTokens, "outro-qc"},
+		{"qc-time-a", teamTokens, "qc-time-a"},
+		{"time-a/qc-avulso", teamTokens, "qc-avulso"},
+	}
+	for _, tt := range tests {
+		tokens, slug, err := accounts.resolve(tt.model)
+		if err != nil || tokens != tt.tokens || slug != tt.slug {
+			t.Errorf("resolve(%q) = %p, %q, %v", tt.model, tokens, slug, err)
+		}
+	}
+
+	for _, model := range []string{"time-a/", "../callback/x", "time-a/a/b", "qc padrão"} {
+		if _, _, err := accounts.resolve(model); !errors.Is(err, ErrInvalidModel) {
+			t.Errorf("resolve(%q) = %v, esperado ErrInvalidModel", model, err)
+		}
+	}
+}
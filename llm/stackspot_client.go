@@ -4,26 +4,51 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"github.com/chatcomStackspotAI/metrics"
 	"github.com/chatcomStackspotAI/models"
+	"github.com/chatcomStackspotAI/retry"
+	"github.com/chatcomStackspotAI/tracing"
+	"go.opentelemetry.io/otel/attribute"
 	"go.uber.org/zap"
 	"io/ioutil"
 	"net/http"
+	"net/url"
 	"strings"
-	"sync"
 	"time"
 )
 
+// DefaultStackSpotBaseURL é a URL base da API de Quick Commands da StackSpot
+const DefaultStackSpotBaseURL = "https://genai-code-buddy-api.stackspot.com/v1"
+
+// StackSpotConfig reúne as opções de construção do StackSpotClient
+type StackSpotConfig struct {
+	Slug        string
+	BaseURL     string       // Padrão: DefaultStackSpotBaseURL
+	HTTPClient  *http.Client // Padrão: cliente com timeout de 30s
+	RetryPolicy retry.Policy // Valor zero: uma única tentativa
+	PollPolicy  PollPolicy   // Campos vazios usam DefaultPollPolicy
+}
+
 type StackSpotClient struct {
 	tokenManager *TokenManager
 	slug         string
+	baseURL      string
+	httpClient   *http.Client
+	retryPolicy  retry.Policy
+	pollPolicy   PollPolicy
 	logger       *zap.Logger
 }
 
-func NewStackSpotClient(tokenManager *TokenManager, slug string, logger *zap.Logger) *StackSpotClient {
+func NewStackSpotClient(tokenManager *TokenManager, config StackSpotConfig, logger *zap.Logger) *StackSpotClient {
 	return &StackSpotClient{
 		tokenManager: tokenManager,
-		slug:         slug,
+		slug:         config.Slug,
+		baseURL:      baseURLOrDefault(config.BaseURL, DefaultStackSpotBaseURL),
+		httpClient:   httpClientOrDefault(config.HTTPClient, 30*time.Second),
+		retryPolicy:  config.RetryPolicy,
+		pollPolicy:   config.PollPolicy.withDefaults(),
 		logger:       logger,
 	}
 }
@@ -65,107 +90,145 @@ func (c *StackSpotClient) SendPrompt(ctx context.Context, prompt string, history
 		return "", fmt.Errorf("erro ao enviar a requisição: %w", err)
 	}
 
-	var llmResponse string
-	maxAttempts := 50
-	for i := 0; i < maxAttempts; i++ {
+	// A partir daqui a execução continua na StackSpot e pode ser retomada pelo ID
+	notifyExecutionStarted(ctx, responseID)
+
+	return c.pollExecution(ctx, responseID)
+}
+
+// ResumeExecution volta a consultar uma execução criada anteriormente, por exemplo antes
+// de uma reinicialização do servidor
+func (c *StackSpotClient) ResumeExecution(ctx context.Context, executionID string) (string, error) {
+	c.logger.Info("Retomando execução da StackSpot", zap.String("response_id", executionID))
+	return c.pollExecution(ctx, executionID)
+}
+
+// pollExecution consulta o callback da execução até que ela termine, com a cadência definida
+// pela PollPolicy, até o prazo da política ou o do ctx, o que vier primeiro. O token é obtido
+// a cada consulta, pois a execução pode durar mais do que a validade de um token.
+func (c *StackSpotClient) pollExecution(ctx context.Context, responseID string) (string, error) {
+	policy := c.pollPolicy
+	start := time.Now()
+	deadline := start.Add(policy.Timeout)
+	interval := policy.InitialInterval
+
+	for attempt := 1; ; attempt++ {
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			metrics.StackSpotPollAttempts.WithLabelValues("timeout").Observe(float64(attempt - 1))
+			c.logger.Error("Timeout ao obter a resposta da LLM",
+				zap.Int("tentativas", attempt-1), zap.Duration("elapsed", time.Since(start)))
+			return "", NewError(ErrCodeTimeout, ProviderStackSpot, fmt.Errorf("timeout ao obter a resposta da LLM"))
+		}
+
 		select {
 		case <-ctx.Done():
+			metrics.StackSpotPollAttempts.WithLabelValues("cancelled").Observe(float64(attempt - 1))
 			return "", fmt.Errorf("contexto cancelado ou expirado: %w", ctx.Err())
-		case <-time.After(2 * time.Second):
-			llmResponse, err = c.getLLMResponseWithRetry(ctx, responseID, token)
-			if err == nil {
-				return llmResponse, nil
-			}
+		case <-time.After(min(interval, remaining)):
+		}
 
-			if strings.Contains(err.Error(), "resposta ainda não está pronta") {
-				c.logger.Info("Resposta ainda não está pronta", zap.Int("tentativa", i+1))
-				continue
-			}
+		token, err := c.tokenManager.GetAccessToken(ctx)
+		if err != nil {
+			metrics.StackSpotPollAttempts.WithLabelValues("error").Observe(float64(attempt - 1))
+			c.logger.Error("Erro ao obter o token", zap.Error(err))
+			return "", fmt.Errorf("erro ao obter o token: %w", err)
+		}
+
+		pollCtx, pollSpan := tracing.Start(ctx, "stackspot.poll",
+			attribute.String("stackspot.execution_id", responseID),
+			attribute.Int("stackspot.poll_iteration", attempt))
+		llmResponse, err := c.getLLMResponseWithRetry(pollCtx, responseID, token)
+		pollSpan.End()
+		if err == nil {
+			metrics.StackSpotPollAttempts.WithLabelValues("completed").Observe(float64(attempt))
+			return llmResponse, nil
+		}
 
-			if strings.Contains(err.Error(), "a execução da LLM falhou") {
-				c.logger.Error("Falha na execução da LLM", zap.Error(err))
-				return "", fmt.Errorf("a LLM não pôde processar a solicitação")
+		var notReady *executionNotReadyError
+		if errors.As(err, &notReady) {
+			if notReady.progress > 0 {
+				notifyProgress(ctx, notReady.progress)
 			}
+			interval = policy.next(interval, time.Since(start), notReady.progress)
+			c.logger.Info("Resposta ainda não está pronta",
+				zap.Int("tentativa", attempt),
+				zap.Float64("progress", notReady.progress),
+				zap.Duration("next_poll_in", interval))
+			continue
+		}
 
-			c.logger.Error("Erro ao obter a resposta da LLM", zap.Error(err))
-			return "", fmt.Errorf("erro ao obter a resposta: %w", err)
+		if errors.Is(err, errExecutionFailed) {
+			metrics.StackSpotPollAttempts.WithLabelValues("failed").Observe(float64(attempt))
+			c.logger.Error("Falha na execução da LLM", zap.Error(err))
+			return "", NewError(ErrCodeProviderError, ProviderStackSpot, fmt.Errorf("a LLM não pôde processar a solicitação: %w", err))
 		}
-	}
 
-	c.logger.Error("Timeout ao obter a resposta da LLM")
-	return "", fmt.Errorf("timeout ao obter a resposta da LLM")
+		metrics.StackSpotPollAttempts.WithLabelValues("error").Observe(float64(attempt))
+		c.logger.Error("Erro ao obter a resposta da LLM", zap.Error(err))
+		return "", fmt.Errorf("erro ao obter a resposta: %w", err)
+	}
 }
 
 // Implementação das funções auxiliares com retry
 
 func (c *StackSpotClient) sendRequestToLLMWithRetry(ctx context.Context, prompt, accessToken string) (string, error) {
-	maxAttempts := 5
-	backoff := time.Second
-
-	for attempt := 1; attempt <= maxAttempts; attempt++ {
-		responseID, err := c.sendRequestToLLM(ctx, prompt, accessToken)
-		if err != nil {
-			if isTemporaryError(err) {
-				c.logger.Warn("Erro temporário ao enviar requisição para GPT-4o", zap.Int("attempt", attempt), zap.Error(err))
-				if attempt < maxAttempts {
-					time.Sleep(backoff)
-					backoff *= 2 // Backoff exponencial
-					continue
-				}
-			}
-			return "", fmt.Errorf("erro ao enviar requisição para GPT-4o: %w", err)
-		}
-		return responseID, nil
+	var responseID string
+	err := retry.Do(ctx, c.retryPolicy, func(ctx context.Context, attempt int) error {
+		var err error
+		responseID, err = c.sendRequestToLLM(ctx, prompt, accessToken)
+		return err
+	}, func(attempt int, err error, delay time.Duration) {
+		c.logger.Warn("Erro temporário ao enviar requisição para GPT-4o",
+			zap.Int("attempt", attempt), zap.Duration("retry_in", delay), zap.Error(err))
+		metrics.LLMRetriesTotal.WithLabelValues(ProviderStackSpot, "create_execution").Inc()
+	})
+	if err != nil {
+		return "", fmt.Errorf("erro ao enviar requisição para GPT-4o: %w", err)
 	}
-
-	return "", fmt.Errorf("falha ao enviar requisição para GPT-4o após %d tentativas", maxAttempts)
+	return responseID, nil
 }
 
 func (c *StackSpotClient) getLLMResponseWithRetry(ctx context.Context, responseID, accessToken string) (string, error) {
-	maxAttempts := 3
-	backoff := time.Second
-
-	for attempt := 1; attempt <= maxAttempts; attempt++ {
-		llmResponse, err := c.getLLMResponse(ctx, responseID, accessToken)
-		if err != nil {
-			if isTemporaryError(err) {
-				c.logger.Warn("Erro temporário ao obter resposta da GPT-4o", zap.Int("attempt", attempt), zap.Error(err))
-				if attempt < maxAttempts {
-					time.Sleep(backoff)
-					backoff *= 2 // Backoff exponencial
-					continue
-				}
-			}
-			return "", fmt.Errorf("erro ao obter resposta da GPT-4o: %w", err)
-		}
-		return llmResponse, nil
+	var llmResponse string
+	err := retry.Do(ctx, c.retryPolicy, func(ctx context.Context, attempt int) error {
+		var err error
+		llmResponse, err = c.getLLMResponse(ctx, responseID, accessToken)
+		return err
+	}, func(attempt int, err error, delay time.Duration) {
+		c.logger.Warn("Erro temporário ao obter resposta da GPT-4o",
+			zap.Int("attempt", attempt), zap.Duration("retry_in", delay), zap.Error(err))
+		metrics.LLMRetriesTotal.WithLabelValues(ProviderStackSpot, "callback").Inc()
+	})
+	if err != nil {
+		return "", fmt.Errorf("erro ao obter resposta da GPT-4o: %w", err)
 	}
-
-	return "", fmt.Errorf("falha ao obter resposta da GPT-4o após %d tentativas", maxAttempts)
+	return llmResponse, nil
 }
 
-func (c *StackSpotClient) sendRequestToLLM(ctx context.Context, prompt, accessToken string) (string, error) {
+func (c *StackSpotClient) sendRequestToLLM(ctx context.Context, prompt, accessToken string) (responseID string, err error) {
+	ctx, span := tracing.Start(ctx, "stackspot.create_execution", attribute.String("stackspot.slug", c.slug))
+	defer func() { tracing.End(span, err) }()
+
 	conversationID := generateUUID()
 
-	url := fmt.Sprintf("https://genai-code-buddy-api.stackspot.com/v1/quick-commands/create-execution/%s?conversation_id=%s", c.slug, conversationID)
-	c.logger.Info("Fazendo POST para URL", zap.String("url", url))
+	requestURL := fmt.Sprintf("%s/quick-commands/create-execution/%s?conversation_id=%s",
+		c.baseURL, url.PathEscape(c.slug), url.QueryEscape(conversationID))
+	c.logger.Info("Fazendo POST para URL", zap.String("url", requestURL))
 
 	requestBody := map[string]string{
 		"input_data": prompt,
 	}
 	jsonValue, _ := json.Marshal(requestBody)
 
-	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(jsonValue))
+	req, err := http.NewRequestWithContext(ctx, "POST", requestURL, bytes.NewBuffer(jsonValue))
 	if err != nil {
 		return "", fmt.Errorf("erro ao criar a requisição: %w", err)
 	}
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("Authorization", "Bearer "+accessToken)
 
-	client := &http.Client{
-		Timeout: 30 * time.Second,
-	}
-	resp, err := client.Do(req)
+	resp, err := c.httpClient.Do(req)
 	if err != nil {
 		return "", fmt.Errorf("erro ao fazer a requisição: %w", err)
 	}
@@ -178,10 +241,9 @@ func (c *StackSpotClient) sendRequestToLLM(ctx context.Context, prompt, accessTo
 
 	if resp.StatusCode != http.StatusOK {
 		c.logger.Error("Erro na requisição à LLM", zap.Int("status_code", resp.StatusCode), zap.String("response", string(bodyBytes)))
-		return "", fmt.Errorf("erro na requisição à LLM: status %d, resposta: %s", resp.StatusCode, string(bodyBytes))
+		return "", fmt.Errorf("erro na requisição à LLM: %w", retry.NewHTTPError(resp, bodyBytes))
 	}
 
-	var responseID string
 	if err := json.Unmarshal(bodyBytes, &responseID); err != nil {
 		c.logger.Error("Erro ao deserializar o responseID", zap.Error(err))
 		return "", fmt.Errorf("erro ao deserializar o responseID: %w", err)
@@ -191,11 +253,21 @@ func (c *StackSpotClient) sendRequestToLLM(ctx context.Context, prompt, accessTo
 	return responseID, nil
 }
 
-func (c *StackSpotClient) getLLMResponse(ctx context.Context, responseID, accessToken string) (string, error) {
-	url := fmt.Sprintf("https://genai-code-buddy-api.stackspot.com/v1/quick-commands/callback/%s", responseID)
-	c.logger.Info("Fazendo GET para URL", zap.String("url", url))
+func (c *StackSpotClient) getLLMResponse(ctx context.Context, responseID, accessToken string) (llmAnswer string, err error) {
+	ctx, span := tracing.Start(ctx, "stackspot.callback", attribute.String("stackspot.execution_id", responseID))
+	defer func() {
+		// Execução ainda em andamento não é uma falha do span
+		spanErr := err
+		if errors.Is(spanErr, errResponseNotReady) {
+			spanErr = nil
+		}
+		tracing.End(span, spanErr)
+	}()
 
-	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	requestURL := fmt.Sprintf("%s/quick-commands/callback/%s", c.baseURL, url.PathEscape(responseID))
+	c.logger.Info("Fazendo GET para URL", zap.String("url", requestURL))
+
+	req, err := http.NewRequestWithContext(ctx, "GET", requestURL, nil)
 	if err != nil {
 		c.logger.Error("Erro ao criar a requisição GET", zap.Error(err))
 		return "", fmt.Errorf("erro ao criar a requisição GET: %w", err)
@@ -203,10 +275,7 @@ func (c *StackSpotClient) getLLMResponse(ctx context.Context, responseID, access
 	req.Header.Set("Authorization", "Bearer "+accessToken)
 	req.Header.Set("Accept", "application/json")
 
-	client := &http.Client{
-		Timeout: 30 * time.Second,
-	}
-	resp, err := client.Do(req)
+	resp, err := c.httpClient.Do(req)
 	if err != nil {
 		c.logger.Error("Erro na requisição GET para a LLM", zap.Error(err))
 		return "", fmt.Errorf("erro na requisição GET para a LLM: %w", err)
@@ -222,7 +291,7 @@ func (c *StackSpotClient) getLLMResponse(ctx context.Context, responseID, access
 	c.logger.Info("Resposta recebida", zap.Int("status_code", resp.StatusCode), zap.String("response", string(bodyBytes)))
 
 	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("erro na requisição de callback: status %d, resposta: %s", resp.StatusCode, string(bodyBytes))
+		return "", fmt.Errorf("erro na requisição de callback: %w", retry.NewHTTPError(resp, bodyBytes))
 	}
 
 	var callbackResponse CallbackResponse
@@ -231,25 +300,49 @@ func (c *StackSpotClient) getLLMResponse(ctx context.Context, responseID, access
 		return "", fmt.Errorf("erro ao deserializar a resposta JSON: %w", err)
 	}
 
+	span.SetAttributes(
+		attribute.String("stackspot.status", callbackResponse.Progress.Status),
+		attribute.Float64("stackspot.execution_percentage", callbackResponse.Progress.ExecutionPercentage))
+
 	switch callbackResponse.Progress.Status {
 	case "COMPLETED":
 		if len(callbackResponse.Steps) > 0 {
 			lastStepIndex := len(callbackResponse.Steps) - 1
 			lastStep := callbackResponse.Steps[lastStepIndex]
-			llmAnswer := lastStep.StepResult.Answer
-			return llmAnswer, nil
+			return lastStep.StepResult.Answer, nil
 		} else {
 			return "", fmt.Errorf("nenhuma resposta disponível")
 		}
 	case "FAILURE":
 		c.logger.Error("A execução falhou", zap.String("status", callbackResponse.Progress.Status))
-		return "", fmt.Errorf("a execução da LLM falhou")
+		return "", errExecutionFailed
 	default:
 		c.logger.Info("Status da execução", zap.String("status", callbackResponse.Progress.Status))
-		return "", fmt.Errorf("resposta ainda não está pronta")
+		return "", &executionNotReadyError{progress: callbackResponse.Progress.ExecutionPercentage}
 	}
 }
 
+var (
+	// errResponseNotReady indica que a execução ainda está em andamento na StackSpot
+	errResponseNotReady = errors.New("resposta ainda não está pronta")
+	// errExecutionFailed indica que a StackSpot concluiu a execução com status FAILURE
+	errExecutionFailed = errors.New("a execução da LLM falhou")
+)
+
+// executionNotReadyError é o errResponseNotReady com o progresso informado pela StackSpot
+// (execution_percentage, de 0 a 1), usado para ajustar o intervalo das consultas
+type executionNotReadyError struct {
+	progress float64
+}
+
+func (e *executionNotReadyError) Error() string {
+	return errResponseNotReady.Error()
+}
+
+func (e *executionNotReadyError) Is(target error) bool {
+	return target == errResponseNotReady
+}
+
 // Estruturas para decodificar a resposta da LLM
 
 type CallbackResponse struct {
@@ -290,93 +383,6 @@ type StepResult struct {
 	Sources []Source `json:"sources"`
 }
 
-// Implementação do TokenManager
-
-type TokenManager struct {
-	clientID     string
-	clientSecret string
-	accessToken  string
-	expiresAt    time.Time
-	mu           sync.RWMutex
-	logger       *zap.Logger
-}
-
-func NewTokenManager(clientID, clientSecret string, logger *zap.Logger) *TokenManager {
-	return &TokenManager{
-		clientID:     clientID,
-		clientSecret: clientSecret,
-		logger:       logger,
-	}
-}
-
-func (tm *TokenManager) GetAccessToken(ctx context.Context) (string, error) {
-	tm.mu.RLock()
-	token := tm.accessToken
-	expiration := tm.expiresAt
-	tm.mu.RUnlock()
-
-	if time.Until(expiration) > 60*time.Second && token != "" {
-		return token, nil
-	}
-
-	return tm.refreshToken(ctx)
-}
-
-func (tm *TokenManager) refreshToken(ctx context.Context) (string, error) {
-	tm.mu.Lock()
-	defer tm.mu.Unlock()
-
-	tm.logger.Info("Renovando o access token...")
-
-	tokenURL := "https://idm.stackspot.com/zup/oidc/oauth/token"
-	data := strings.NewReader(fmt.Sprintf(
-		"grant_type=client_credentials&client_id=%s&client_secret=%s",
-		tm.clientID, tm.clientSecret))
-
-	req, err := http.NewRequestWithContext(ctx, "POST", tokenURL, data)
-	if err != nil {
-		return "", fmt.Errorf("erro ao criar a requisição: %w", err)
-	}
-	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
-
-	client := &http.Client{
-		Timeout: 30 * time.Second,
-	}
-	resp, err := client.Do(req)
-	if err != nil {
-		return "", fmt.Errorf("erro ao fazer a requisição: %w", err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		bodyBytes, _ := ioutil.ReadAll(resp.Body)
-		errMsg := fmt.Sprintf("falha ao obter o token: %s", string(bodyBytes))
-		tm.logger.Error(errMsg)
-		return "", fmt.Errorf(errMsg)
-	}
-
-	var result map[string]interface{}
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return "", fmt.Errorf("erro ao decodificar a resposta: %w", err)
-	}
-
-	accessToken, ok := result["access_token"].(string)
-	if !ok {
-		return "", fmt.Errorf("não foi possível obter o access_token")
-	}
-
-	expiresIn, ok := result["expires_in"].(float64)
-	if !ok {
-		return "", fmt.Errorf("não foi possível obter expires_in")
-	}
-
-	tm.accessToken = accessToken
-	tm.expiresAt = time.Now().Add(time.Duration(expiresIn) * time.Second)
-	tm.logger.Info("Token renovado com sucesso", zap.Time("expires_at", tm.expiresAt))
-
-	return tm.accessToken, nil
-}
-
 // Função para gerar um UUID
 func generateUUID() string {
 	return fmt.Sprintf("%d", time.Now().UnixNano())
@@ -0,0 +1,165 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/chatcomStackspotAI/llm/llmtest"
+	"go.uber.org/zap"
+)
+
+// testPollPolicy consulta o callback a cada poucos milissegundos
+var testPollPolicy = PollPolicy{
+	InitialInterval: time.Millisecond,
+	MaxInterval:     5 * time.Millisecond,
+	Multiplier:      1.5,
+	Timeout:         5 * time.Second,
+}
+
+func newTestStackSpotClient(t *testing.T, fake *llmtest.Server) *StackSpotClient {
+	t.Helper()
+	tokenManager := NewTokenManager(TokenManagerConfig{
+		Account:      "default",
+		ClientID:     "fake-client-id",
+		ClientSecret: "fake-client-secret",
+		Realm:        "stackspot",
+		IDMURL:       fake.IDMURL(),
+		RetryPolicy:  testRetryPolicy,
+	}, zap.NewNop())
+	t.Cleanup(tokenManager.Close)
+
+	return NewStackSpotClient(tokenManager, StackSpotConfig{
+		Slug:        "fake-slug",
+		BaseURL:     fake.StackSpotURL(),
+		RetryPolicy: testRetryPolicy,
+		PollPolicy:  testPollPolicy,
+	}, zap.NewNop())
+}
+
+func TestStackSpotClientSendPrompt(t *testing.T) {
+	fake := llmtest.NewServer()
+	defer fake.Close()
+
+	var executionID string
+	ctx := WithExecutionObserver(context.Background(), func(id string) { executionID = id })
+	response, err := newTestStackSpotClient(t, fake).SendPrompt(ctx, "oi", nil)
+	if err != nil {
+		t.Fatalf("SendPrompt: %v", err)
+	}
+	if response != "eco: Usuário: oi" {
+		t.Fatalf("resposta = %q", response)
+	}
+	if executionID == "" {
+		t.Fatal("o ID da execução não foi informado ao observador")
+	}
+
+	tokens := fake.Requests(llmtest.RouteStackSpotToken)
+	if len(tokens) != 1 || tokens[0].Path != "/idm/stackspot/oidc/oauth/token" {
+		t.Fatalf("requisições de token = %+v", tokens)
+	}
+	creates := fake.Requests(llmtest.RouteStackSpotCreate)
+	if len(creates) != 1 || !strings.HasSuffix(creates[0].Path, "/create-execution/fake-slug") {
+		t.Fatalf("requisições de criação = %+v", creates)
+	}
+}
+
+func TestStackSpotClientRetriesTokenRequest(t *testing.T) {
+	fake := llmtest.NewServer()
+	defer fake.Close()
+	fake.FailNext(llmtest.RouteStackSpotToken, 1, llmtest.Failure{Status: http.StatusBadGateway})
+
+	if _, err := newTestStackSpotClient(t, fake).SendPrompt(context.Background(), "oi", nil); err != nil {
+		t.Fatalf("SendPrompt: %v", err)
+	}
+	if n := len(fake.Requests(llmtest.RouteStackSpotToken)); n != 2 {
+		t.Fatalf("requisições de token = %d, esperado 2", n)
+	}
+}
+
+func TestStackSpotClientRetriesCreateExecution(t *testing.T) {
+	fake := llmtest.NewServer()
+	defer fake.Close()
+	fake.FailNext(llmtest.RouteStackSpotCreate, 2, llmtest.Failure{Status: http.StatusServiceUnavailable})
+
+	if _, err := newTestStackSpotClient(t, fake).SendPrompt(context.Background(), "oi", nil); err != nil {
+		t.Fatalf("SendPrompt: %v", err)
+	}
+	if n := len(fake.Requests(llmtest.RouteStackSpotCreate)); n != 3 {
+		t.Fatalf("requisições de criação = %d, esperado 3", n)
+	}
+}
+
+func TestStackSpotClientExecutionFailure(t *testing.T) {
+	fake := llmtest.NewServer()
+	defer fake.Close()
+	fake.StackSpotFailure = true
+
+	_, err := newTestStackSpotClient(t, fake).SendPrompt(context.Background(), "oi", nil)
+	var llmErr *Error
+	if !errors.As(err, &llmErr) || llmErr.Code != ErrCodeProviderError {
+		t.Fatalf("erro = %v, esperado %s", err, ErrCodeProviderError)
+	}
+	if !errors.Is(err, errExecutionFailed) {
+		t.Fatalf("erro = %v, esperado errExecutionFailed", err)
+	}
+}
+
+func TestStackSpotClientSlowExecution(t *testing.T) {
+	fake := llmtest.NewServer()
+	defer fake.Close()
+	fake.StackSpotPollsUntilDone = 4
+
+	var progress []float64
+	ctx := WithProgressObserver(context.Background(), func(p float64) { progress = append(progress, p) })
+	response, err := newTestStackSpotClient(t, fake).SendPrompt(ctx, "oi", nil)
+	if err != nil {
+		t.Fatalf("SendPrompt: %v", err)
+	}
+	if response != "eco: Usuário: oi" {
+		t.Fatalf("resposta = %q", response)
+	}
+	if n := len(fake.Requests(llmtest.RouteStackSpotCallback)); n != 4 {
+		t.Fatalf("consultas ao callback = %d, esperado 4", n)
+	}
+	if len(progress) != 3 || progress[0] != 0.25 || progress[2] != 0.75 {
+		t.Fatalf("progresso = %v", progress)
+	}
+}
+
+func TestStackSpotClientPollTimeout(t *testing.T) {
+	fake := llmtest.NewServer()
+	defer fake.Close()
+	fake.StackSpotPollsUntilDone = 1000
+
+	client := newTestStackSpotClient(t, fake)
+	client.pollPolicy.Timeout = 30 * time.Millisecond
+	_, err := client.SendPrompt(context.Background(), "oi", nil)
+	var llmErr *Error
+	if !errors.As(err, &llmErr) || llmErr.Code != ErrCodeTimeout {
+		t.Fatalf("erro = %v, esperado %s", err, ErrCodeTimeout)
+	}
+}
+
+func TestStackSpotClientRenewsTokenWhilePolling(t *testing.T) {
+	fake := llmtest.NewServer()
+	defer fake.Close()
+	fake.StackSpotPollsUntilDone = 3
+	// Tokens com validade abaixo de minTokenValidity são renovados a cada pedido
+	fake.TokenTTL = 1
+
+	if _, err := newTestStackSpotClient(t, fake).SendPrompt(context.Background(), "oi", nil); err != nil {
+		t.Fatalf("SendPrompt: %v", err)
+	}
+
+	seen := make(map[string]bool)
+	for _, req := range fake.Requests(llmtest.RouteStackSpotCallback) {
+		seen[req.Header.Get("Authorization")] = true
+	}
+	if len(seen) != 3 {
+		t.Fatalf("as 3 consultas usaram %d tokens; cada consulta deve obter o token atual", len(seen))
+	}
+}
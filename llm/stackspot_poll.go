@@ -0,0 +1,76 @@
+package llm
+
+import (
+	"os"
+	"strconv"
+	"time"
+)
+
+// PollPolicy controla a cadência das consultas ao callback de uma execução da StackSpot.
+// O intervalo começa em InitialInterval e cresce por Multiplier até MaxInterval; quando a
+// StackSpot informa o progresso da execução, o intervalo segue a estimativa do tempo restante.
+type PollPolicy struct {
+	InitialInterval time.Duration // Espera antes da primeira consulta e menor intervalo
+	MaxInterval     time.Duration // Maior intervalo entre consultas
+	Multiplier      float64       // Crescimento do intervalo quando não há progresso informado
+	Timeout         time.Duration // Prazo total da consulta, limitado também pelo ctx
+}
+
+// DefaultPollPolicy retorna a política padrão: começa em 500ms, cresce 1,5x até 5s e
+// desiste depois de 5 minutos, o mesmo prazo das chamadas feitas pelos handlers
+func DefaultPollPolicy() PollPolicy {
+	return PollPolicy{
+		InitialInterval: 500 * time.Millisecond,
+		MaxInterval:     5 * time.Second,
+		Multiplier:      1.5,
+		Timeout:         5 * time.Minute,
+	}
+}
+
+// PollPolicyFromEnv sobrescreve os campos da política com as variáveis <PREFIX>_POLL_INITIAL_INTERVAL,
+// <PREFIX>_POLL_MAX_INTERVAL, <PREFIX>_POLL_MULTIPLIER e <PREFIX>_POLL_TIMEOUT, quando definidas
+func PollPolicyFromEnv(prefix string, base PollPolicy) PollPolicy {
+	if v, err := time.ParseDuration(os.Getenv(prefix + "_POLL_INITIAL_INTERVAL")); err == nil && v > 0 {
+		base.InitialInterval = v
+	}
+	if v, err := time.ParseDuration(os.Getenv(prefix + "_POLL_MAX_INTERVAL")); err == nil && v > 0 {
+		base.MaxInterval = v
+	}
+	if v, err := strconv.ParseFloat(os.Getenv(prefix+"_POLL_MULTIPLIER"), 64); err == nil && v >= 1 {
+		base.Multiplier = v
+	}
+	if v, err := time.ParseDuration(os.Getenv(prefix + "_POLL_TIMEOUT")); err == nil && v > 0 {
+		base.Timeout = v
+	}
+	return base
+}
+
+// withDefaults preenche os campos não informados com os valores de DefaultPollPolicy
+func (p PollPolicy) withDefaults() PollPolicy {
+	defaults := DefaultPollPolicy()
+	if p.InitialInterval <= 0 {
+		p.InitialInterval = defaults.InitialInterval
+	}
+	if p.MaxInterval < p.InitialInterval {
+		p.MaxInterval = max(defaults.MaxInterval, p.InitialInterval)
+	}
+	if p.Multiplier < 1 {
+		p.Multiplier = defaults.Multiplier
+	}
+	if p.Timeout <= 0 {
+		p.Timeout = defaults.Timeout
+	}
+	return p
+}
+
+// next calcula a espera até a próxima consulta. Com o progresso informado (entre 0 e 1),
+// estima o tempo restante pela velocidade observada até aqui e consulta na metade dele:
+// execuções perto do fim são consultadas com mais frequência, e as longas, com menos.
+func (p PollPolicy) next(current, elapsed time.Duration, progress float64) time.Duration {
+	next := time.Duration(float64(current) * p.Multiplier)
+	if progress > 0 && progress < 1 {
+		remaining := time.Duration(float64(elapsed) * (1 - progress) / progress)
+		next = remaining / 2
+	}
+	return min(max(next, p.InitialInterval), p.MaxInterval)
+}
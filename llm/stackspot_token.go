@@ -0,0 +1,265 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/chatcomStackspotAI/metrics"
+	"github.com/chatcomStackspotAI/retry"
+	"github.com/chatcomStackspotAI/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.uber.org/zap"
+)
+
+// DefaultStackSpotIDMURL é a URL base do provedor de identidade da StackSpot
+const DefaultStackSpotIDMURL = "https://idm.stackspot.com"
+
+// DefaultStackSpotRealm é o realm do IDM usado quando nenhum outro é configurado
+const DefaultStackSpotRealm = "zup"
+
+const (
+	// minTokenValidity é a validade mínima para que o token em cache seja entregue sem renovação
+	minTokenValidity = 60 * time.Second
+	// defaultTokenRenewBefore é a antecedência padrão da renovação em background
+	defaultTokenRenewBefore = 5 * time.Minute
+	// tokenRefreshTimeout limita cada renovação, incluindo as novas tentativas
+	tokenRefreshTimeout = time.Minute
+)
+
+// TokenManagerConfig reúne as credenciais e o endereço do IDM usados pelo TokenManager
+type TokenManagerConfig struct {
+	Account      string // Nome da conta, usado nos logs e traces
+	ClientID     string
+	ClientSecret string
+	Realm        string        // Padrão: DefaultStackSpotRealm
+	IDMURL       string        // Padrão: DefaultStackSpotIDMURL
+	HTTPClient   *http.Client  // Padrão: cliente com timeout de 30s
+	RetryPolicy  retry.Policy  // Valor zero: uma única tentativa
+	RenewBefore  time.Duration // Antecedência da renovação em background; padrão: 5 minutos
+}
+
+// TokenManager obtém e mantém o access token de uma conta da StackSpot. Chamadas concorrentes
+// compartilham a mesma renovação, e o token é renovado em background antes de expirar, para
+// que as requisições não precisem esperar pelo IDM.
+type TokenManager struct {
+	account      string
+	clientID     string
+	clientSecret string
+	tokenURL     string
+	httpClient   *http.Client
+	retryPolicy  retry.Policy
+	renewBefore  time.Duration
+	logger       *zap.Logger
+
+	mu          sync.Mutex
+	accessToken string
+	expiresAt   time.Time
+	inflight    *tokenRefresh // Renovação em andamento, compartilhada pelos chamadores
+	renewTimer  *time.Timer
+	closed      bool
+}
+
+// tokenRefresh é o resultado de uma renovação, disponível quando done é fechado
+type tokenRefresh struct {
+	done  chan struct{}
+	token string
+	err   error
+}
+
+func NewTokenManager(config TokenManagerConfig, logger *zap.Logger) *TokenManager {
+	realm := config.Realm
+	if realm == "" {
+		realm = DefaultStackSpotRealm
+	}
+	renewBefore := config.RenewBefore
+	if renewBefore <= 0 {
+		renewBefore = defaultTokenRenewBefore
+	}
+	return &TokenManager{
+		account:      config.Account,
+		clientID:     config.ClientID,
+		clientSecret: config.ClientSecret,
+		tokenURL:     baseURLOrDefault(config.IDMURL, DefaultStackSpotIDMURL) + "/" + url.PathEscape(realm) + "/oidc/oauth/token",
+		httpClient:   httpClientOrDefault(config.HTTPClient, 30*time.Second),
+		retryPolicy:  config.RetryPolicy,
+		renewBefore:  renewBefore,
+		logger:       logger.With(zap.String("stackspot_account", config.Account)),
+	}
+}
+
+// GetAccessToken retorna o token em cache ou aguarda a renovação, que é feita uma única vez
+// mesmo com vários chamadores simultâneos. O cancelamento do ctx só interrompe a espera deste
+// chamador; a renovação continua para os demais.
+func (tm *TokenManager) GetAccessToken(ctx context.Context) (string, error) {
+	tm.mu.Lock()
+	if tm.accessToken != "" && time.Until(tm.expiresAt) > minTokenValidity {
+		token := tm.accessToken
+		tm.mu.Unlock()
+		return token, nil
+	}
+	refresh := tm.startRefreshLocked(ctx)
+	tm.mu.Unlock()
+
+	select {
+	case <-refresh.done:
+		return refresh.token, refresh.err
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+}
+
+// Close interrompe a renovação em background
+func (tm *TokenManager) Close() {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+
+	tm.closed = true
+	if tm.renewTimer != nil {
+		tm.renewTimer.Stop()
+	}
+}
+
+// startRefreshLocked inicia uma renovação ou retorna a que já está em andamento. O contexto
+// da renovação mantém o span do chamador, mas não é cancelado junto com ele. Deve ser chamada
+// com o mutex travado.
+func (tm *TokenManager) startRefreshLocked(ctx context.Context) *tokenRefresh {
+	if tm.inflight != nil {
+		return tm.inflight
+	}
+
+	refresh := &tokenRefresh{done: make(chan struct{})}
+	tm.inflight = refresh
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), tokenRefreshTimeout)
+		token, expiresAt, err := tm.requestTokenWithRetry(ctx)
+		cancel()
+
+		tm.mu.Lock()
+		defer tm.mu.Unlock()
+
+		if err == nil {
+			tm.accessToken = token
+			tm.expiresAt = expiresAt
+			tm.scheduleRenewalLocked(renewalDelay(time.Until(expiresAt), tm.renewBefore))
+		} else if tm.accessToken != "" && time.Now().Before(tm.expiresAt) {
+			// O token atual ainda vale: ele é entregue, e a renovação é tentada de novo mais tarde
+			tm.logger.Warn("Falha ao renovar o token; usando o atual até a próxima tentativa",
+				zap.Time("expires_at", tm.expiresAt), zap.Error(err))
+			token, err = tm.accessToken, nil
+			tm.scheduleRenewalLocked((time.Until(tm.expiresAt) - minTokenValidity) / 2)
+		}
+
+		refresh.token, refresh.err = token, err
+		tm.inflight = nil
+		close(refresh.done)
+	}()
+	return refresh
+}
+
+// renewalDelay calcula quando renovar um token que vale por ttl: renewBefore antes de expirar,
+// ou na metade da validade para tokens curtos
+func renewalDelay(ttl, renewBefore time.Duration) time.Duration {
+	if ttl-renewBefore < ttl/2 {
+		return ttl / 2
+	}
+	return ttl - renewBefore
+}
+
+// scheduleRenewalLocked agenda a renovação em background. Deve ser chamada com o mutex travado.
+func (tm *TokenManager) scheduleRenewalLocked(delay time.Duration) {
+	if tm.renewTimer != nil {
+		tm.renewTimer.Stop()
+		tm.renewTimer = nil
+	}
+	if tm.closed || delay < time.Second {
+		return
+	}
+
+	tm.renewTimer = time.AfterFunc(delay, func() {
+		tm.mu.Lock()
+		if tm.closed {
+			tm.mu.Unlock()
+			return
+		}
+		tm.startRefreshLocked(context.Background())
+		tm.mu.Unlock()
+	})
+}
+
+func (tm *TokenManager) requestTokenWithRetry(ctx context.Context) (token string, expiresAt time.Time, err error) {
+	ctx, span := tracing.Start(ctx, "stackspot.refresh_token", attribute.String("stackspot.account", tm.account))
+	defer func() { tracing.End(span, err) }()
+
+	tm.logger.Info("Renovando o access token...")
+
+	err = retry.Do(ctx, tm.retryPolicy, func(ctx context.Context, attempt int) error {
+		var err error
+		token, expiresAt, err = tm.requestToken(ctx)
+		return err
+	}, func(attempt int, err error, delay time.Duration) {
+		tm.logger.Warn("Erro temporário ao obter o token",
+			zap.Int("attempt", attempt), zap.Duration("retry_in", delay), zap.Error(err))
+		metrics.LLMRetriesTotal.WithLabelValues(ProviderStackSpot, "token").Inc()
+	})
+	if err != nil {
+		tm.logger.Error("Falha ao obter o token", zap.Error(err))
+		return "", time.Time{}, err
+	}
+
+	tm.logger.Info("Token renovado com sucesso", zap.Time("expires_at", expiresAt))
+	return token, expiresAt, nil
+}
+
+func (tm *TokenManager) requestToken(ctx context.Context) (string, time.Time, error) {
+	data := url.Values{
+		"grant_type":    {"client_credentials"},
+		"client_id":     {tm.clientID},
+		"client_secret": {tm.clientSecret},
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", tm.tokenURL, strings.NewReader(data.Encode()))
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("erro ao criar a requisição: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := tm.httpClient.Do(req)
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("erro ao fazer a requisição: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		bodyBytes, _ := io.ReadAll(resp.Body)
+		err := retry.NewHTTPError(resp, bodyBytes)
+		// O IDM responde 400/401 para client_id ou client_secret inválidos
+		if resp.StatusCode == http.StatusBadRequest || resp.StatusCode == http.StatusUnauthorized {
+			return "", time.Time{}, NewError(ErrCodeAuthFailed, ProviderStackSpot, fmt.Errorf("falha ao obter o token: %w", err))
+		}
+		return "", time.Time{}, fmt.Errorf("falha ao obter o token: %w", err)
+	}
+
+	var result struct {
+		AccessToken string  `json:"access_token"`
+		ExpiresIn   float64 `json:"expires_in"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", time.Time{}, fmt.Errorf("erro ao decodificar a resposta: %w", err)
+	}
+	if result.AccessToken == "" {
+		return "", time.Time{}, fmt.Errorf("não foi possível obter o access_token")
+	}
+	if result.ExpiresIn <= 0 {
+		return "", time.Time{}, fmt.Errorf("não foi possível obter expires_in")
+	}
+
+	return result.AccessToken, time.Now().Add(time.Duration(result.ExpiresIn * float64(time.Second))), nil
+}
@@ -0,0 +1,109 @@
+package llm
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Usage é o consumo de tokens informado pelo provedor
+type Usage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
+}
+
+func (u *Usage) add(promptTokens, completionTokens int) {
+	u.PromptTokens += promptTokens
+	u.CompletionTokens += completionTokens
+	u.TotalTokens = u.PromptTokens + u.CompletionTokens
+}
+
+type usageKey struct{}
+
+type usageCollector struct {
+	mu    sync.Mutex
+	usage Usage
+}
+
+// WithUsage retorna um contexto que acumula o consumo das chamadas feitas com ele;
+// a função retornada lê o total acumulado até o momento
+func WithUsage(ctx context.Context) (context.Context, func() Usage) {
+	collector := &usageCollector{}
+	return context.WithValue(ctx, usageKey{}, collector), func() Usage {
+		collector.mu.Lock()
+		defer collector.mu.Unlock()
+		return collector.usage
+	}
+}
+
+// recordUsage soma o consumo ao coletor do contexto, quando houver
+func recordUsage(ctx context.Context, promptTokens, completionTokens int) {
+	collector, ok := ctx.Value(usageKey{}).(*usageCollector)
+	if !ok {
+		return
+	}
+	collector.mu.Lock()
+	defer collector.mu.Unlock()
+	collector.usage.add(promptTokens, completionTokens)
+}
+
+// UsageSummary acumula as chamadas e os tokens de um provedor e modelo
+type UsageSummary struct {
+	Provider string `json:"provider"`
+	Model    string `json:"model"`
+	Requests int    `json:"requests"`
+	Errors   int    `json:"errors"`
+	Usage
+}
+
+// UsageTracker agrega o consumo de todas as chamadas feitas pelos clientes do LLMManager
+type UsageTracker struct {
+	mu      sync.Mutex
+	since   time.Time
+	entries map[[2]string]*UsageSummary
+}
+
+func NewUsageTracker() *UsageTracker {
+	return &UsageTracker{
+		since:   time.Now(),
+		entries: make(map[[2]string]*UsageSummary),
+	}
+}
+
+// Record registra uma chamada ao provedor com o consumo informado
+func (t *UsageTracker) Record(provider, model string, usage Usage, err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	key := [2]string{provider, model}
+	entry, ok := t.entries[key]
+	if !ok {
+		entry = &UsageSummary{Provider: provider, Model: model}
+		t.entries[key] = entry
+	}
+	entry.Requests++
+	if err != nil {
+		entry.Errors++
+	}
+	entry.add(usage.PromptTokens, usage.CompletionTokens)
+}
+
+// Snapshot retorna o início da contagem e os totais por provedor e modelo, ordenados
+func (t *UsageTracker) Snapshot() (time.Time, []UsageSummary) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	summaries := make([]UsageSummary, 0, len(t.entries))
+	for _, entry := range t.entries {
+		summaries = append(summaries, *entry)
+	}
+	sort.Slice(summaries, func(i, j int) bool {
+		if summaries[i].Provider != summaries[j].Provider {
+			return summaries[i].Provider < summaries[j].Provider
+		}
+		return summaries[i].Model < summaries[j].Model
+	})
+	return t.since, summaries
+}
@@ -1,64 +1,18 @@
 package main
 
 import (
-	"encoding/json"
+	"context"
 	"fmt"
+	"os"
+
 	"github.com/chatcomStackspotAI/handlers"
-	"github.com/chatcomStackspotAI/llm"
-	"github.com/chatcomStackspotAI/middlewares"
+	"github.com/chatcomStackspotAI/server"
+	"github.com/chatcomStackspotAI/tracing"
 	"github.com/joho/godotenv"
+	"github.com/redis/go-redis/v9"
 	"go.uber.org/zap"
-	"html/template"
-	"net/http"
-	"os"
-	"path/filepath"
-	"time"
 )
 
-func indexHandler(logger *zap.Logger) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		tmpl, err := template.ParseFiles(filepath.Join("templates", "index.html"))
-		if err != nil {
-			logger.Error("Erro ao carregar o template", zap.Error(err))
-			http.Error(w, "Erro ao carregar o template", http.StatusInternalServerError)
-			return
-		}
-
-		data := map[string]string{
-			"OpenAIModel":  os.Getenv("OPENAI_MODEL"),
-			"ClaudeModel":  os.Getenv("CLAUDEAI_MODEL"),
-			"DefaultModel": "spot-default",
-			"CurrentModel": os.Getenv("OPENAI_MODEL"), // Modelo inicial
-		}
-
-		if data["OpenAIModel"] == "" {
-			data["OpenAIModel"] = "gpt-4o-mini"
-		}
-		if data["ClaudeModel"] == "" {
-			data["ClaudeModel"] = "claude-3-5-sonnet-20241022"
-		}
-
-		logger.Info("Carregando página com modelos",
-			zap.String("openai_model", data["OpenAIModel"]),
-			zap.String("claude_model", data["ClaudeModel"]))
-
-		tmpl.Execute(w, data)
-	}
-}
-
-func getModelsHandler(logger *zap.Logger) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		models := map[string]string{
-			"openai":  os.Getenv("OPENAI_MODEL"),
-			"claude":  os.Getenv("CLAUDEAI_MODEL"),
-			"default": "spot-default",
-		}
-
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(models)
-	}
-}
-
 func main() {
 	// Carrega variáveis de ambiente
 	err := godotenv.Load()
@@ -75,34 +29,55 @@ func main() {
 		port = "8080"
 	}
 
-	manager, err := llm.NewLLMManager(logger)
+	// Configurar o tracing OpenTelemetry
+	shutdownTracing, err := tracing.Init(context.Background(), logger)
 	if err != nil {
-		logger.Fatal("Erro ao inicializar o LLMManager", zap.Error(err))
+		logger.Fatal("Erro ao inicializar o tracing", zap.Error(err))
 	}
+	defer shutdownTracing(context.Background())
 
-	// Inicializa o ResponseStore
-	responseStore := handlers.NewResponseStore()
-
-	mux := http.NewServeMux()
-	mux.HandleFunc("/", indexHandler(logger))
-	mux.HandleFunc("/send", handlers.SendMessageHandler(manager, responseStore, logger))
-	mux.HandleFunc("/get-response", handlers.GetResponseHandler(responseStore, logger))
-	mux.HandleFunc("/api/models", getModelsHandler(logger))
-	mux.Handle("/static/", http.StripPrefix("/static/", http.FileServer(http.Dir("static"))))
+	config := server.Config{
+		Addr:   ":" + port,
+		Logger: logger,
+	}
 
-	finalHandler := middlewares.ForceHTTPSMiddleware(mux, logger)
+	// Com REDIS_URL, as respostas ficam no Redis e podem ser consultadas em qualquer instância; as
+	// mensagens em andamento também, e são retomadas quando a instância reinicia
+	if redisURL := os.Getenv("REDIS_URL"); redisURL != "" {
+		options, err := redis.ParseURL(redisURL)
+		if err != nil {
+			logger.Fatal("REDIS_URL inválida", zap.Error(err))
+		}
+		client := redis.NewClient(options)
+		defer client.Close()
+
+		store, err := handlers.NewRedisResponseStore(context.Background(), handlers.RedisResponseStoreConfig{
+			Client:    client,
+			KeyPrefix: os.Getenv("REDIS_KEY_PREFIX"),
+			// No Heroku, DYNO (ex.: "web.1") se mantém entre reinicializações
+			Instance: os.Getenv("DYNO"),
+		}, logger)
+		if err != nil {
+			logger.Fatal("Erro ao inicializar o ResponseStore no Redis", zap.Error(err))
+		}
+		defer store.Close()
+		config.ResponseStore = store
+		logger.Info("Armazenando as respostas no Redis", zap.String("addr", options.Addr))
+	}
 
-	logger.Info("Servidor iniciado", zap.String("port", port))
+	// Lê a interface do disco (ex.: DEV_ASSETS_DIR=web) para editá-la sem recompilar
+	if dir := os.Getenv("DEV_ASSETS_DIR"); dir != "" {
+		config.Assets = os.DirFS(dir)
+		config.DevMode = true
+		logger.Info("Servindo a interface a partir do disco", zap.String("dir", dir))
+	}
 
-	server := &http.Server{
-		Addr:         ":" + port,
-		Handler:      finalHandler,
-		ReadTimeout:  5 * time.Second,
-		WriteTimeout: 10 * time.Second,
-		IdleTimeout:  120 * time.Second,
+	srv, err := server.New(config)
+	if err != nil {
+		logger.Fatal("Erro ao inicializar o servidor", zap.Error(err))
 	}
 
-	if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+	if err := srv.ListenAndServe(); err != nil {
 		logger.Fatal("Erro ao iniciar o servidor", zap.Error(err))
 	}
 }
@@ -0,0 +1,112 @@
+// metrics/metrics.go
+
+package metrics
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "chat"
+
+var (
+	// HTTPRequestsTotal conta as requisições HTTP atendidas, por rota, método e status
+	HTTPRequestsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "Total de requisições HTTP atendidas.",
+	}, []string{"route", "method", "status"})
+
+	// HTTPRequestDuration mede a latência das requisições HTTP
+	HTTPRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "Latência das requisições HTTP em segundos.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"route", "method", "status"})
+
+	// LLMRequestDuration mede a latência das chamadas aos provedores de LLM
+	LLMRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "llm_request_duration_seconds",
+		Help:      "Latência das chamadas aos provedores de LLM em segundos.",
+		Buckets:   []float64{0.5, 1, 2.5, 5, 10, 20, 30, 60, 120, 300},
+	}, []string{"provider", "model", "outcome"})
+
+	// LLMRetriesTotal conta as novas tentativas feitas pelos loops de backoff
+	LLMRetriesTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "llm_retries_total",
+		Help:      "Total de novas tentativas feitas ao chamar os provedores de LLM.",
+	}, []string{"provider", "operation"})
+
+	// LLMTokensTotal acumula os tokens consumidos, separados em prompt e completion
+	LLMTokensTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "llm_tokens_total",
+		Help:      "Total de tokens consumidos nos provedores de LLM.",
+	}, []string{"provider", "model", "type"})
+
+	// StackSpotPollAttempts registra quantas consultas ao callback cada execução precisou
+	StackSpotPollAttempts = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "stackspot_poll_attempts",
+		Help:      "Número de consultas ao callback da StackSpot por execução.",
+		Buckets:   []float64{1, 2, 3, 5, 10, 20, 30, 50, 100, 200},
+	}, []string{"outcome"})
+
+	// WebhookDeliveriesTotal conta as entregas de callbacks de /send, por resultado final
+	WebhookDeliveriesTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "webhook_deliveries_total",
+		Help:      "Total de callbacks de /send entregues ou descartados após as tentativas.",
+	}, []string{"outcome"})
+
+	// CircuitBreakerState expõe o estado do circuit breaker de cada provedor
+	CircuitBreakerState = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "circuit_breaker_state",
+		Help:      "Estado do circuit breaker por provedor (0 = fechado, 1 = half-open, 2 = aberto).",
+	}, []string{"provider"})
+)
+
+// ObserveTokens registra o consumo de tokens de uma chamada ao provedor
+func ObserveTokens(provider, model string, promptTokens, completionTokens int) {
+	if promptTokens > 0 {
+		LLMTokensTotal.WithLabelValues(provider, model, "prompt").Add(float64(promptTokens))
+	}
+	if completionTokens > 0 {
+		LLMTokensTotal.WithLabelValues(provider, model, "completion").Add(float64(completionTokens))
+	}
+}
+
+// RegisterResponseStoreSize expõe no registerer o número de respostas mantidas no ResponseStore e
+// retorna a função que remove o gauge. Cada registerer aceita um único store: um segundo registro
+// falha, em vez de passar a medir outro servidor.
+func RegisterResponseStoreSize(registerer prometheus.Registerer, size func() int) (unregister func(), err error) {
+	gauge := prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "response_store_size",
+		Help:      "Número de respostas armazenadas no ResponseStore.",
+	}, func() float64 {
+		return float64(size())
+	})
+	if err := registerer.Register(gauge); err != nil {
+		return nil, err
+	}
+	return func() { registerer.Unregister(gauge) }, nil
+}
+
+// Handler retorna o handler HTTP que expõe as métricas no formato do Prometheus. As métricas
+// globais vêm do registro padrão; um registerer próprio que também seja um prometheus.Gatherer
+// (como o criado por prometheus.NewRegistry) tem as suas métricas acrescentadas.
+func Handler(registerer prometheus.Registerer) http.Handler {
+	gatherer, ok := registerer.(prometheus.Gatherer)
+	if !ok || registerer == prometheus.DefaultRegisterer {
+		return promhttp.Handler()
+	}
+	return promhttp.HandlerFor(prometheus.Gatherers{prometheus.DefaultGatherer, gatherer}, promhttp.HandlerOpts{})
+}
@@ -14,6 +14,7 @@ func ForceHTTPSMiddleware(next http.Handler, logger *zap.Logger) http.Handler {
 			zap.String("remote_addr", r.RemoteAddr),
 			zap.String("method", r.Method),
 			zap.String("url", r.URL.Path),
+			zap.String("request_id", RequestIDFromContext(r.Context())),
 		)
 
 		if env != "prod" {
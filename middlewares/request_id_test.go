@@ -0,0 +1,47 @@
+package middlewares
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRequestIDMiddleware(t *testing.T) {
+	cases := []struct {
+		name     string
+		received string
+		kept     bool
+	}{
+		{"vazio", "", false},
+		{"válido", "abc-123_DEF.4", true},
+		{"longo demais", strings.Repeat("a", 129), false},
+		{"espaço", "abc 123", false},
+		{"quebra de linha", "abc\nforjado", false},
+		{"caractere não ASCII", "requisição", false},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var fromContext string
+			handler := RequestIDMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				fromContext = RequestIDFromContext(r.Context())
+			}))
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tc.received != "" {
+				req.Header.Set(RequestIDHeader, tc.received)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			got := rec.Header().Get(RequestIDHeader)
+			if got == "" || got != fromContext {
+				t.Fatalf("cabeçalho %q, contexto %q", got, fromContext)
+			}
+			if kept := got == tc.received; kept != tc.kept {
+				t.Fatalf("X-Request-ID = %q, recebido %q, esperado reaproveitar = %v", got, tc.received, tc.kept)
+			}
+		})
+	}
+}
@@ -1,12 +1,34 @@
 package models
 
+import "time"
+
 type Message struct {
-	Role    string `json:"role"`
-	Content string `json:"content"`
+	Role     string `json:"role"`
+	Content  string `json:"content"`
+	Provider string `json:"provider,omitempty"` // Provedor que gerou a resposta (mensagens do assistente)
+	Model    string `json:"model,omitempty"`    // Modelo que gerou a resposta (mensagens do assistente)
 }
 
 type ResponseData struct {
-	Status   string `json:"status"`   // "processing", "completed", ou "error"
-	Response string `json:"response"` // A resposta da LLM
-	Message  string `json:"message"`  // Mensagem de erro, se houver
+	Status   string    `json:"status"`          // "processing", "completed", ou "error"
+	Response string    `json:"response"`        // A resposta da LLM
+	Message  string    `json:"message"`         // Mensagem de erro, se houver
+	Error    *APIError `json:"error,omitempty"` // Detalhes do erro, quando Status é "error"
+}
+
+// APIError é o corpo padrão dos erros retornados pela API
+type APIError struct {
+	Code      string `json:"code"`                 // Código estável para tratamento pelos clientes
+	Message   string `json:"message"`              // Mensagem segura para exibição ao usuário
+	Retryable bool   `json:"retryable"`            // Se a mesma requisição pode ter sucesso mais tarde
+	RequestID string `json:"request_id,omitempty"` // Identificador para correlação com os logs
+}
+
+// Conversation é uma conversa mantida pelo servidor na API v1
+type Conversation struct {
+	ID        string    `json:"id"`
+	Title     string    `json:"title"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	Messages  []Message `json:"messages,omitempty"`
 }
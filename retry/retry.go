@@ -0,0 +1,203 @@
+// retry/retry.go
+
+package retry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+)
+
+// Policy define quantas vezes e com qual espaçamento uma operação é repetida
+type Policy struct {
+	MaxAttempts    int           // Número máximo de tentativas, incluindo a primeira
+	InitialBackoff time.Duration // Espera antes da segunda tentativa
+	MaxBackoff     time.Duration // Limite superior da espera entre tentativas
+	Multiplier     float64       // Fator de crescimento exponencial
+	Jitter         float64       // Fração aleatória (0 a 1) aplicada sobre a espera
+}
+
+// DefaultPolicy retorna a política padrão: 3 tentativas, backoff de 1s dobrando até 30s
+func DefaultPolicy() Policy {
+	return Policy{
+		MaxAttempts:    3,
+		InitialBackoff: time.Second,
+		MaxBackoff:     30 * time.Second,
+		Multiplier:     2,
+		Jitter:         0.2,
+	}
+}
+
+// PolicyFromEnv sobrescreve os campos da política com as variáveis <PREFIX>_RETRY_MAX_ATTEMPTS,
+// <PREFIX>_RETRY_INITIAL_BACKOFF e <PREFIX>_RETRY_MAX_BACKOFF, quando definidas
+func PolicyFromEnv(prefix string, base Policy) Policy {
+	if v, err := strconv.Atoi(os.Getenv(prefix + "_RETRY_MAX_ATTEMPTS")); err == nil && v > 0 {
+		base.MaxAttempts = v
+	}
+	if v, err := time.ParseDuration(os.Getenv(prefix + "_RETRY_INITIAL_BACKOFF")); err == nil && v > 0 {
+		base.InitialBackoff = v
+	}
+	if v, err := time.ParseDuration(os.Getenv(prefix + "_RETRY_MAX_BACKOFF")); err == nil && v > 0 {
+		base.MaxBackoff = v
+	}
+	return base
+}
+
+// Backoff calcula a espera antes da tentativa seguinte à tentativa informada (iniciando em 1)
+func (p Policy) Backoff(attempt int) time.Duration {
+	multiplier := p.Multiplier
+	if multiplier < 1 {
+		multiplier = 1
+	}
+	delay := float64(p.InitialBackoff) * math.Pow(multiplier, float64(attempt-1))
+	if p.MaxBackoff > 0 && delay > float64(p.MaxBackoff) {
+		delay = float64(p.MaxBackoff)
+	}
+	if p.Jitter > 0 {
+		delay += delay * p.Jitter * (rand.Float64()*2 - 1)
+	}
+	return time.Duration(delay)
+}
+
+// HTTPError representa uma resposta HTTP sem sucesso de um provedor
+type HTTPError struct {
+	StatusCode int
+	Body       string
+	RetryAfter time.Duration // Espera sugerida pelo cabeçalho Retry-After, se houver
+}
+
+// NewHTTPError cria um HTTPError a partir da resposta e do corpo já lido
+func NewHTTPError(resp *http.Response, body []byte) *HTTPError {
+	return &HTTPError{
+		StatusCode: resp.StatusCode,
+		Body:       string(body),
+		RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After")),
+	}
+}
+
+func (e *HTTPError) Error() string {
+	return fmt.Sprintf("status %d, resposta: %s", e.StatusCode, e.Body)
+}
+
+// parseRetryAfter aceita tanto segundos quanto uma data HTTP
+func parseRetryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		if wait := time.Until(date); wait > 0 {
+			return wait
+		}
+	}
+	return 0
+}
+
+// IsRetryableStatus indica se o status HTTP representa uma falha transitória
+func IsRetryableStatus(status int) bool {
+	switch status {
+	case http.StatusRequestTimeout,
+		http.StatusTooEarly,
+		http.StatusTooManyRequests,
+		http.StatusInternalServerError,
+		http.StatusBadGateway,
+		http.StatusServiceUnavailable,
+		http.StatusGatewayTimeout,
+		529: // Anthropic: API sobrecarregada
+		return true
+	}
+	return false
+}
+
+// permanentError impede novas tentativas, mesmo que o erro interno seja transitório
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+// Permanent marca o erro como não recuperável
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &permanentError{err: err}
+}
+
+// Retryable indica se vale a pena repetir a operação que falhou com o erro informado
+func Retryable(err error) bool {
+	if err == nil {
+		return false
+	}
+	var permanent *permanentError
+	if errors.As(err, &permanent) {
+		return false
+	}
+	if errors.Is(err, context.Canceled) {
+		return false
+	}
+	var httpErr *HTTPError
+	if errors.As(err, &httpErr) {
+		return IsRetryableStatus(httpErr.StatusCode)
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) && (netErr.Timeout() || netErr.Temporary())
+}
+
+// Notify é chamada antes de cada espera, com a tentativa que falhou e o tempo até a próxima
+type Notify func(attempt int, err error, delay time.Duration)
+
+// Do executa fn até que ela tenha sucesso, retorne um erro não recuperável, as tentativas
+// se esgotem ou o contexto seja encerrado. As esperas respeitam o Retry-After e o contexto.
+func Do(ctx context.Context, p Policy, fn func(ctx context.Context, attempt int) error, notify Notify) error {
+	maxAttempts := p.MaxAttempts
+	if maxAttempts < 1 {
+		maxAttempts = 1
+	}
+
+	var err error
+	for attempt := 1; attempt <= maxAttempts; attempt++ {
+		err = fn(ctx, attempt)
+		if err == nil {
+			return nil
+		}
+		if !Retryable(err) || attempt == maxAttempts || ctx.Err() != nil {
+			break
+		}
+
+		delay := p.Backoff(attempt)
+		var httpErr *HTTPError
+		if errors.As(err, &httpErr) && httpErr.RetryAfter > delay {
+			delay = httpErr.RetryAfter
+		}
+
+		// Não adianta esperar além do prazo do contexto
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < delay {
+			break
+		}
+
+		if notify != nil {
+			notify(attempt, err, delay)
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return fmt.Errorf("%w (última falha: %v)", ctx.Err(), err)
+		case <-timer.C:
+		}
+	}
+
+	return err
+}
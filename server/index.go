@@ -0,0 +1,123 @@
+package server
+
+import (
+	"bytes"
+	"html/template"
+	"io/fs"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/chatcomStackspotAI/handlers"
+	"github.com/chatcomStackspotAI/web"
+	"go.uber.org/zap"
+)
+
+const indexTemplate = "templates/index.html"
+
+// assets serve a interface web a partir do FS configurado. Em produção o template e os ETags
+// são calculados uma única vez; no modo de desenvolvimento tudo é relido do disco a cada
+// requisição e o cache dos navegadores é desativado.
+type assets struct {
+	fs       fs.FS
+	devMode  bool
+	basePath string
+	logger   *zap.Logger
+
+	index   *template.Template
+	etags   map[string]string
+	version string
+}
+
+func newAssets(assetsFS fs.FS, devMode bool, basePath string, logger *zap.Logger) (*assets, error) {
+	a := &assets{fs: assetsFS, devMode: devMode, basePath: basePath, logger: logger}
+	if devMode {
+		return a, nil
+	}
+
+	index, err := template.ParseFS(assetsFS, indexTemplate)
+	if err != nil {
+		return nil, err
+	}
+	etags, version, err := web.ETags(assetsFS)
+	if err != nil {
+		return nil, err
+	}
+	a.index, a.etags, a.version = index, etags, version
+	return a, nil
+}
+
+func (a *assets) indexHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		tmpl := a.index
+		if a.devMode {
+			var err error
+			if tmpl, err = template.ParseFS(a.fs, indexTemplate); err != nil {
+				a.logger.Error("Erro ao carregar o template", zap.Error(err))
+				handlers.WriteError(w, r, http.StatusInternalServerError, handlers.ErrCodeInternal, "Erro ao carregar o template")
+				return
+			}
+		}
+
+		data := map[string]string{
+			"BasePath":     a.basePath,
+			"AssetVersion": a.version,
+			"OpenAIModel":  os.Getenv("OPENAI_MODEL"),
+			"ClaudeModel":  os.Getenv("CLAUDEAI_MODEL"),
+			"DefaultModel": "spot-default",
+			"CurrentModel": os.Getenv("OPENAI_MODEL"), // Modelo inicial
+		}
+
+		if data["OpenAIModel"] == "" {
+			data["OpenAIModel"] = "gpt-4o-mini"
+		}
+		if data["ClaudeModel"] == "" {
+			data["ClaudeModel"] = "claude-3-5-sonnet-20241022"
+		}
+
+		a.logger.Info("Carregando página com modelos",
+			zap.String("openai_model", data["OpenAIModel"]),
+			zap.String("claude_model", data["ClaudeModel"]))
+
+		// Renderiza em memória para não enviar uma página pela metade em caso de erro
+		var page bytes.Buffer
+		if err := tmpl.Execute(&page, data); err != nil {
+			a.logger.Error("Erro ao renderizar o template", zap.Error(err))
+			handlers.WriteError(w, r, http.StatusInternalServerError, handlers.ErrCodeInternal, "Erro ao carregar o template")
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Write(page.Bytes())
+	}
+}
+
+// staticHandler serve static/ com ETag e cache. Os links da página levam ?v=<versão>,
+// então o cache longo é seguro: um deploy com arquivos novos muda a URL.
+func (a *assets) staticHandler() http.Handler {
+	static, err := fs.Sub(a.fs, "static")
+	if err != nil {
+		// fs.Sub só falha com caminhos inválidos, e "static" é fixo
+		panic(err)
+	}
+	fileServer := http.StripPrefix("/static/", http.FileServerFS(static))
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if a.devMode {
+			w.Header().Set("Cache-Control", "no-cache")
+			fileServer.ServeHTTP(w, r)
+			return
+		}
+
+		// O FileServer responde 304 quando o If-None-Match coincide com o ETag definido aqui
+		if etag, ok := a.etags[strings.TrimPrefix(r.URL.Path, "/static/")]; ok {
+			w.Header().Set("ETag", etag)
+			if r.URL.Query().Get("v") == a.version {
+				w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+			} else {
+				w.Header().Set("Cache-Control", "public, max-age=300")
+			}
+		}
+		fileServer.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,260 @@
+// Package server monta o servidor de chat (interface web, API v1, endpoints compatíveis com a
+// OpenAI e métricas) para ser executado sozinho ou montado no mux de outro serviço:
+//
+//	chat, err := server.New(server.Config{Logger: logger, BasePath: "/chat"})
+//	if err != nil {
+//		return err
+//	}
+//	mux.Handle("/chat/", http.StripPrefix("/chat", chat))
+//	chat.Resume(ctx)
+//
+// New não chama os provedores; as mensagens interrompidas pela última parada só voltam a ser
+// consultadas em ListenAndServe ou, quando o servidor é montado em outro mux, em Resume.
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/chatcomStackspotAI/handlers"
+	"github.com/chatcomStackspotAI/llm"
+	"github.com/chatcomStackspotAI/metrics"
+	"github.com/chatcomStackspotAI/middlewares"
+	"github.com/chatcomStackspotAI/web"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.uber.org/zap"
+)
+
+// Config reúne as dependências do servidor. Os campos vazios recebem os valores padrão
+// usados pelo binário principal.
+type Config struct {
+	// Addr é o endereço usado por ListenAndServe (padrão ":8080")
+	Addr string
+	// Logger recebe os logs do servidor e dos handlers (padrão: descarta os logs)
+	Logger *zap.Logger
+	// Manager fornece os clientes de LLM; se nil, é criado a partir das variáveis de ambiente
+	Manager *llm.LLMManager
+	// ResponseStore guarda as respostas assíncronas de /send e /get-response e as mensagens ainda
+	// sem resposta (padrão: em memória). Com várias instâncias, ou para retomar as mensagens
+	// depois de uma reinicialização, use um store compartilhado, como handlers.NewRedisResponseStore.
+	ResponseStore handlers.ResponseStore
+	// ConversationStore guarda as conversas da API v1
+	ConversationStore *handlers.ConversationStore
+	// Webhooks envia os callbacks de /send (callback_url); padrão: configurado pelas variáveis WEBHOOK_*
+	Webhooks *handlers.WebhookDispatcher
+	// Batches processa os lotes de /api/batches; padrão: configurado por BATCH_WORKERS e BATCH_MAX_ITEMS
+	Batches *handlers.BatchProcessor
+	// Registerer recebe as métricas próprias de cada servidor, como o tamanho do ResponseStore
+	// (padrão: prometheus.DefaultRegisterer). Vários servidores no mesmo processo precisam de
+	// registerers distintos, por exemplo prometheus.NewRegistry().
+	Registerer prometheus.Registerer
+	// Auth, se definido, envolve todas as rotas exceto /metrics
+	Auth func(http.Handler) http.Handler
+	// Routes registra rotas adicionais no mesmo mux, depois das rotas do chat
+	Routes func(mux *http.ServeMux)
+	// BasePath é o prefixo sob o qual o servidor está montado (por exemplo, "/chat"),
+	// usado pela interface web para montar as URLs
+	BasePath string
+	// Assets contém os diretórios templates/ e static/ da interface web
+	// (padrão: os arquivos embutidos no binário, web.FS)
+	Assets fs.FS
+	// DevMode relê o template e os arquivos estáticos a cada requisição e desativa o cache,
+	// para editar a interface sem reiniciar; use com Assets: os.DirFS("web")
+	DevMode bool
+}
+
+// Server é o servidor de chat. Implementa http.Handler.
+type Server struct {
+	config      Config
+	handler     http.Handler
+	httpServer  *http.Server
+	ownsManager bool   // O LLMManager foi criado por New e é encerrado em Shutdown
+	unregister  func() // Remove as métricas do servidor do Config.Registerer
+	resumeOnce  sync.Once
+}
+
+// New valida a configuração, cria as dependências ausentes e registra as rotas
+func New(config Config) (*Server, error) {
+	if config.Addr == "" {
+		config.Addr = ":8080"
+	}
+	if config.Logger == nil {
+		config.Logger = zap.NewNop()
+	}
+	if config.Assets == nil {
+		config.Assets = web.FS
+	}
+	config.BasePath = strings.TrimRight(config.BasePath, "/")
+	if config.BasePath != "" && !strings.HasPrefix(config.BasePath, "/") {
+		return nil, fmt.Errorf("BasePath deve começar com /: %q", config.BasePath)
+	}
+
+	ownsManager := config.Manager == nil
+	if ownsManager {
+		manager, err := llm.NewLLMManager(config.Logger)
+		if err != nil {
+			return nil, fmt.Errorf("erro ao inicializar o LLMManager: %w", err)
+		}
+		config.Manager = manager
+	}
+	if config.ResponseStore == nil {
+		config.ResponseStore = handlers.NewMemoryResponseStore()
+	}
+	if config.ConversationStore == nil {
+		config.ConversationStore = handlers.NewConversationStore()
+	}
+	if config.Webhooks == nil {
+		config.Webhooks = handlers.NewWebhookDispatcher(handlers.WebhookConfigFromEnv(), config.Logger)
+	}
+	if config.Batches == nil {
+		config.Batches = handlers.NewBatchProcessor(config.Manager, handlers.BatchConfigFromEnv(), config.Logger)
+	}
+	if config.Registerer == nil {
+		config.Registerer = prometheus.DefaultRegisterer
+	}
+
+	handler, err := newHandler(config)
+	if err != nil {
+		if ownsManager {
+			config.Manager.Close()
+		}
+		return nil, err
+	}
+
+	// O tamanho só é conhecido nos stores locais
+	unregister := func() {}
+	if sized, ok := config.ResponseStore.(interface{ Size() int }); ok {
+		if unregister, err = metrics.RegisterResponseStoreSize(config.Registerer, sized.Size); err != nil {
+			if ownsManager {
+				config.Manager.Close()
+			}
+			return nil, fmt.Errorf("erro ao registrar a métrica do ResponseStore (use um Config.Registerer por servidor): %w", err)
+		}
+	}
+
+	s := &Server{config: config, handler: handler, ownsManager: ownsManager, unregister: unregister}
+	s.httpServer = &http.Server{
+		Addr:         config.Addr,
+		Handler:      handler,
+		ReadTimeout:  5 * time.Second,
+		WriteTimeout: 10 * time.Second,
+		IdleTimeout:  120 * time.Second,
+	}
+	return s, nil
+}
+
+func newHandler(config Config) (http.Handler, error) {
+	logger := config.Logger
+	manager := config.Manager
+
+	assets, err := newAssets(config.Assets, config.DevMode, config.BasePath, logger)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao carregar a interface web: %w", err)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", assets.indexHandler())
+	mux.HandleFunc("/send", handlers.SendMessageHandler(manager, config.ResponseStore, config.Webhooks, logger))
+	mux.HandleFunc("/get-response", handlers.GetResponseHandler(config.ResponseStore, logger))
+	mux.HandleFunc("GET /ws", handlers.WebSocketHandler(manager, config.ResponseStore, logger))
+	mux.HandleFunc("/api/models", handlers.ModelsHandler(manager, logger))
+	mux.HandleFunc("/api/providers", handlers.ProvidersHandler(manager, logger))
+	mux.Handle("/metrics", metrics.Handler(config.Registerer))
+
+	// Lotes de prompts enviados como JSONL
+	handlers.RegisterBatchRoutes(mux, config.Batches, manager, logger)
+
+	// Endpoints compatíveis com a API da OpenAI, para SDKs e plugins de IDE
+	mux.HandleFunc("POST /v1/chat/completions", handlers.OpenAIChatCompletionsHandler(manager, logger))
+	mux.HandleFunc("GET /v1/models", handlers.OpenAIModelsHandler(manager))
+
+	// API REST versionada, validada contra api/openapi.yaml
+	if err := handlers.RegisterAPIV1(mux, manager, config.ConversationStore, config.Webhooks, logger); err != nil {
+		return nil, fmt.Errorf("erro ao registrar a API v1: %w", err)
+	}
+	mux.Handle("/static/", assets.staticHandler())
+
+	if config.Routes != nil {
+		config.Routes(mux)
+	}
+
+	// As métricas envolvem o mux diretamente para enxergar o padrão da rota (r.Pattern),
+	// que o ServeMux define na requisição recebida
+	var handler http.Handler = middlewares.MetricsMiddleware(mux)
+	if config.Auth != nil {
+		handler = withAuth(handler, config.Auth)
+	}
+
+	tracedHandler := otelhttp.NewHandler(handler, "http.server",
+		otelhttp.WithSpanNameFormatter(func(_ string, r *http.Request) string {
+			return r.Method + " " + r.URL.Path
+		}),
+		// Métricas e arquivos estáticos não geram spans
+		otelhttp.WithFilter(func(r *http.Request) bool {
+			return r.URL.Path != "/metrics" && !strings.HasPrefix(r.URL.Path, "/static/")
+		}),
+	)
+
+	return middlewares.RequestIDMiddleware(middlewares.ForceHTTPSMiddleware(tracedHandler, logger)), nil
+}
+
+// withAuth aplica o middleware de autenticação a todas as rotas, exceto às métricas,
+// que costumam ser coletadas pelo Prometheus sem credenciais
+func withAuth(next http.Handler, auth func(http.Handler) http.Handler) http.Handler {
+	protected := auth(next)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/metrics" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		protected.ServeHTTP(w, r)
+	})
+}
+
+// ServeHTTP atende a requisição com as rotas e os middlewares do chat
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.handler.ServeHTTP(w, r)
+}
+
+// Manager retorna o LLMManager usado pelo servidor
+func (s *Server) Manager() *llm.LLMManager {
+	return s.config.Manager
+}
+
+// Resume volta a consultar em background as execuções interrompidas pela última parada.
+// ListenAndServe já o chama; quem monta o servidor em outro mux deve chamá-lo ao iniciar.
+// Só a primeira chamada tem efeito.
+func (s *Server) Resume(ctx context.Context) {
+	s.resumeOnce.Do(func() {
+		handlers.ResumePendingExecutions(ctx, s.config.Manager, s.config.ResponseStore, s.config.Webhooks, s.config.Logger)
+	})
+}
+
+// ListenAndServe retoma as execuções pendentes, inicia o servidor HTTP em Config.Addr e
+// bloqueia até Shutdown
+func (s *Server) ListenAndServe() error {
+	s.Resume(context.Background())
+	s.config.Logger.Info("Servidor iniciado", zap.String("addr", s.config.Addr))
+	if err := s.httpServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// Shutdown encerra o servidor aguardando as requisições em andamento. O LLMManager só é
+// encerrado quando foi criado por New; um Config.Manager próprio continua com quem o criou.
+func (s *Server) Shutdown(ctx context.Context) error {
+	err := s.httpServer.Shutdown(ctx)
+	s.unregister()
+	if s.ownsManager {
+		s.config.Manager.Close()
+	}
+	return err
+}
@@ -0,0 +1,224 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/chatcomStackspotAI/handlers"
+	"github.com/chatcomStackspotAI/llm"
+	"github.com/chatcomStackspotAI/models"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// setMockEnv deixa apenas o provedor MOCK configurado, como na CI
+func setMockEnv(t *testing.T) {
+	t.Helper()
+	for _, key := range []string{"OPENAI_API_KEY", "CLAUDEAI_API_KEY", "CLIENT_ID", "CLIENT_SECRET", "STACKSPOT_ACCOUNTS", "LLM_CASSETTE_MODE"} {
+		t.Setenv(key, "")
+	}
+	script := filepath.Join(t.TempDir(), "mock.json")
+	rules := `[{"match":"(?i)erro","status":503},{"match":".*","response":"resposta roteirizada do mock"}]`
+	if err := os.WriteFile(script, []byte(rules), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("MOCK_ENABLED", "true")
+	t.Setenv("MOCK_SCRIPT_FILE", script)
+	t.Setenv("MOCK_CHUNK_DELAY", "0s")
+}
+
+// newMockServer inicia o servidor completo apenas com o provedor MOCK
+func newMockServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	setMockEnv(t)
+	srv, err := New(Config{})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	ts := httptest.NewServer(srv)
+	t.Cleanup(func() {
+		ts.Close()
+		srv.Shutdown(context.Background())
+	})
+	return ts
+}
+
+// sendAndWait envia a mensagem por /send e aguarda a resposta em /get-response
+func sendAndWait(t *testing.T, ts *httptest.Server, prompt string) models.ResponseData {
+	t.Helper()
+	body := `{"provider":"MOCK","prompt":"` + prompt + `","session_id":"sessao-ci"}`
+	resp, err := http.Post(ts.URL+"/send", "application/json", strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("/send: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("/send: status %d", resp.StatusCode)
+	}
+	var sent struct {
+		MessageID string `json:"message_id"`
+	}
+	json.NewDecoder(resp.Body).Decode(&sent)
+
+	query := url.Values{"session_id": {"sessao-ci"}, "message_id": {sent.MessageID}, "wait": {"10s"}}
+	resp, err = http.Get(ts.URL + "/get-response?" + query.Encode())
+	if err != nil {
+		t.Fatalf("/get-response: %v", err)
+	}
+	defer resp.Body.Close()
+	var data models.ResponseData
+	if err := json.NewDecoder(resp.Body).Decode(&data); err != nil {
+		t.Fatalf("/get-response: resposta inválida: %v", err)
+	}
+	return data
+}
+
+func TestMockProviderFlow(t *testing.T) {
+	ts := newMockServer(t)
+
+	resp, err := http.Get(ts.URL + "/api/providers")
+	if err != nil {
+		t.Fatalf("/api/providers: %v", err)
+	}
+	var providers []llm.ProviderStatus
+	json.NewDecoder(resp.Body).Decode(&providers)
+	resp.Body.Close()
+	if len(providers) != 1 || providers[0].Provider != llm.ProviderMock {
+		t.Fatalf("provedores = %+v, esperado apenas %s", providers, llm.ProviderMock)
+	}
+
+	if data := sendAndWait(t, ts, "oi"); data.Status != "completed" || data.Response != "resposta roteirizada do mock" {
+		t.Fatalf("resposta = %+v", data)
+	}
+	data := sendAndWait(t, ts, "simule um erro")
+	if data.Status != "error" || data.Error == nil || data.Error.Code != string(llm.ErrCodeProviderUnavailable) {
+		t.Fatalf("resposta = %+v, esperado erro %s", data, llm.ErrCodeProviderUnavailable)
+	}
+}
+
+func TestMockProviderStreaming(t *testing.T) {
+	ts := newMockServer(t)
+
+	body := `{"model":"mock","stream":true,"messages":[{"role":"user","content":"oi"}]}`
+	resp, err := http.Post(ts.URL+"/v1/chat/completions", "application/json", strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("/v1/chat/completions: %v", err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); !strings.HasPrefix(ct, "text/event-stream") {
+		t.Fatalf("Content-Type = %q", ct)
+	}
+
+	var content strings.Builder
+	var chunks int
+	done := false
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		line, ok := strings.CutPrefix(scanner.Text(), "data: ")
+		if !ok {
+			continue
+		}
+		if line == "[DONE]" {
+			done = true
+			break
+		}
+		var chunk struct {
+			Choices []struct {
+				Delta struct {
+					Content string `json:"content"`
+				} `json:"delta"`
+			} `json:"choices"`
+		}
+		if err := json.Unmarshal([]byte(line), &chunk); err != nil {
+			t.Fatalf("chunk inválido %q: %v", line, err)
+		}
+		if len(chunk.Choices) > 0 && chunk.Choices[0].Delta.Content != "" {
+			chunks++
+			content.WriteString(chunk.Choices[0].Delta.Content)
+		}
+	}
+	if !done {
+		t.Fatal("o streaming terminou sem [DONE]")
+	}
+	if content.String() != "resposta roteirizada do mock" || chunks < 2 {
+		t.Fatalf("conteúdo = %q em %d trechos", content.String(), chunks)
+	}
+}
+
+func TestResponseStoreSizePerServer(t *testing.T) {
+	setMockEnv(t)
+	scrape := func(srv *Server) string {
+		rec := httptest.NewRecorder()
+		srv.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+		return rec.Body.String()
+	}
+
+	// Cada servidor com o seu registry expõe o tamanho do próprio store
+	store := handlers.NewMemoryResponseStore()
+	store.SetResponse(context.Background(), "sessao", "mensagem", &models.ResponseData{Status: "processing"})
+	first, err := New(Config{ResponseStore: store, Registerer: prometheus.NewRegistry()})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer first.Shutdown(context.Background())
+	second, err := New(Config{Registerer: prometheus.NewRegistry()})
+	if err != nil {
+		t.Fatalf("New com outro registry: %v", err)
+	}
+	defer second.Shutdown(context.Background())
+
+	if body := scrape(first); !strings.Contains(body, "chat_response_store_size 1\n") {
+		t.Errorf("métricas do primeiro servidor sem o tamanho do store:\n%s", body)
+	}
+	if body := scrape(second); !strings.Contains(body, "chat_response_store_size 0\n") {
+		t.Errorf("métricas do segundo servidor sem o tamanho do store:\n%s", body)
+	}
+
+	// No registerer padrão, um segundo servidor é recusado até que o primeiro seja encerrado
+	defaultServer, err := New(Config{})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	if _, err := New(Config{}); err == nil {
+		t.Fatal("o segundo servidor no registerer padrão deveria ser recusado")
+	}
+	defaultServer.Shutdown(context.Background())
+	replacement, err := New(Config{})
+	if err != nil {
+		t.Fatalf("New depois do Shutdown: %v", err)
+	}
+	replacement.Shutdown(context.Background())
+}
+
+func TestPendingExecutionsResumeOnlyOnStart(t *testing.T) {
+	setMockEnv(t)
+	store := handlers.NewMemoryResponseStore()
+	store.AddPending(context.Background(), handlers.PendingExecution{SessionID: "sessao", MessageID: "interrompida", Provider: llm.ProviderMock})
+
+	srv, err := New(Config{ResponseStore: store, Registerer: prometheus.NewRegistry()})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer srv.Shutdown(context.Background())
+
+	// Criar o servidor não retoma nada
+	if _, exists, _ := store.GetResponse(context.Background(), "sessao", "interrompida"); exists {
+		t.Fatal("New retomou as execuções pendentes")
+	}
+
+	srv.Resume(context.Background())
+	data, exists, _ := store.GetResponse(context.Background(), "sessao", "interrompida")
+	if !exists || data.Status != "error" || data.Error.Code != handlers.ErrCodeInterrupted {
+		t.Fatalf("mensagem depois de Resume = %+v", data)
+	}
+	if pending, _ := store.ListPending(context.Background()); len(pending) != 0 {
+		t.Fatalf("execuções pendentes depois de Resume: %v", pending)
+	}
+}
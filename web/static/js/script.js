@@ -17,6 +17,8 @@ document.addEventListener('DOMContentLoaded', () => {
     const openaiModel = document.body.getAttribute('data-openai-model') || 'gpt-4o-mini';
     const claudeModel = document.body.getAttribute('data-claude-model') || 'claude-3-5-sonnet-20241022';
     const stackspotModel = document.body.getAttribute('data-spot-model') || 'spot-default';
+    // Prefixo das rotas quando o chat está montado sob outro caminho (ex.: /chat)
+    const basePath = document.body.getAttribute('data-base-path') || '';
 
     // Estado do aplicativo
     let currentChatID = null;
@@ -80,6 +82,9 @@ document.addEventListener('DOMContentLoaded', () => {
             case 'SPOT':
                 return 'GPT-4o';
 
+            case 'MOCK':
+                return 'Mock';
+
             default:
                 return 'Assistente';
         }
@@ -122,6 +127,10 @@ document.addEventListener('DOMContentLoaded', () => {
 
         // Event listeners
         addEventListeners();
+
+        // Atualizar periodicamente a disponibilidade dos provedores no seletor
+        refreshProviderStatus();
+        setInterval(refreshProviderStatus, 30000);
     }
 
     // Inicialização
@@ -176,6 +185,42 @@ document.addEventListener('DOMContentLoaded', () => {
         console.log('Assistant name updated to:', assistantName);
     }
 
+    // Consulta o estado dos circuit breakers e sinaliza no seletor os provedores indisponíveis
+    async function refreshProviderStatus() {
+        try {
+            const response = await fetch(`${basePath}/api/providers`);
+            if (!response.ok) {
+                return;
+            }
+            const statuses = await response.json();
+            const statusByProvider = {};
+            statuses.forEach(status => {
+                statusByProvider[status.provider] = status;
+            });
+
+            Array.from(llmProviderSelect.options).forEach(option => {
+                if (!option.dataset.label) {
+                    option.dataset.label = option.textContent;
+                }
+                const status = statusByProvider[option.value];
+                let suffix = '';
+                if (!status) {
+                    suffix = ' (não configurado)';
+                } else if (status.state === 'open') {
+                    suffix = ' (indisponível)';
+                } else if (status.state === 'half-open') {
+                    suffix = ' (instável)';
+                }
+                option.textContent = option.dataset.label + suffix;
+                option.title = status && status.open_until
+                    ? `Circuito aberto até ${new Date(status.open_until).toLocaleTimeString()}`
+                    : '';
+            });
+        } catch (error) {
+            console.error('Erro ao obter o status dos provedores:', error);
+        }
+    }
+
     function isChatExists(chatID) {
         const chatList = JSON.parse(localStorage.getItem('chatList')) || [];
         return chatList.some(chat => chat.id === chatID);
@@ -316,7 +361,7 @@ document.addEventListener('DOMContentLoaded', () => {
             // Adicionar indicador de digitação
             addMessage(assistantName, '', 'assistant-message', false, false, true);
 
-            const response = await fetch('/send', {
+            const response = await fetch(`${basePath}/send`, {
                 method: 'POST',
                 headers: { 'Content-Type': 'application/json' },
                 body: JSON.stringify({
@@ -329,8 +374,7 @@ document.addEventListener('DOMContentLoaded', () => {
             });
 
             if (!response.ok) {
-                const errorText = await response.text();
-                throw new Error(errorText);
+                throw new Error(await readErrorMessage(response));
             }
 
             const data = await response.json();
@@ -345,6 +389,25 @@ document.addEventListener('DOMContentLoaded', () => {
         }
     }
 
+    // Extrai a mensagem do envelope de erro da API, com fallback para o texto puro
+    async function readErrorMessage(response) {
+        const text = await response.text();
+        try {
+            return formatApiError(JSON.parse(text).error) || text;
+        } catch (e) {
+            return text;
+        }
+    }
+
+    function formatApiError(apiError) {
+        if (!apiError) {
+            return '';
+        }
+        return apiError.request_id
+            ? `${apiError.message} (código: ${apiError.code}, request ID: ${apiError.request_id})`
+            : apiError.message;
+    }
+
     async function pollForResponse(messageID) {
         try {
             // Obter o session_id do localStorage
@@ -353,11 +416,12 @@ document.addEventListener('DOMContentLoaded', () => {
                 throw new Error('session_id não encontrado no localStorage');
             }
 
-            // Chamar o servidor para obter a resposta
-            const response = await fetch(`/get-response?message_id=${messageID}&session_id=${sessionId}`);
+            // Chamar o servidor para obter a resposta; com wait, ele só responde quando a
+            // mensagem for concluída ou o prazo acabar (abaixo dos 30s dos roteadores comuns)
+            const startedAt = Date.now();
+            const response = await fetch(`${basePath}/get-response?message_id=${messageID}&session_id=${sessionId}&wait=25s`);
             if (!response.ok) {
-                const errorText = await response.text();
-                throw new Error(errorText);
+                throw new Error(await readErrorMessage(response));
             }
 
             const data = await response.json();
@@ -383,12 +447,14 @@ document.addEventListener('DOMContentLoaded', () => {
                 // Salvar a mensagem da IA no localStorage
                 saveMessage(assistantName, data.response, true);  // Salva a mensagem da IA
             } else if (data.status === 'processing') {
+                // Consulta de novo em seguida; se o servidor não esperou, aguarda 1s para não sobrecarregá-lo
+                const delay = Date.now() - startedAt < 1000 ? 1000 : 0;
                 setTimeout(() => {
                     pollForResponse(messageID);
-                }, 1000);
+                }, delay);
             } else if (data.status === 'error') {
                 removeLastMessage();
-                addMessage('Erro', data.message, 'assistant-message', false, true);
+                addMessage('Erro', formatApiError(data.error) || data.message, 'assistant-message', false, true);
             }
         } catch (error) {
             console.error("Erro ao obter a resposta:", error);
@@ -0,0 +1,54 @@
+// Package web contém a interface do chat (template e arquivos estáticos) embutida no binário.
+package web
+
+import (
+	"crypto/sha256"
+	"embed"
+	"encoding/hex"
+	"io/fs"
+	"sort"
+)
+
+// FS contém os diretórios templates/ e static/
+//
+//go:embed templates static
+var FS embed.FS
+
+// ETags calcula o ETag de cada arquivo em static/, indexado pelo caminho relativo a static/
+// (por exemplo, "js/script.js"), e uma versão que muda sempre que algum arquivo muda,
+// usada para invalidar o cache dos navegadores a cada deploy
+func ETags(assets fs.FS) (etags map[string]string, version string, err error) {
+	etags = make(map[string]string)
+	static, err := fs.Sub(assets, "static")
+	if err != nil {
+		return nil, "", err
+	}
+
+	err = fs.WalkDir(static, ".", func(path string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return err
+		}
+		content, err := fs.ReadFile(static, path)
+		if err != nil {
+			return err
+		}
+		sum := sha256.Sum256(content)
+		etags[path] = `"` + hex.EncodeToString(sum[:8]) + `"`
+		return nil
+	})
+	if err != nil {
+		return nil, "", err
+	}
+
+	paths := make([]string, 0, len(etags))
+	for path := range etags {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	versionHash := sha256.New()
+	for _, path := range paths {
+		versionHash.Write([]byte(path + etags[path]))
+	}
+	return etags, hex.EncodeToString(versionHash.Sum(nil)[:4]), nil
+}